@@ -3,3 +3,5 @@ module cryptographic-computing
 go 1.21.0
 
 require github.com/hashicorp/vault/sdk v0.10.2
+
+require filippo.io/nistec v0.0.3
@@ -33,7 +33,10 @@ func main() {
 
 	// create cryptoalgorithm, messages and selection bits for algorithms.
 	elGamal := elgamal.ElGamal{}
-	elGamal.Init()
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil { // elGamal.Init() generates a new group instead
+		fmt.Println("Error:", err)
+		return
+	}
 	selectionBits := utils.RandomSelectionBits(m)
 	var messages []*utils.MessagePair
 	for i := 0; i < m; i++ {
@@ -46,11 +49,15 @@ func main() {
 
 	/* Outcoment desired protocol that you want to test */
 
-	//result := OTBasic.OTBasicProtocol(l, m, selectionBits, messages, elGamal)
-	result := OTExtension.OTExtensionProtocol(k, l, m, selectionBits, messages, elGamal)
-	//result := OTExtension.OTExtensionProtocolTranspose(k, l, m, selectionBits, messages, elGamal)
-	//result := OTExtension.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
-	//result := OTExtension.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true) // multithreaded
+	//result, communication, err := OTBasic.OTBasicProtocol(m, selectionBits, messages, elGamal)
+	result, communication, err := OTExtension.OTExtensionProtocol(k, m, selectionBits, messages, elGamal)
+	//result, communication, err := OTExtension.OTExtensionProtocolTranspose(k, l, m, selectionBits, messages, elGamal)
+	//result, communication, err := OTExtension.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
+	//result, communication, err := OTExtension.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true) // multithreaded
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
 
 	println("")
 	print("Selection bits: ")
@@ -73,4 +80,9 @@ func main() {
 		fmt.Printf("\n")
 		fmt.Printf("%d", b)
 	}
+	fmt.Printf("\n")
+
+	total := communication.Total()
+	fmt.Printf("Communication: %d bytes sender to receiver, %d bytes receiver to sender, %d rounds\n",
+		total.SenderToReceiver, total.ReceiverToSender, total.Rounds)
 }
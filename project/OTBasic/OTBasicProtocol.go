@@ -3,34 +3,75 @@ package OTBasic
 import (
 	"cryptographic-computing/project/elgamal"
 	"cryptographic-computing/project/utils"
-	"fmt"
 )
 
-// k: Security parameter, l: Byte length of each message, m: Number of messages to be sent and selction bits
-func OTBasicProtocol(l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal) [][]byte {
+// Errors returned for invalid inputs and ciphertexts. They are shared with the OTExtension and elgamal packages,
+// so errors.Is works for all of them.
+var (
+	ErrInvalidChoiceBit     = utils.ErrInvalidChoiceBit
+	ErrDimensionMismatch    = utils.ErrDimensionMismatch
+	ErrInvalidMessageLength = utils.ErrInvalidMessageLength
+	ErrDecryptionFailed     = elgamal.ErrDecryptionFailed
+	ErrInvalidElement       = elgamal.ErrInvalidElement
+)
+
+// m: Number of messages to be sent and selction bits
+// The messages are encrypted with hybrid ElGamal, so they are byte strings of any length, and the two messages of a
+// pair can have different lengths.
+// Returns an error wrapping ErrInvalidChoiceBit, ErrDimensionMismatch or ErrInvalidMessageLength if the inputs are invalid.
+// Along with the result, the bytes sent in each direction and the rounds are returned. The public keys are accounted
+// to utils.PhaseBaseOT and the encrypted messages to utils.PhaseCiphertexts.
+func OTBasicProtocol(m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal) ([][]byte, *utils.Communication, error) {
+	return OTBasicProtocolWithContext(m, selectionBits, messages, nil, elGamal)
+}
+
+// OTBasicProtocolWithContext runs OTBasicProtocol with the hybrid encryptions bound to the context, e.g. the session
+// ID of the OTExtension protocol that uses the OTs as base OTs, so ciphertexts from a run with another context do not
+// decrypt.
+func OTBasicProtocolWithContext(m int, selectionBits []byte, messages []*utils.MessagePair, context []byte, elGamal elgamal.ElGamal) ([][]byte, *utils.Communication, error) {
+
+	if err := utils.ValidateCount("selectionBits", len(selectionBits), m); err != nil {
+		return nil, nil, err
+	}
+	if err := utils.ValidateCount("messages", len(messages), m); err != nil {
+		return nil, nil, err
+	}
 
-	receiver := OTReceiver{}
-	sender := OTSender{}
+	communication := &utils.Communication{}
+	receiver := OTReceiver{context: context}
+	sender := OTSender{context: context}
 
 	// Initialize the receiver's selection bits and the sender's messages
-	receiver.Init(selectionBits)
-	sender.Init(messages)
+	if err := receiver.Init(selectionBits); err != nil {
+		return nil, nil, err
+	}
+	if err := sender.Init(messages); err != nil {
+		return nil, nil, err
+	}
 
 	// The receiver makes secret keys and oblivious keys for each message to be received based on the selection bits.
 	// Then send the public keys to the sender.
-	publicKeys := receiver.Choose(m, &elGamal)
-	sender.ReceiveKeys(publicKeys)
+	publicKeys, err := receiver.Choose(m, &elGamal)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseBaseOT, utils.ReceiverToSender, utils.PublicKeyPairsWireLength(publicKeys))
+	if err := sender.ReceiveKeys(publicKeys, &elGamal); err != nil {
+		return nil, nil, err
+	}
 
-	// Sender encrypts the messages using the public keys received from the receiver.
+	// Sender encrypts the messages using the public keys received from the receiver, with hybrid ElGamal.
 	// Then send the ciphertexts to the receiver.
-	ciphertextPairs := sender.EncryptMessages(&elGamal)
+	ciphertextPairs, err := sender.EncryptMessages(&elGamal)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.HybridCiphertextPairsWireLength(ciphertextPairs))
 
 	// The receiver decrypts the ciphertexts using the secret keys depending on the selection bits.
-	result := receiver.DecryptMessage(ciphertextPairs, l, &elGamal)
-
-	if len(result) != len(messages) {
-		fmt.Println("Result length is not equal to messages length in OTBasicProtocol")
+	plaintexts, err := receiver.DecryptMessage(ciphertextPairs, &elGamal)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	return result
+	return plaintexts, communication, nil
 }
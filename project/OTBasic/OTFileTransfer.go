@@ -0,0 +1,164 @@
+// OTFileTransfer.go
+package OTBasic
+
+import (
+	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/utils"
+	"fmt"
+	"io"
+)
+
+// FileKeyLength is the bit length of the random keys the FileSender transfers with an OT.
+const FileKeyLength = 256
+
+// FileSender offers two payloads of any size, of which the FileReceiver obtains exactly one. The payloads are
+// encrypted with utils.EncryptStream under the two messages of an OT, so they are never held in memory.
+// Both encrypted payloads are sent in full, so their lengths are not hidden from the receiver.
+type FileSender struct {
+	keys   *utils.MessagePair // Keys (K0, K1) of the two payloads, the sender's messages in the OT.
+	random io.Reader          // Source of randomness for the keys, or nil for crypto/rand.
+}
+
+// FileReceiver obtains one of the two payloads of a FileSender, using the key it received in the OT.
+type FileReceiver struct {
+	selectionBit byte   // The payload b to obtain.
+	key          []byte // Key K_b received in the OT.
+}
+
+// SetRandom sets the source of randomness for the keys chosen with ChooseKeys.
+func (sender *FileSender) SetRandom(random io.Reader) {
+	sender.random = random
+}
+
+// Method for choosing two random keys of FileKeyLength bits, which the sender inputs to an OT.
+func (sender *FileSender) ChooseKeys() (*utils.MessagePair, error) {
+	key0, err := utils.RandomSeedFrom(sender.random, FileKeyLength/8)
+	if err != nil {
+		return nil, err
+	}
+	key1, err := utils.RandomSeedFrom(sender.random, FileKeyLength/8)
+	if err != nil {
+		return nil, err
+	}
+	sender.keys = &utils.MessagePair{Message0: key0, Message1: key1}
+	return sender.keys, nil
+}
+
+// Method for using the sender's output of an existing OT as keys, e.g. a pair of a random OT from
+// OTExtension.OTExtensionProtocolRandom. The keys should have at least 128 bits.
+func (sender *FileSender) SetKeys(keys *utils.MessagePair) error {
+	if keys == nil || len(keys.Message0) == 0 || len(keys.Message1) == 0 {
+		return fmt.Errorf("%w: both keys must be non-empty", ErrInvalidMessageLength)
+	}
+	sender.keys = keys
+	return nil
+}
+
+// Method for sending both payloads: payload0 encrypted under K0 and then payload1 encrypted under K1 are written to
+// channel. Returns the number of bytes written.
+func (sender *FileSender) SendPayloads(payload0 io.Reader, payload1 io.Reader, channel io.Writer) (int64, error) {
+	if sender.keys == nil {
+		return 0, fmt.Errorf("%w: keys must be chosen or set before sending", ErrInvalidMessageLength)
+	}
+	written0, err := utils.EncryptStream(sender.keys.Message0, payload0, channel)
+	if err != nil {
+		return written0, fmt.Errorf("payload 0: %w", err)
+	}
+	written1, err := utils.EncryptStream(sender.keys.Message1, payload1, channel)
+	if err != nil {
+		return written0 + written1, fmt.Errorf("payload 1: %w", err)
+	}
+	return written0 + written1, nil
+}
+
+// Initialize the receiver with its selection bit b and the key K_b it received in the OT.
+// Returns an error wrapping ErrInvalidChoiceBit if b is not 0 or 1, or ErrInvalidMessageLength if the key is empty.
+func (receiver *FileReceiver) Init(selectionBit byte, key []byte) error {
+	if err := utils.ValidateSelectionBits([]byte{selectionBit}); err != nil {
+		return err
+	}
+	if len(key) == 0 {
+		return fmt.Errorf("%w: key must be non-empty", ErrInvalidMessageLength)
+	}
+	receiver.selectionBit = selectionBit
+	receiver.key = key
+	return nil
+}
+
+// Method for receiving payload b from channel and writing it to output. The other payload is read and discarded.
+// Returns the number of payload bytes written, and an error wrapping utils.ErrStreamAuthentication or
+// utils.ErrMalformedMessage if the stream was changed. After an error, the output written so far must be discarded.
+func (receiver *FileReceiver) ReceivePayload(channel io.Reader, output io.Writer) (int64, error) {
+	if receiver.key == nil {
+		return 0, fmt.Errorf("%w: the receiver must be initialized with its key", ErrInvalidMessageLength)
+	}
+	if receiver.selectionBit == 1 {
+		if err := utils.SkipStream(channel); err != nil {
+			return 0, fmt.Errorf("payload 0: %w", err)
+		}
+	}
+	written, err := utils.DecryptStream(receiver.key, channel, output)
+	if err != nil {
+		return written, fmt.Errorf("payload %d: %w", receiver.selectionBit, err)
+	}
+	if receiver.selectionBit == 0 {
+		if err := utils.SkipStream(channel); err != nil {
+			return written, fmt.Errorf("payload 1: %w", err)
+		}
+	}
+	return written, nil
+}
+
+// OTFileTransferProtocol transfers one of two payloads of any size: the receiver with selection bit b obtains
+// payload b, written to output, and learns nothing about the other payload except its length. The sender learns
+// nothing about b. The sender's random keys are transferred with OTBasicProtocol, and the payloads are streamed
+// through a pipe chunk by chunk, so gigabytes can be sent without holding them in memory.
+// The OT is accounted to utils.PhaseBaseOT and utils.PhaseCiphertexts, and the encrypted payloads to
+// utils.PhaseCiphertexts.
+func OTFileTransferProtocol(selectionBit byte, payload0 io.Reader, payload1 io.Reader, output io.Writer, elGamal elgamal.ElGamal) (*utils.Communication, error) {
+	if err := utils.ValidateSelectionBits([]byte{selectionBit}); err != nil {
+		return nil, err
+	}
+
+	sender := FileSender{random: elGamal.Random()}
+	receiver := FileReceiver{}
+
+	// The sender chooses two random keys, and the receiver obtains key b with an OT.
+	keys, err := sender.ChooseKeys()
+	if err != nil {
+		return nil, err
+	}
+	received, communication, err := OTBasicProtocol(1, []byte{selectionBit}, []*utils.MessagePair{keys}, elGamal)
+	if err != nil {
+		return nil, err
+	}
+	if err := receiver.Init(selectionBit, received[0]); err != nil {
+		return nil, err
+	}
+
+	// The sender streams both encrypted payloads to the receiver, which decrypts payload b.
+	channelReader, channelWriter := io.Pipe()
+	type sendResult struct {
+		written int64
+		err     error
+	}
+	sent := make(chan sendResult, 1)
+	go func() {
+		written, err := sender.SendPayloads(payload0, payload1, channelWriter)
+		channelWriter.CloseWithError(err)
+		sent <- sendResult{written, err}
+	}()
+
+	_, receiveErr := receiver.ReceivePayload(channelReader, output)
+	channelReader.CloseWithError(receiveErr) // Unblocks the sender if the receiver stopped early
+	result := <-sent
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, int(result.written))
+
+	if result.err != nil {
+		return nil, result.err
+	}
+	if receiveErr != nil {
+		return nil, receiveErr
+	}
+	return communication, nil
+}
@@ -5,26 +5,67 @@ package OTBasic
 import (
 	"cryptographic-computing/project/elgamal"
 	"cryptographic-computing/project/utils"
+	"fmt"
+	"io"
 	"math/big"
 )
 
 type OTReceiver struct {
 	secretKeys    []*big.Int // Secret keys for each message to be received.
 	selectionBits []byte     // Selection bits for each message to be received depending on if the receiver wants to learn M0 or M1 (Hidden for the OTSender)
+	random        io.Reader  // Source of randomness for the keys, or nil for the source of the ElGamal.
+	context       []byte     // Context of the hybrid encryptions set with SetContext, e.g. a session ID.
 }
 
-func (receiver *OTReceiver) Init(selectionBits []byte) {
+// SetRandom sets the source of randomness for the receiver's secret and oblivious keys, e.g. a seeded deterministic
+// stream (utils.NewDeterministicReader) in tests. If it is not set, the source of the ElGamal is used.
+func (receiver *OTReceiver) SetRandom(random io.Reader) {
+	receiver.random = random
+}
+
+// SetContext sets the context that the hybrid encryptions of the messages are bound to, which must be the context of
+// the sender (see OTSender.SetContext).
+func (receiver *OTReceiver) SetContext(context []byte) {
+	receiver.context = context
+}
+
+// Returns an error wrapping ErrInvalidChoiceBit if a selection bit is not 0 or 1.
+func (receiver *OTReceiver) Init(selectionBits []byte) error {
+	if err := utils.ValidateSelectionBits(selectionBits); err != nil {
+		return err
+	}
 	receiver.selectionBits = selectionBits
+	return nil
 }
 
-func (receiver *OTReceiver) Choose(num_selections int, elGamal *elgamal.ElGamal) []*utils.PublicKeyPair {
+func (receiver *OTReceiver) Choose(num_selections int, elGamal *elgamal.ElGamal) ([]*utils.PublicKeyPair, error) {
 
+	if err := utils.ValidateCount("selection bits", len(receiver.selectionBits), num_selections); err != nil {
+		return nil, err
+	}
+
+	elGamal = elGamal.WithRandom(receiver.random)
 	receiver.secretKeys = make([]*big.Int, num_selections)
 
 	// Generate secretkeys for each of the messages to be received
 	for i := 0; i < num_selections; i++ {
-		receiver.secretKeys[i] = elGamal.MakeSecretKey()
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			return nil, err
+		}
+		receiver.secretKeys[i] = sk
+	}
+
+	// Generate the fake public keys with OGen, and the real public keys with Gen as one batch
+	obliviousKeys := make([]*big.Int, num_selections)
+	for i := 0; i < num_selections; i++ {
+		h, err := elGamal.OGen()
+		if err != nil {
+			return nil, err
+		}
+		obliviousKeys[i] = h
 	}
+	realKeys := elGamal.GenBatch(receiver.secretKeys)
 
 	// Initialize a list of num_selections public keys to be sent to the OTSender
 	publicKeys := make([]*utils.PublicKeyPair, num_selections)
@@ -35,50 +76,42 @@ func (receiver *OTReceiver) Choose(num_selections int, elGamal *elgamal.ElGamal)
 
 		// Assign the public keys made from Gen and OGen based on the receiver's selection bits
 		if receiver.selectionBits[i] == 0 {
-			publicKeys[i].MessageKey0 = elGamal.Gen(receiver.secretKeys[i])
-			publicKeys[i].MessageKey1 = elGamal.OGen()
-		} else if receiver.selectionBits[i] == 1 {
-			publicKeys[i].MessageKey0 = elGamal.OGen()
-			publicKeys[i].MessageKey1 = elGamal.Gen(receiver.secretKeys[i])
+			publicKeys[i].MessageKey0 = realKeys[i]
+			publicKeys[i].MessageKey1 = obliviousKeys[i]
 		} else {
-			panic("Receiver selection bits are not 0 or 1 in Choose")
+			publicKeys[i].MessageKey0 = obliviousKeys[i]
+			publicKeys[i].MessageKey1 = realKeys[i]
 		}
 	}
 
-	return publicKeys
+	return publicKeys, nil
 }
 
-func (receiver *OTReceiver) DecryptMessage(ciphertextPairs []*utils.CiphertextPair, l int, elGamal *elgamal.ElGamal) [][]byte {
+// Method to decrypt the hybrid ciphertexts of the messages chosen by the selection bits. The messages are returned
+// exactly as they were sent, whatever their length. Returns an error wrapping
+// ErrDimensionMismatch if the number of ciphertext pairs is wrong, or ErrDecryptionFailed if a ciphertext does not
+// decrypt, e.g. because it was encrypted with another context, together with ErrInvalidElement if its c1 is not a
+// group element or is the identity.
+func (receiver *OTReceiver) DecryptMessage(ciphertextPairs []*utils.HybridCiphertextPair, elGamal *elgamal.ElGamal) ([][]byte, error) {
 
-	// Initialize a list of plaintexts to be decrypted
-	plaintexts := make([][]byte, len(ciphertextPairs))
+	if err := utils.ValidateCount("ciphertextPairs", len(ciphertextPairs), len(receiver.secretKeys)); err != nil {
+		return nil, err
+	}
 
-	// Decrypt the message based on the receiver's selection bits
+	// Select the ciphertexts based on the receiver's selection bits
+	selected := make([]*elgamal.HybridCiphertext, len(ciphertextPairs))
 	for i, pair := range ciphertextPairs {
-		var plaintext *big.Int
+		if pair == nil || pair.Ciphertext0 == nil || pair.Ciphertext1 == nil {
+			return nil, fmt.Errorf("%w: ciphertext pair %d is missing", ErrDimensionMismatch, i)
+		}
 		if receiver.selectionBits[i] == 0 {
-			plaintext = elGamal.Decrypt(pair.Ciphertext0.C1, pair.Ciphertext0.C2, receiver.secretKeys[i])
-		} else if receiver.selectionBits[i] == 1 {
-			plaintext = elGamal.Decrypt(pair.Ciphertext1.C1, pair.Ciphertext1.C2, receiver.secretKeys[i])
+			selected[i] = pair.Ciphertext0
 		} else {
-			panic("Receiver selection bits are not 0 or 1 in DecryptMessage")
+			selected[i] = pair.Ciphertext1
 		}
-
-		bytePlaintext := plaintext.Bytes() // Convert *big.Int to []byte
-
-		// Ensure the byte slice is exactly 'l' bytes long.
-		// Padding/Truncation is needed due to the conversion from *big.Int to []byte, where additional bytes are
-		// added/removed to the byte slice if the number is not exactly 'l' bytes long.
-		if len(bytePlaintext) < l {
-			// Padding at the end
-			padding := make([]byte, l-len(bytePlaintext))
-			bytePlaintext = append(bytePlaintext, padding...)
-		} else if len(bytePlaintext) > l {
-			// Truncate if longer
-			bytePlaintext = bytePlaintext[:l]
-		}
-
-		plaintexts[i] = bytePlaintext
 	}
-	return plaintexts
+
+	// Decrypt the selected ciphertexts as one batch. The messages are byte strings, so nothing is lost in a
+	// conversion to numbers.
+	return elGamal.DecryptHybridBatch(selected, receiver.secretKeys, receiver.context)
 }
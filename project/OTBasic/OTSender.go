@@ -4,49 +4,84 @@ package OTBasic
 import (
 	"cryptographic-computing/project/elgamal"
 	"cryptographic-computing/project/utils"
+	"fmt"
+	"io"
 	"math/big"
 )
 
 type OTSender struct {
 	PublicKeys []*utils.PublicKeyPair // Public keys received from the OTReceiver - one oblivious and one real for each message to be sent
 	Messages   []*utils.MessagePair   // Messages to be sent, each message consists of 2 messages M0 and M1.
+	random     io.Reader              // Source of randomness for the encryptions, or nil for the source of the ElGamal.
+	context    []byte                 // Context of the hybrid encryptions set with SetContext, e.g. a session ID.
 }
 
-func (sender *OTSender) Init(Messages []*utils.MessagePair) {
+// SetRandom sets the source of randomness for the sender's ElGamal encryptions, e.g. a seeded deterministic stream
+// (utils.NewDeterministicReader) in tests. If it is not set, the source of the ElGamal is used.
+func (sender *OTSender) SetRandom(random io.Reader) {
+	sender.random = random
+}
+
+// SetContext sets the context that the hybrid encryptions of the messages are bound to, e.g. the session ID of the
+// protocol that runs the OTs. The receiver must decrypt with the same context (see OTReceiver.SetContext).
+func (sender *OTSender) SetContext(context []byte) {
+	sender.context = context
+}
+
+// Initialize the sender with message pairs. The messages are encrypted with the hybrid mode of ElGamal, so they
+// can have any length, including different lengths within a pair.
+// Returns an error wrapping ErrInvalidMessageLength if a message pair is missing.
+func (sender *OTSender) Init(Messages []*utils.MessagePair) error {
+
+	for j, pair := range Messages {
+		if pair == nil {
+			return fmt.Errorf("%w: message pair %d is missing", ErrInvalidMessageLength, j)
+		}
+	}
 
-	sender.Messages = make([]*utils.MessagePair, len(Messages))
 	sender.PublicKeys = make([]*utils.PublicKeyPair, len(Messages))
 	sender.Messages = Messages
+	return nil
 }
 
-func (sender *OTSender) ReceiveKeys(PublicKeys []*utils.PublicKeyPair) {
+// Returns an error wrapping ErrDimensionMismatch if there is not one pair of public keys per message pair, or
+// ErrInvalidElement if a key is not an element of the group or is the identity, which would reveal the message.
+func (sender *OTSender) ReceiveKeys(PublicKeys []*utils.PublicKeyPair, elGamal *elgamal.ElGamal) error {
+
+	if err := utils.ValidatePublicKeyPairs(PublicKeys, len(sender.Messages), elGamal); err != nil {
+		return err
+	}
 	sender.PublicKeys = PublicKeys
+	return nil
 }
 
-func (sender *OTSender) EncryptMessages(elGamal *elgamal.ElGamal) []*utils.CiphertextPair {
+// Method to encrypt both messages of every pair under the corresponding public keys and the context with the hybrid
+// mode of ElGamal, so the messages are byte strings of any length that the receiver decrypts exactly.
+// Returns an error if the source of randomness fails.
+func (sender *OTSender) EncryptMessages(elGamal *elgamal.ElGamal) ([]*utils.HybridCiphertextPair, error) {
 
-	ciphertexts := make([]*utils.CiphertextPair, len(sender.Messages))
+	elGamal = elGamal.WithRandom(sender.random)
 
+	// List the messages with the public keys received from the OTReceiver.
+	// The sender is oblivious to which message is encrypted using which key.
+	messages := make([][]byte, 0, 2*len(sender.Messages))
+	keys := make([]*big.Int, 0, 2*len(sender.Messages))
 	for i := 0; i < len(sender.Messages); i++ {
+		messages = append(messages, sender.Messages[i].Message0, sender.Messages[i].Message1)
+		keys = append(keys, sender.PublicKeys[i].MessageKey0, sender.PublicKeys[i].MessageKey1)
+	}
 
-		ciphertexts[i] = &utils.CiphertextPair{} // Initialize a ciphertext pair for each message
-
-		// Convert the messages to big integers
-		msg0BigInt := new(big.Int)
-		msg1BigInt := new(big.Int)
-		msg0BigInt.SetBytes(sender.Messages[i].Message0)
-		msg1BigInt.SetBytes(sender.Messages[i].Message1)
-
-		// Encrypt the messages using the public keys received from the OTReceiver.
-		// The sender is oblivious to which message is encrypted using which key.
-		cipher0 := elGamal.Encrypt(msg0BigInt, sender.PublicKeys[i].MessageKey0)
-		cipher1 := elGamal.Encrypt(msg1BigInt, sender.PublicKeys[i].MessageKey1)
-
-		// Store the encrypted messages in the ciphertext pair
-		ciphertexts[i].Ciphertext0 = cipher0
-		ciphertexts[i].Ciphertext1 = cipher1
+	// Encrypt all messages as one batch
+	encrypted, err := elGamal.EncryptHybridBatch(messages, keys, sender.context)
+	if err != nil {
+		return nil, err
 	}
 
-	return ciphertexts
+	// Store the encrypted messages in a ciphertext pair for each message pair
+	ciphertexts := make([]*utils.HybridCiphertextPair, len(sender.Messages))
+	for i := range ciphertexts {
+		ciphertexts[i] = &utils.HybridCiphertextPair{Ciphertext0: encrypted[2*i], Ciphertext1: encrypted[2*i+1]}
+	}
+	return ciphertexts, nil
 
 }
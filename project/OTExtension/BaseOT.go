@@ -0,0 +1,210 @@
+// BaseOT.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/OTBasic"
+	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/utils"
+	"fmt"
+	"math/big"
+)
+
+// BaseOT transfers the k seed pairs of the base OT phase. The roles are reversed: the OTReceiver of the extension
+// is the sender of the base OTs with the seed pairs as messages, and the OTSender is the receiver with the bits of
+// its string s as choice bits. Like the protocols of this package, an implementation runs both parties.
+type BaseOT interface {
+	// Transfer runs one OT per seed pair, where the receiver obtains Seed0 of pair i if choiceBits[i] is 0 and Seed1
+	// if it is 1. The messages are recorded in communication, the receiver's keys (if any) in utils.PhaseBaseOT and
+	// the encrypted seeds in utils.PhaseSeedTransfer, with the directions of the extension. The encryptions of the
+	// seeds are bound to the session ID, so seeds replayed from another session are not recovered, unless the
+	// session ID is nil.
+	Transfer(sessionID []byte, choiceBits []byte, seeds []*utils.Seed, communication *utils.Communication) ([][]byte, error)
+}
+
+// ElGamalBaseOT is the base OT of the original protocol: the receiver sends a real ElGamal public key for its choice
+// bit and an oblivious key from OGen for the other one, and the sender encrypts both seeds.
+type ElGamalBaseOT struct {
+	elGamal elgamal.ElGamal
+}
+
+// NewElGamalBaseOT creates the ElGamal base OT in the group of elGamal, drawing both parties' randomness from it.
+func NewElGamalBaseOT(elGamal elgamal.ElGamal) *ElGamalBaseOT {
+	return &ElGamalBaseOT{elGamal: elGamal}
+}
+
+// Transfer runs the k base OTs with one public key pair and one ciphertext pair per seed pair. The seeds are masked
+// with pads derived from the session ID and the public keys of their base OT before they are encrypted.
+func (baseOT *ElGamalBaseOT) Transfer(sessionID []byte, choiceBits []byte, seeds []*utils.Seed, communication *utils.Communication) ([][]byte, error) {
+	if err := validateBaseOTInputs(choiceBits, seeds); err != nil {
+		return nil, err
+	}
+	secretKeys, publicKeys, err := elGamalBaseOTKeys(&baseOT.elGamal, choiceBits)
+	if err != nil {
+		return nil, err
+	}
+	communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, utils.PublicKeyPairsWireLength(publicKeys))
+	if err := utils.ValidatePublicKeyPairs(publicKeys, len(seeds), &baseOT.elGamal); err != nil {
+		return nil, err
+	}
+	ciphertextPairs, err := elGamalBaseOTEncrypt(&baseOT.elGamal, sessionID, publicKeys, seeds)
+	if err != nil {
+		return nil, err
+	}
+	communication.Record(utils.PhaseSeedTransfer, utils.ReceiverToSender, utils.CiphertextPairsWireLength(ciphertextPairs))
+	return elGamalBaseOTDecrypt(&baseOT.elGamal, sessionID, choiceBits, secretKeys, publicKeys, ciphertextPairs, len(seeds[0].Seed0))
+}
+
+// OTBasicBaseOT runs the base OTs with OTBasic.OTBasicProtocol, with the seeds as messages.
+type OTBasicBaseOT struct {
+	elGamal elgamal.ElGamal
+}
+
+// NewOTBasicBaseOT creates the base OT of the OTBasic package in the group of elGamal.
+func NewOTBasicBaseOT(elGamal elgamal.ElGamal) *OTBasicBaseOT {
+	return &OTBasicBaseOT{elGamal: elGamal}
+}
+
+// Transfer runs OTBasic.OTBasicProtocolWithContext on the seed pairs, with the session ID as the context of the
+// hybrid encryptions. Its public keys are accounted to utils.PhaseBaseOT and its ciphertexts to
+// utils.PhaseSeedTransfer, in the directions of the extension.
+func (baseOT *OTBasicBaseOT) Transfer(sessionID []byte, choiceBits []byte, seeds []*utils.Seed, communication *utils.Communication) ([][]byte, error) {
+	if err := validateBaseOTInputs(choiceBits, seeds); err != nil {
+		return nil, err
+	}
+	messages := make([]*utils.MessagePair, len(seeds))
+	for i, seed := range seeds {
+		messages[i] = &utils.MessagePair{Message0: seed.Seed0, Message1: seed.Seed1}
+	}
+	received, basicCommunication, err := OTBasic.OTBasicProtocolWithContext(len(seeds), choiceBits, messages, sessionID, baseOT.elGamal)
+	if err != nil {
+		return nil, err
+	}
+	communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, basicCommunication.Phases[utils.PhaseBaseOT].ReceiverToSender)
+	communication.Record(utils.PhaseSeedTransfer, utils.ReceiverToSender, basicCommunication.Phases[utils.PhaseCiphertexts].SenderToReceiver)
+	return received, nil
+}
+
+// validateBaseOTInputs checks that there is one choice bit per seed pair, and that all seeds have the same non-zero
+// length.
+func validateBaseOTInputs(choiceBits []byte, seeds []*utils.Seed) error {
+	if err := utils.ValidateCount("choiceBits", len(choiceBits), len(seeds)); err != nil {
+		return err
+	}
+	if len(seeds) == 0 {
+		return fmt.Errorf("%w: no seed pairs to transfer", ErrDimensionMismatch)
+	}
+	for i, seed := range seeds {
+		if seed == nil || len(seed.Seed0) == 0 || len(seed.Seed0) != len(seeds[0].Seed0) || len(seed.Seed1) != len(seeds[0].Seed0) {
+			return fmt.Errorf("%w: seed pair %d does not have %d-byte seeds", ErrInvalidMessageLength, i, len(seeds[0].Seed0))
+		}
+	}
+	return utils.ValidateSelectionBits(choiceBits)
+}
+
+// elGamalBaseOTKeys makes a secret key for every base OT, and a pair of public keys with the real key for the
+// choice bit and an oblivious key from OGen for the other bit. The real keys are generated as a batch.
+// Returns an error if the source of randomness fails.
+func elGamalBaseOTKeys(elGamal *elgamal.ElGamal, choiceBits []byte) ([]*big.Int, []*utils.PublicKeyPair, error) {
+	secretKeys := make([]*big.Int, len(choiceBits))
+	for i := range secretKeys {
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		secretKeys[i] = sk
+	}
+	obliviousKeys := make([]*big.Int, len(choiceBits))
+	for i := range obliviousKeys {
+		h, err := elGamal.OGen()
+		if err != nil {
+			return nil, nil, err
+		}
+		obliviousKeys[i] = h
+	}
+	realKeys := elGamal.GenBatch(secretKeys)
+
+	publicKeys := make([]*utils.PublicKeyPair, len(choiceBits))
+	for i, bit := range choiceBits {
+		publicKeys[i] = &utils.PublicKeyPair{}
+		if bit == 0 {
+			publicKeys[i].MessageKey0 = realKeys[i]
+			publicKeys[i].MessageKey1 = obliviousKeys[i]
+		} else {
+			publicKeys[i].MessageKey0 = obliviousKeys[i]
+			publicKeys[i].MessageKey1 = realKeys[i]
+		}
+	}
+	return secretKeys, publicKeys, nil
+}
+
+// elGamalBaseOTEncrypt encrypts both seeds of every pair under the corresponding public keys, as one batch, after
+// binding them to the session ID and the keys (see bindSeed).
+// Returns an error if the source of randomness fails.
+func elGamalBaseOTEncrypt(elGamal *elgamal.ElGamal, sessionID []byte, publicKeys []*utils.PublicKeyPair, seeds []*utils.Seed) ([]*utils.CiphertextPair, error) {
+	messages := make([]*big.Int, 0, 2*len(seeds))
+	keys := make([]*big.Int, 0, 2*len(seeds))
+	for i, seed := range seeds {
+		seed0 := bindSeed(elGamal, sessionID, i, 0, publicKeys[i], seed.Seed0)
+		seed1 := bindSeed(elGamal, sessionID, i, 1, publicKeys[i], seed.Seed1)
+		messages = append(messages, utils.SeedToInt(seed0), utils.SeedToInt(seed1))
+		keys = append(keys, publicKeys[i].MessageKey0, publicKeys[i].MessageKey1)
+	}
+	ciphertexts, err := elGamal.EncryptBatch(messages, keys)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertextPairs := make([]*utils.CiphertextPair, len(seeds))
+	for i := range ciphertextPairs {
+		ciphertextPairs[i] = &utils.CiphertextPair{Ciphertext0: ciphertexts[2*i], Ciphertext1: ciphertexts[2*i+1]}
+	}
+	return ciphertextPairs, nil
+}
+
+// elGamalBaseOTDecrypt decrypts the ciphertext of the choice bit of every pair with its secret key, as one batch,
+// and restores the seed of seedLength bytes, unbinding it with the session ID and the receiver's public keys.
+// Returns an error wrapping ErrDimensionMismatch if a ciphertext is missing or a decrypted seed is too long, which is
+// the case for a ciphertext encrypted under another key, or ErrInvalidElement if the c1 of a chosen ciphertext is not
+// a group element or is the identity.
+func elGamalBaseOTDecrypt(elGamal *elgamal.ElGamal, sessionID []byte, choiceBits []byte, secretKeys []*big.Int, publicKeys []*utils.PublicKeyPair, ciphertextPairs []*utils.CiphertextPair, seedLength int) ([][]byte, error) {
+	if err := utils.ValidateCount("ciphertextPairs", len(ciphertextPairs), len(choiceBits)); err != nil {
+		return nil, err
+	}
+	ciphertexts := make([]*elgamal.Ciphertext, len(ciphertextPairs))
+	for i, pair := range ciphertextPairs {
+		if pair == nil || pair.Ciphertext0 == nil || pair.Ciphertext1 == nil {
+			return nil, fmt.Errorf("%w: ciphertext pair %d is missing", ErrDimensionMismatch, i)
+		}
+		ciphertexts[i] = pair.Ciphertext0
+		if choiceBits[i] == 1 {
+			ciphertexts[i] = pair.Ciphertext1
+		}
+		if err := elGamal.ValidateElement(ciphertexts[i].C1); err != nil {
+			return nil, fmt.Errorf("ciphertext pair %d: %w", i, err)
+		}
+	}
+	plaintexts := elGamal.DecryptBatch(ciphertexts, secretKeys)
+
+	seeds := make([][]byte, len(plaintexts))
+	for i, plaintext := range plaintexts {
+		// Restore the seed from the decrypted big int.
+		seed, err := utils.SeedFromInt(plaintext, seedLength)
+		if err != nil {
+			return nil, fmt.Errorf("%w: seed %d: %v", ErrDimensionMismatch, i, err)
+		}
+		seeds[i] = bindSeed(elGamal, sessionID, i, choiceBits[i], publicKeys[i], seed)
+	}
+	return seeds, nil
+}
+
+// bindSeed masks or unmasks seed b of base OT i with a pad derived from the session ID and the public key pair of
+// the base OT, so the ElGamal ciphertexts of the seeds are bound to the keys and the session in which they were
+// sent. Without a session ID the seed is encrypted as it is.
+func bindSeed(elGamal *elgamal.ElGamal, sessionID []byte, i int, b byte, keys *utils.PublicKeyPair, seed []byte) []byte {
+	if sessionID == nil {
+		return seed
+	}
+	group := elGamal.Group()
+	encodedKeys := append(group.MarshalElement(keys.MessageKey0), group.MarshalElement(keys.MessageKey1)...)
+	return utils.SessionBindSeed(sessionID, i, b, encodedKeys, seed)
+}
@@ -0,0 +1,60 @@
+// CorrelationCheck.go
+package OTExtension
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"cryptographic-computing/project/utils"
+	"encoding/binary"
+	"errors"
+)
+
+// Statistical security parameter s of the KOS consistency check. The receiver extends k + s extra OTs
+// with random selection bits, which are sacrificed to hide its real selection bits in the check.
+const StatisticalSecurity = 64
+
+// ErrCorrelationCheckFailed is returned by the OTSender when the receiver's U matrix is not consistent
+// with a single vector of selection bits, e.g. because a malicious receiver tries to learn bits of s.
+var ErrCorrelationCheckFailed = errors.New("OTExtension: KOS correlation check failed")
+
+// ErrCommitmentMismatch is returned by the OTReceiver when the sender's opened challenge seed
+// does not match the commitment it sent before seeing the receiver's seed.
+var ErrCommitmentMismatch = errors.New("OTExtension: challenge seed does not match commitment")
+
+// Length in bytes of the seeds used for coin tossing the challenge of the correlation check.
+const challengeSeedLength = 32
+
+// challengeGenerator expands the coin-tossed seeds of both parties into the challenges χ_1, ..., χ_m' ∈ GF(2^k).
+type challengeGenerator struct {
+	stream cipher.Stream
+	buffer []byte
+	k      int
+}
+
+// newChallengeGenerator derives an AES-CTR key from H(seedSender || seedReceiver), so that neither party
+// alone controls the challenges.
+func newChallengeGenerator(seedSender []byte, seedReceiver []byte, k int) (*challengeGenerator, error) {
+	key := sha256.Sum256(append(append([]byte{}, seedSender...), seedReceiver...))
+	block, err := aes.NewCipher(key[:16])
+	if err != nil {
+		return nil, err
+	}
+	return &challengeGenerator{
+		stream: cipher.NewCTR(block, make([]byte, aes.BlockSize)),
+		buffer: make([]byte, 8*utils.WordsFor(k)),
+		k:      k,
+	}, nil
+}
+
+// next writes the next challenge χ_j into chi.
+func (generator *challengeGenerator) next(chi []uint64) {
+	for i := range generator.buffer {
+		generator.buffer[i] = 0
+	}
+	generator.stream.XORKeyStream(generator.buffer, generator.buffer)
+	for i := range chi {
+		chi[i] = binary.LittleEndian.Uint64(generator.buffer[8*i:])
+	}
+	utils.ClearPadding(chi, generator.k)
+}
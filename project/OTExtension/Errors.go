@@ -0,0 +1,61 @@
+// Errors.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/utils"
+	"fmt"
+)
+
+// Errors returned for invalid inputs. They are shared with the OTBasic package, so errors.Is works for both.
+// Errors caused by the other party (e.g. a U matrix or ciphertexts of the wrong size) wrap ErrDimensionMismatch
+// or ErrInvalidMessageLength as well, so a service can reject a single request without crashing.
+var (
+	ErrInvalidChoiceBit         = utils.ErrInvalidChoiceBit
+	ErrDimensionMismatch        = utils.ErrDimensionMismatch
+	ErrInvalidSecurityParameter = utils.ErrInvalidSecurityParameter
+	ErrInvalidMessageLength     = utils.ErrInvalidMessageLength
+	ErrInvalidParameter         = utils.ErrInvalidParameter
+	ErrInvalidElement           = elgamal.ErrInvalidElement
+)
+
+// validateParameters checks the public parameters k, l and m shared by both parties.
+func validateParameters(k int, l int, m int) error {
+	if err := utils.ValidateSecurityParameter(k); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return err
+	}
+	if m <= 0 {
+		return fmt.Errorf("%w: m must be positive, got %d", ErrInvalidParameter, m)
+	}
+	return nil
+}
+
+// validateOffset checks that the PRG offset of a chunk or batch is a non-negative multiple of 64.
+func validateOffset(offset int) error {
+	if offset < 0 || offset%64 != 0 {
+		return fmt.Errorf("%w: offset must be a non-negative multiple of 64, got %d", ErrInvalidParameter, offset)
+	}
+	return nil
+}
+
+// validateMatrix checks that a bit matrix received from the other party has the expected size.
+func validateMatrix(name string, matrix *utils.BitMatrix, rows int, cols int) error {
+	if matrix == nil {
+		return fmt.Errorf("%w: %s is missing", ErrDimensionMismatch, name)
+	}
+	if matrix.Rows != rows || matrix.Cols != cols || matrix.RowWords != utils.WordsFor(cols) || len(matrix.Words) != rows*matrix.RowWords {
+		return fmt.Errorf("%w: %s is %d × %d, expected %d × %d", ErrDimensionMismatch, name, matrix.Rows, matrix.Cols, rows, cols)
+	}
+	return nil
+}
+
+// validateN checks that the number of messages N of a 1-out-of-N OT is between 2 and WalshHadamardLength.
+func validateN(n int) error {
+	if n < 2 || n > WalshHadamardLength {
+		return fmt.Errorf("%w: N must be between 2 and %d, got %d", ErrInvalidParameter, WalshHadamardLength, n)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Hash.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/utils"
+	"fmt"
+)
+
+// HashFunction selects the correlation-robust hash H used to turn the rows of Q and T into pads for the messages.
+type HashFunction int
+
+const (
+	HashSHA256      HashFunction = iota // H(q_j) with SHA-256 (utils.Hash), as in the original protocol. The default.
+	HashFixedKeyAES                     // H(j, q_j) with tweakable fixed-key AES (utils.CRHash), keyed per session.
+)
+
+// Length in bytes of the fixed AES key of HashFixedKeyAES.
+const hashKeyLength = 16
+
+// Method for choosing the hash function. For HashFixedKeyAES the sender chooses a random AES key for the session,
+// which is returned and must be sent to the OTReceiver. For HashSHA256 nil is returned and nothing has to be sent.
+// The hash function may be chosen before or after the session ID is negotiated; the AES key used is derived from
+// the chosen key and the session ID as soon as both are known.
+// Returns an error wrapping ErrInvalidParameter for an unknown hash function.
+func (sender *OTSender) ChooseHashFunction(hash HashFunction) ([]byte, error) {
+
+	if err := validateHashFunction(hash); err != nil {
+		return nil, err
+	}
+	if hash == HashSHA256 {
+		sender.crHash = nil
+		sender.hashKey = nil
+		return nil, nil
+	}
+
+	key, err := utils.RandomSeedFrom(sender.random, hashKeyLength)
+	if err != nil {
+		return nil, err
+	}
+	sender.crHash, err = sessionCRHash(key, sender.sessionID)
+	if err != nil {
+		return nil, err
+	}
+	sender.hashKey = key
+	return key, nil // Send the key to the OTReceiver
+}
+
+// Method for receiving the hash function chosen by the OTSender, together with the key returned by ChooseHashFunction.
+// Returns an error wrapping ErrInvalidParameter for an unknown hash function or a key of the wrong length.
+func (receiver *OTReceiver) ReceiveHashFunction(hash HashFunction, key []byte) error {
+
+	if err := validateHashFunction(hash); err != nil {
+		return err
+	}
+	if hash == HashSHA256 {
+		receiver.crHash = nil
+		receiver.hashKey = nil
+		return nil
+	}
+
+	crHash, err := sessionCRHash(key, receiver.sessionID)
+	if err != nil {
+		return err
+	}
+	receiver.crHash = crHash
+	receiver.hashKey = key
+	return nil
+}
+
+// validateHashFunction checks that hash is one of the supported hash functions.
+func validateHashFunction(hash HashFunction) error {
+	if hash != HashSHA256 && hash != HashFixedKeyAES {
+		return fmt.Errorf("%w: unknown hash function %d", ErrInvalidParameter, hash)
+	}
+	return nil
+}
+
+// hashRow hashes the row of Q or T for OT number j (counted from the start of the session, so chunks and batches
+// use distinct tweaks) to l bits. Without a CRHash, the session ID and the row are hashed with SHA-256 and j is
+// not used. A CRHash is already keyed for the session (see sessionCRHash).
+func hashRow(crHash *utils.CRHash, sessionID []byte, j int, row []uint64, l int) []byte {
+	if crHash == nil {
+		return utils.Hash(append(append([]byte{}, sessionID...), utils.WordsToBytes(row)...), l)
+	}
+	return crHash.Hash(uint64(j), row, l)
+}
@@ -2,118 +2,626 @@ package OTExtension
 
 import (
 	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/parallel"
 	"cryptographic-computing/project/utils"
 	"fmt"
+	"io"
 )
 
-func OTExtensionProtocol(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal) [][]byte {
-	receiver := OTReceiver{}
-	sender := OTSender{}
+// setMultithreaded lets both parties use parallel.DefaultWorkers goroutines for PRG expansion, transposition and
+// hashing if multithreaded is true, and a single goroutine otherwise.
+func setMultithreaded(sender *OTSender, receiver *OTReceiver, multithreaded bool) {
+	workers := 1
+	if multithreaded {
+		workers = parallel.DefaultWorkers()
+	}
+	sender.SetWorkers(workers)
+	receiver.SetWorkers(workers)
+}
 
-	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's messages
-	receiver.Init(selectionBits, k, l)
-	sender.Init(messages, k, l)
+// validateInputs checks the public parameters and that there are m selection bits and m message pairs.
+// The values of the selection bits and the lengths of the messages are checked by the parties' Init methods.
+func validateInputs(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair) error {
+	if err := validateParameters(k, l, m); err != nil {
+		return err
+	}
+	if err := utils.ValidateCount("selectionBits", len(selectionBits), m); err != nil {
+		return err
+	}
+	return utils.ValidateCount("messages", len(messages), m)
+}
+
+// validateVariableInputs checks k, m and the number of selection bits and message pairs of OTExtensionProtocol,
+// where every pair has its own length instead of a common l.
+func validateVariableInputs(k int, m int, selectionBits []byte, messages []*utils.MessagePair) error {
+	if err := utils.ValidateSecurityParameter(k); err != nil {
+		return err
+	}
+	if m <= 0 {
+		return fmt.Errorf("%w: m must be positive, got %d", ErrInvalidParameter, m)
+	}
+	if err := utils.ValidateCount("selectionBits", len(selectionBits), m); err != nil {
+		return err
+	}
+	return utils.ValidateCount("messages", len(messages), m)
+}
+
+// initParties initializes both parties with the receiver's selection bits and the sender's messages.
+func initParties(sender *OTSender, receiver *OTReceiver, k int, l int, selectionBits []byte, messages []*utils.MessagePair) error {
+	if err := receiver.Init(selectionBits, k, l); err != nil {
+		return err
+	}
+	return sender.Init(messages, k, l)
+}
+
+// baseOTPhase runs the initial phase shared by all the OTExtension protocols, and negotiates the session ID.
+// The base OTs are run with baseOT, and the shares of the session ID are sent along with its first message in each
+// direction, so they add bytes but no rounds to communication.
+func baseOTPhase(sender *OTSender, receiver *OTReceiver, baseOT BaseOT, communication *utils.Communication) error {
 
 	// Sender choose random string S. Receiver chooses k random seeds. All of length k.
-	sender.ChooseRandomS()
-	receiver.ChooseSeeds()
+	if err := sender.ChooseRandomS(); err != nil {
+		return err
+	}
+	if err := receiver.ChooseSeeds(); err != nil {
+		return err
+	}
+
+	// The parties exchange their shares of the session ID (see Session.go).
+	senderShare, err := sender.StartSession()
+	if err != nil {
+		return err
+	}
+	receiverShare, err := receiver.JoinSession(senderShare)
+	if err != nil {
+		return err
+	}
 
 	// The parties invoke the regular OT functionality k times (Sender plays receiver and receiver plays sender).
-	// Sender chooses a secret keys and public keys for each message, and sends public keys to original receiver, .
-	// Receiver chooses seeds and sends to original sender.
-	publicKeys := sender.Choose(&elGamal)
-	receiver.ReceiveKeys(publicKeys)
-	seedCiphertexts := receiver.EncryptSeeds(&elGamal)
-	sender.DecryptSeeds(seedCiphertexts, &elGamal)
+	// The receiver sends its seed pairs, and the sender chooses the seeds with the bits of s.
+	seeds, err := baseOT.Transfer(receiver.SessionID(), sender.BaseOTChoiceBits(), receiver.BaseOTSeeds(), communication)
+	if err != nil {
+		return err
+	}
+	communication.Piggyback(utils.PhaseBaseOT, utils.SenderToReceiver, utils.SessionShareWireLength())
+	communication.Piggyback(utils.PhaseSeedTransfer, utils.ReceiverToSender, utils.SessionShareWireLength())
+	if err := sender.CompleteSession(receiverShare); err != nil {
+		return err
+	}
+	return sender.ReceiveBaseOTSeeds(seeds)
+}
+
+// byteCiphertextTuplesWireLength returns the length of a message holding the ciphertext tuples of the 1-out-of-N
+// OTs, for the communication accounting.
+func byteCiphertextTuplesWireLength(tuples []*utils.ByteCiphertextTuple) int {
+	var lengths []int
+	for _, tuple := range tuples {
+		for _, y := range tuple.Y {
+			lengths = append(lengths, len(y))
+		}
+	}
+	return utils.ByteStringsWireLength(lengths...)
+}
+
+// All protocols draw the randomness of both parties from the source of elGamal (see elgamal.ElGamal.SetRandom),
+// which is crypto/rand unless a test sets a deterministic stream to reproduce a run.
+// All protocols validate k (128, 192 or 256), l, m, the selection bits and the message lengths before running,
+// and return an error wrapping ErrInvalidSecurityParameter, ErrInvalidMessageLength, ErrInvalidParameter,
+// ErrInvalidChoiceBit or ErrDimensionMismatch instead of panicking.
+// Along with the result, the protocols return the bytes sent in each direction and the rounds of each phase.
+//
+// OTExtensionProtocol takes the lengths from the message pairs instead of a common l: pair j may hold messages of
+// any lengths, including empty ones and x0_j and x1_j of different lengths. Both messages of a pair are padded to
+// the longer one, so the receiver learns the length of its message and of the pair, but not of the other message.
+func OTExtensionProtocol(k int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal) ([][]byte, *utils.Communication, error) {
+	if err := validateVariableInputs(k, m, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
+
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: elGamal.Random()}
+	sender := OTSender{random: elGamal.Random()}
+
+	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's messages
+	if err := receiver.InitVariableLengths(selectionBits, k); err != nil {
+		return nil, nil, err
+	}
+	if err := sender.InitVariableLengths(messages, k); err != nil {
+		return nil, nil, err
+	}
+
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, nil, err
+	}
 
 	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
 	// The sender generates the Matrix Q from the received U Matrix.
-	U := receiver.GenerateMatrixTAndU()
-	sender.GenerateMatrixQ(U)
+	U, err := receiver.GenerateMatrixTAndU()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQ(U); err != nil {
+		return nil, nil, err
+	}
 
 	// The sender sends m ciphertext pairs to the receiver.
 	// The receiver computes the desired message based on the selection bits.
-	ByteCiphertexts := sender.MakeAndSendCiphertexts()
-	result := receiver.DecryptCiphertexts(ByteCiphertexts)
+	ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ByteCiphertexts))
+	plaintexts, err := receiver.DecryptCiphertexts(ByteCiphertexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintexts, communication, nil
+}
+
+func OTExtensionProtocolTranspose(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal) ([][]byte, *utils.Communication, error) {
+	if err := validateInputs(k, l, m, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
+
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: elGamal.Random()}
+	sender := OTSender{random: elGamal.Random()}
+
+	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's messages
+	if err := initParties(&sender, &receiver, k, l, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
 
-	if len(result) != len(messages) {
-		fmt.Println("Result length is not equal to messages length in OTExtensionProtocol")
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, nil, err
 	}
 
-	return result
+	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
+	// The sender generates the Matrix Q from the received U Matrix.
+	U, err := receiver.GenerateMatrixTAndUTranspose()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQTranspose(U); err != nil {
+		return nil, nil, err
+	}
+
+	// The sender sends m ciphertext pairs to the receiver.
+	// The receiver computes the desired message based on the selection bits.
+	ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ByteCiphertexts))
+	plaintexts, err := receiver.DecryptCiphertexts(ByteCiphertexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintexts, communication, nil
+}
+
+func OTExtensionProtocolEklundh(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal, multithreaded bool) ([][]byte, *utils.Communication, error) {
+	return OTExtensionProtocolEklundhHash(k, l, m, selectionBits, messages, elGamal, multithreaded, HashSHA256)
 }
 
-func OTExtensionProtocolTranspose(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal) [][]byte {
-	receiver := OTReceiver{}
-	sender := OTSender{}
+// OTExtension protocol with Eklundh transposes, where the rows of Q and T are hashed with the given hash function.
+// With HashFixedKeyAES, the sender chooses the key of the tweakable fixed-key AES hash and sends it to the receiver.
+func OTExtensionProtocolEklundhHash(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal, multithreaded bool, hash HashFunction) ([][]byte, *utils.Communication, error) {
+	return otExtensionEklundh(k, l, m, selectionBits, messages, NewElGamalBaseOT(elGamal), elGamal.Random(), multithreaded, hash)
+}
+
+// OTExtension protocol with Eklundh transposes, where the k base OTs are run with baseOT, e.g. an ElGamalBaseOT or
+// an OTBasicBaseOT, so different base OTs can be compared. The parties draw their randomness from crypto/rand.
+func OTExtensionProtocolBaseOT(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, baseOT BaseOT, multithreaded bool) ([][]byte, *utils.Communication, error) {
+	return otExtensionEklundh(k, l, m, selectionBits, messages, baseOT, nil, multithreaded, HashSHA256)
+}
+
+// otExtensionEklundh runs the OTExtension protocol with Eklundh transposes, the given base OT and hash function, where
+// both parties draw their randomness from random.
+func otExtensionEklundh(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, baseOT BaseOT, random io.Reader, multithreaded bool, hash HashFunction) ([][]byte, *utils.Communication, error) {
+	if err := validateInputs(k, l, m, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
+
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: random}
+	sender := OTSender{random: random}
 
 	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's messages
-	receiver.Init(selectionBits, k, l)
-	sender.Init(messages, k, l)
+	if err := initParties(&sender, &receiver, k, l, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
+	setMultithreaded(&sender, &receiver, multithreaded)
 
-	// Sender choose random string S. Receiver chooses k random seeds. All of length k.
-	sender.ChooseRandomS()
-	receiver.ChooseSeeds()
+	// Sender chooses the hash function, and sends the key of the fixed-key AES hash (if any) to the receiver.
+	hashKey, err := sender.ChooseHashFunction(hash)
+	if err != nil {
+		return nil, nil, err
+	}
+	if hashKey != nil {
+		communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, utils.ByteStringsWireLength(len(hashKey)))
+	}
+	if err := receiver.ReceiveHashFunction(hash, hashKey); err != nil {
+		return nil, nil, err
+	}
 
-	// The parties invoke the regular OT functionality k times (Sender plays receiver and receiver plays sender).
-	// Sender chooses a secret keys and public keys for each message, and sends public keys to original receiver, .
-	// Receiver chooses seeds and sends to original sender.
-	publicKeys := sender.Choose(&elGamal)
-	receiver.ReceiveKeys(publicKeys)
-	seedCiphertexts := receiver.EncryptSeeds(&elGamal)
-	sender.DecryptSeeds(seedCiphertexts, &elGamal)
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, baseOT, communication); err != nil {
+		return nil, nil, err
+	}
 
 	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
 	// The sender generates the Matrix Q from the received U Matrix.
-	U := receiver.GenerateMatrixTAndUTranspose()
-	sender.GenerateMatrixQTranspose(U)
+	U, err := receiver.GenerateMatrixTAndUEklundh(multithreaded)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, multithreaded); err != nil {
+		return nil, nil, err
+	}
 
 	// The sender sends m ciphertext pairs to the receiver.
 	// The receiver computes the desired message based on the selection bits.
-	ByteCiphertexts := sender.MakeAndSendCiphertexts()
-	result := receiver.DecryptCiphertexts(ByteCiphertexts)
+	ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ByteCiphertexts))
+	plaintexts, err := receiver.DecryptCiphertexts(ByteCiphertexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintexts, communication, nil
+}
+
+// OTExtensionProtocolKOS is the actively secure variant of OTExtensionProtocolEklundh following KOS15.
+// The receiver extends k + s extra OTs, and after sending U it proves in a correlation check, driven by a coin-tossed
+// challenge, that U is consistent with a single vector of selection bits. The protocol returns
+// ErrCorrelationCheckFailed instead of sending any ciphertexts if a (malicious) receiver sends an inconsistent U.
+// k must be 128, 192 or 256, since the check is computed in GF(2^k).
+func OTExtensionProtocolKOS(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal, multithreaded bool) ([][]byte, *utils.Communication, error) {
+	if err := validateInputs(k, l, m, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
 
-	if len(result) != len(messages) {
-		fmt.Println("Result length is not equal to messages length in OTExtensionProtocol")
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: elGamal.Random()}
+	sender := OTSender{random: elGamal.Random()}
+
+	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's messages.
+	// Both parties extend k + s extra OTs for the correlation check.
+	if err := initParties(&sender, &receiver, k, l, selectionBits, messages); err != nil {
+		return nil, nil, err
+	}
+	setMultithreaded(&sender, &receiver, multithreaded)
+	if err := receiver.AddCheckOTs(); err != nil {
+		return nil, nil, err
 	}
+	sender.AddCheckOTs()
 
-	return result
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, nil, err
+	}
+
+	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
+	// The sender generates the Matrix Q from the received U Matrix.
+	U, err := receiver.GenerateMatrixTAndUEklundh(multithreaded)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, multithreaded); err != nil {
+		return nil, nil, err
+	}
+
+	// The parties toss coins for the challenge seed. The sender commits to its seed before seeing the receiver's seed.
+	commitment, err := sender.CommitChallengeSeed()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.SenderToReceiver, utils.ChallengeCommitmentWireLength(commitment))
+	seedReceiver, err := receiver.ChooseChallengeSeed(commitment)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.ChallengeSeedWireLength(seedReceiver))
+	seedSender, err := sender.OpenChallengeSeed(seedReceiver)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.SenderToReceiver, utils.ChallengeSeedWireLength(seedSender))
+	if err := receiver.ReceiveChallengeSeed(seedSender); err != nil {
+		return nil, nil, err
+	}
+
+	// The receiver proves that U is consistent, and the sender aborts if the check fails.
+	check, err := receiver.MakeCorrelationCheck()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.CorrelationCheckWireLength(check))
+	if err := sender.VerifyCorrelationCheck(check); err != nil {
+		return nil, nil, err
+	}
+
+	// The sender sends m ciphertext pairs to the receiver. The extra OTs of the check are discarded.
+	// The receiver computes the desired message based on the selection bits.
+	ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ByteCiphertexts))
+	plaintexts, err := receiver.DecryptCiphertexts(ByteCiphertexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintexts, communication, nil
 }
 
-func OTExtensionProtocolEklundh(k int, l int, m int, selectionBits []byte, messages []*utils.MessagePair, elGamal elgamal.ElGamal, multithreaded bool) [][]byte {
-	receiver := OTReceiver{}
-	sender := OTSender{}
+// OTExtensionProtocolCorrelated runs m correlated OTs with the global correlation Δ (delta) of l bits.
+// The sender gets m random pairs (x0_j, x1_j) with x1_j = x0_j ⊕ Δ, and the receiver gets x^(r_j)_j for its
+// selection bits r. Only one masked string per OT is sent in the final phase, which halves the communication
+// compared to OTExtensionProtocolEklundh. Returns the sender's pairs and the receiver's messages.
+func OTExtensionProtocolCorrelated(k int, l int, m int, selectionBits []byte, delta []byte, elGamal elgamal.ElGamal, multithreaded bool) ([]*utils.MessagePair, [][]byte, *utils.Communication, error) {
+	if err := validateParameters(k, l, m); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := utils.ValidateCount("selectionBits", len(selectionBits), m); err != nil {
+		return nil, nil, nil, err
+	}
 
-	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's messages
-	receiver.Init(selectionBits, k, l)
-	sender.Init(messages, k, l)
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: elGamal.Random()}
+	sender := OTSender{random: elGamal.Random()}
 
-	// Sender choose random string S. Receiver chooses k random seeds. All of length k.
-	sender.ChooseRandomS()
-	receiver.ChooseSeeds()
+	// Initialize public parameters for both parties, the receiver's selection bits, and the sender's correlation Δ
+	if err := receiver.Init(selectionBits, k, l); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := sender.InitCorrelated(delta, m, k, l); err != nil {
+		return nil, nil, nil, err
+	}
+	setMultithreaded(&sender, &receiver, multithreaded)
 
-	// The parties invoke the regular OT functionality k times (Sender plays receiver and receiver plays sender).
-	// Sender chooses a secret keys and public keys for each message, and sends public keys to original receiver, .
-	// Receiver chooses seeds and sends to original sender.
-	publicKeys := sender.Choose(&elGamal)
-	receiver.ReceiveKeys(publicKeys)
-	seedCiphertexts := receiver.EncryptSeeds(&elGamal)
-	sender.DecryptSeeds(seedCiphertexts, &elGamal)
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, nil, nil, err
+	}
 
 	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
 	// The sender generates the Matrix Q from the received U Matrix.
-	U := receiver.GenerateMatrixTAndUEklundh(false)
-	sender.GenerateMatrixQEklundh(U, false)
+	U, err := receiver.GenerateMatrixTAndUEklundh(multithreaded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, multithreaded); err != nil {
+		return nil, nil, nil, err
+	}
 
-	// The sender sends m ciphertext pairs to the receiver.
-	// The receiver computes the desired message based on the selection bits.
-	ByteCiphertexts := sender.MakeAndSendCiphertexts()
-	result := receiver.DecryptCiphertexts(ByteCiphertexts)
+	// The sender sends one masked string per OT to the receiver, and keeps the correlated pairs as output.
+	// The receiver computes its message based on the selection bits.
+	ciphertexts, err := sender.MakeAndSendCorrelatedCiphertexts()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.CorrelatedCiphertextsWireLength(ciphertexts))
+	result, err := receiver.DecryptCorrelatedCiphertexts(ciphertexts)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return sender.Messages(), result, communication, nil
+}
 
-	if len(result) != len(messages) {
-		fmt.Println("Result length is not equal to messages length in OTExtensionProtocol")
+// OTExtensionProtocolRandom runs m random OTs of l-bit strings, where neither party supplies inputs.
+// The sender gets random pairs (H(q_j), H(q_j ⊕ s)), and the receiver gets random choice bits c_j and H(t_j),
+// without a final ciphertext round. The outputs can be stored and derandomized into chosen-message OTs with
+// OTDerandomizeProtocol once the inputs are known.
+func OTExtensionProtocolRandom(k int, l int, m int, elGamal elgamal.ElGamal, multithreaded bool) (*RandomOTSender, *RandomOTReceiver, *utils.Communication, error) {
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: elGamal.Random()}
+	sender := OTSender{random: elGamal.Random()}
+
+	// Initialize public parameters for both parties. The receiver chooses random selection bits.
+	if err := receiver.InitRandom(m, k, l); err != nil {
+		return nil, nil, nil, err
+	}
+	if err := sender.InitRandom(m, k, l); err != nil {
+		return nil, nil, nil, err
+	}
+	setMultithreaded(&sender, &receiver, multithreaded)
+
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
+	// The sender generates the Matrix Q from the received U Matrix.
+	U, err := receiver.GenerateMatrixTAndUEklundh(multithreaded)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, multithreaded); err != nil {
+		return nil, nil, nil, err
 	}
 
-	return result
+	// Both parties hash their rows locally. No ciphertexts are sent.
+	pairs := sender.MakeRandomMessages()
+	choiceBits, messages := receiver.MakeRandomMessages()
 
+	return &RandomOTSender{Pairs: pairs}, &RandomOTReceiver{ChoiceBits: choiceBits, Messages: messages}, communication, nil
+}
+
+// OTDerandomizeProtocol turns stored random OTs into chosen-message OTs of the sender's messages and the
+// receiver's selection bits, using one message in each direction (Beaver's derandomization).
+// The messages must have the same length as the random messages of the random OTs.
+func OTDerandomizeProtocol(randomSender *RandomOTSender, randomReceiver *RandomOTReceiver, selectionBits []byte, messages []*utils.MessagePair) ([][]byte, *utils.Communication, error) {
+
+	communication := &utils.Communication{}
+
+	// The receiver sends d_j = b_j ⊕ c_j to the sender.
+	corrections, err := randomReceiver.MakeCorrections(selectionBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.ReceiverToSender, utils.ByteStringsWireLength(utils.ByteLength(len(corrections))))
+
+	// The sender sends one ciphertext pair per OT, masked with the random pair swapped according to d_j.
+	ciphertexts, err := randomSender.MakeAndSendCiphertexts(corrections, messages)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ciphertexts))
+
+	// The receiver removes its random message from the ciphertext chosen by its selection bit.
+	plaintexts, err := randomReceiver.DecryptCiphertexts(ciphertexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintexts, communication, nil
+}
+
+// OTExtensionProtocolN runs m 1-out-of-N OTs of l-bit strings following KK13. The receiver's choices r_j ∈ [0, N)
+// are encoded with a Walsh–Hadamard code of length 256, so the base OT phase uses k = 256 seed pairs, and the
+// sender sends N ciphertexts per OT. N must be between 2 and 256.
+func OTExtensionProtocolN(n int, l int, m int, choices []byte, tuples []*utils.MessageTuple, elGamal elgamal.ElGamal, multithreaded bool) ([][]byte, *utils.Communication, error) {
+	if err := validateParameters(WalshHadamardLength, l, m); err != nil {
+		return nil, nil, err
+	}
+	if err := utils.ValidateCount("choices", len(choices), m); err != nil {
+		return nil, nil, err
+	}
+	if err := utils.ValidateCount("tuples", len(tuples), m); err != nil {
+		return nil, nil, err
+	}
+
+	communication := &utils.Communication{}
+	receiver := OTReceiverN{OTReceiver: OTReceiver{random: elGamal.Random()}}
+	sender := OTSenderN{OTSender: OTSender{random: elGamal.Random()}}
+
+	// Initialize public parameters for both parties, the receiver's choices, and the sender's message tuples
+	if err := receiver.Init(choices, n, l); err != nil {
+		return nil, nil, err
+	}
+	if err := sender.Init(tuples, n, l); err != nil {
+		return nil, nil, err
+	}
+	setMultithreaded(&sender.OTSender, &receiver.OTReceiver, multithreaded)
+
+	// The parties run the 256 base OTs, where the sender learns one seed of each of the receiver's 256 seed pairs.
+	if err := baseOTPhase(&sender.OTSender, &receiver.OTReceiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, nil, err
+	}
+
+	// Receiver generates the Matrix T, and the Matrix U from the codewords of its choices and send U to the sender.
+	// The sender generates the Matrix Q from the received U Matrix exactly as in the 1-out-of-2 extension.
+	U, err := receiver.GenerateMatrixTAndUEklundh(multithreaded)
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, multithreaded); err != nil {
+		return nil, nil, err
+	}
+
+	// The sender sends m ciphertext tuples to the receiver.
+	// The receiver computes the desired message based on its choices.
+	ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	if err != nil {
+		return nil, nil, err
+	}
+	communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, byteCiphertextTuplesWireLength(ByteCiphertexts))
+	plaintexts, err := receiver.DecryptCiphertexts(ByteCiphertexts)
+	if err != nil {
+		return nil, nil, err
+	}
+	return plaintexts, communication, nil
+}
+
+// OTExtensionProtocolStreaming runs m OTs in chunks of chunkSize OTs, so the matrices T, U and Q only take
+// O(k × chunkSize) memory regardless of m. The base OT phase is run once, after which each chunk expands its part of
+// the PRG columns from the chunk's counter offset, transposes it using Eklundh's algorithm, and hashes its rows.
+// The selection bits and message pairs of each chunk are read from the selectionBits and messages callbacks,
+// and the receiver's results are emitted through the output callback together with the index of the chunk's first OT.
+// chunkSize must be a positive multiple of 64. If a callback returns inputs of the wrong size, the protocol stops
+// with an error after emitting the previous chunks.
+func OTExtensionProtocolStreaming(k int, l int, m int, chunkSize int,
+	selectionBits func(start int, count int) []byte,
+	messages func(start int, count int) []*utils.MessagePair,
+	output func(start int, plaintexts [][]byte),
+	elGamal elgamal.ElGamal, multithreaded bool) (*utils.Communication, error) {
+
+	if err := validateParameters(k, l, m); err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 || chunkSize%64 != 0 {
+		return nil, fmt.Errorf("%w: chunkSize must be a positive multiple of 64, got %d", ErrInvalidParameter, chunkSize)
+	}
+
+	communication := &utils.Communication{}
+	receiver := OTReceiver{random: elGamal.Random()}
+	sender := OTSender{random: elGamal.Random()}
+
+	// Initialize public parameters for both parties. The inputs are given chunk by chunk.
+	if err := initParties(&sender, &receiver, k, l, nil, nil); err != nil {
+		return nil, err
+	}
+	setMultithreaded(&sender, &receiver, multithreaded)
+
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&sender, &receiver, NewElGamalBaseOT(elGamal), communication); err != nil {
+		return nil, err
+	}
+
+	for start := 0; start < m; start += chunkSize {
+		count := chunkSize
+		if start+count > m {
+			count = m - start
+		}
+
+		// Both parties continue from the PRG position of the chunk's first OT.
+		chunkSelectionBits := selectionBits(start, count)
+		chunkMessages := messages(start, count)
+		if err := validateInputs(k, l, count, chunkSelectionBits, chunkMessages); err != nil {
+			return nil, err
+		}
+		if err := receiver.InitChunk(chunkSelectionBits, start); err != nil {
+			return nil, err
+		}
+		if err := sender.InitChunk(chunkMessages, start); err != nil {
+			return nil, err
+		}
+
+		// Receiver generates the chunk of T and U and send U to the sender, who generates the chunk of Q.
+		U, err := receiver.GenerateMatrixTAndUEklundh(multithreaded)
+		if err != nil {
+			return nil, err
+		}
+		communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+		if err := sender.GenerateMatrixQEklundh(U, multithreaded); err != nil {
+			return nil, err
+		}
+
+		// The sender sends the chunk's ciphertext pairs, and the receiver emits its messages.
+		ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+		if err != nil {
+			return nil, err
+		}
+		communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ByteCiphertexts))
+		plaintexts, err := receiver.DecryptCiphertexts(ByteCiphertexts)
+		if err != nil {
+			return nil, err
+		}
+		output(start, plaintexts)
+	}
+	return communication, nil
 }
@@ -0,0 +1,155 @@
+// OTExtensionSession.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/utils"
+)
+
+// OTExtensionSession runs the k base OTs once and then serves repeated batches of extended OTs.
+// Each batch continues the PRG columns from where the previous batch stopped, so no two batches
+// use the same pseudo-randomness, while the expensive ElGamal base OTs are shared by all batches.
+type OTExtensionSession struct {
+	sender        OTSender
+	receiver      OTReceiver
+	offset        int                 // Bit position in the PRG output where the next batch starts.
+	communication utils.Communication // Communication of the base OTs and all batches so far.
+	multithreaded bool                // Whether the Eklundh transposes are multithreaded.
+}
+
+// NewOTExtensionSession creates a session for OTs of l-bit strings with security parameter k,
+// and runs the base OT phase between the sender and the receiver. Both parties draw their randomness from the
+// source of elGamal (see elgamal.ElGamal.SetRandom).
+func NewOTExtensionSession(k int, l int, elGamal elgamal.ElGamal, multithreaded bool) (*OTExtensionSession, error) {
+	session := &OTExtensionSession{multithreaded: multithreaded}
+	session.sender.SetRandom(elGamal.Random())
+	session.receiver.SetRandom(elGamal.Random())
+
+	// Initialize public parameters for both parties. The inputs are given batch by batch.
+	if err := initParties(&session.sender, &session.receiver, k, l, nil, nil); err != nil {
+		return nil, err
+	}
+	setMultithreaded(&session.sender, &session.receiver, multithreaded)
+
+	// The parties run the k base OTs, where the sender learns one seed of each of the receiver's k seed pairs.
+	if err := baseOTPhase(&session.sender, &session.receiver, NewElGamalBaseOT(elGamal), &session.communication); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// SetWorkers sets the number of goroutines both parties use for PRG expansion, transposition and hashing
+// in the following batches. A value ≤ 0 uses parallel.DefaultWorkers (GOMAXPROCS).
+func (session *OTExtensionSession) SetWorkers(workers int) {
+	session.sender.SetWorkers(workers)
+	session.receiver.SetWorkers(workers)
+	session.multithreaded = workers != 1
+}
+
+// SetHashFunction chooses the hash function for the rows of Q and T in the following batches.
+// For HashFixedKeyAES the sender chooses a fresh key for the session and sends it to the receiver.
+// The OT index j used as tweak counts from the start of the session, so no two batches share a tweak.
+func (session *OTExtensionSession) SetHashFunction(hash HashFunction) error {
+	hashKey, err := session.sender.ChooseHashFunction(hash)
+	if err != nil {
+		return err
+	}
+	if hashKey != nil {
+		session.communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, utils.ByteStringsWireLength(len(hashKey)))
+	}
+	return session.receiver.ReceiveHashFunction(hash, hashKey)
+}
+
+// Offset returns the bit position in the PRG output where the next batch starts.
+func (session *OTExtensionSession) Offset() int {
+	return session.offset
+}
+
+// Communication returns the communication of the session so far: the base OTs, the keys of the hash functions,
+// and every batch. The returned value is a copy, which does not change with the following batches.
+func (session *OTExtensionSession) Communication() *utils.Communication {
+	communication := session.communication
+	return &communication
+}
+
+// advance moves the PRG position past a batch of m OTs, rounded up to a multiple of 64 bits.
+func (session *OTExtensionSession) advance(m int) {
+	session.offset += 64 * utils.WordsFor(m)
+}
+
+// Extend runs a batch of m OTs with the receiver's selection bits and the sender's message pairs,
+// using the seeds from the session's base OTs and fresh PRG output. Invalid inputs are rejected before any
+// PRG output is used, so the session can continue with the next batch after an error.
+func (session *OTExtensionSession) Extend(m int, selectionBits []byte, messages []*utils.MessagePair) ([][]byte, error) {
+	receiver := &session.receiver
+	sender := &session.sender
+
+	if err := validateInputs(receiver.k, receiver.l, m, selectionBits, messages); err != nil {
+		return nil, err
+	}
+
+	// Both parties continue from the PRG position after the previous batch.
+	if err := receiver.InitChunk(selectionBits, session.offset); err != nil {
+		return nil, err
+	}
+	if err := sender.InitChunk(messages, session.offset); err != nil {
+		return nil, err
+	}
+	session.advance(m)
+
+	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
+	// The sender generates the Matrix Q from the received U Matrix.
+	U, err := receiver.GenerateMatrixTAndUEklundh(session.multithreaded)
+	if err != nil {
+		return nil, err
+	}
+	session.communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, session.multithreaded); err != nil {
+		return nil, err
+	}
+
+	// The sender sends m ciphertext pairs to the receiver.
+	// The receiver computes the desired message based on the selection bits.
+	ByteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	if err != nil {
+		return nil, err
+	}
+	session.communication.Record(utils.PhaseCiphertexts, utils.SenderToReceiver, utils.ByteCiphertextPairsWireLength(ByteCiphertexts))
+	return receiver.DecryptCiphertexts(ByteCiphertexts)
+}
+
+// ExtendRandom runs a batch of m random OTs as in OTExtensionProtocolRandom,
+// using the seeds from the session's base OTs and fresh PRG output.
+func (session *OTExtensionSession) ExtendRandom(m int) (*RandomOTSender, *RandomOTReceiver, error) {
+	receiver := &session.receiver
+	sender := &session.sender
+
+	// The receiver chooses random selection bits, and both parties continue from the PRG position after the previous batch.
+	if err := receiver.InitRandom(m, receiver.k, receiver.l); err != nil {
+		return nil, nil, err
+	}
+	if err := sender.InitRandom(m, sender.k, sender.l); err != nil {
+		return nil, nil, err
+	}
+	receiver.offset = session.offset
+	sender.offset = session.offset
+	session.advance(m)
+
+	// Receiver generates the Matrix T, and the Matrix U and send U to the sender.
+	// The sender generates the Matrix Q from the received U Matrix.
+	U, err := receiver.GenerateMatrixTAndUEklundh(session.multithreaded)
+	if err != nil {
+		return nil, nil, err
+	}
+	session.communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, utils.BitMatrixWireLength(U))
+	if err := sender.GenerateMatrixQEklundh(U, session.multithreaded); err != nil {
+		return nil, nil, err
+	}
+
+	// Both parties hash their rows locally. No ciphertexts are sent.
+	pairs := sender.MakeRandomMessages()
+	choiceBits, messages := receiver.MakeRandomMessages()
+
+	return &RandomOTSender{Pairs: pairs}, &RandomOTReceiver{ChoiceBits: choiceBits, Messages: messages}, nil
+}
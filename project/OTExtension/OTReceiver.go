@@ -3,10 +3,14 @@ package OTExtension
 
 // Import your ElGamal package
 import (
-	"crypto/rand"
+	"bytes"
+	"crypto/sha256"
 	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/parallel"
 	"cryptographic-computing/project/utils"
-	"math/big"
+	"errors"
+	"fmt"
+	"io"
 
 	"github.com/hashicorp/vault/sdk/helper/xor"
 )
@@ -15,244 +19,500 @@ type OTReceiver struct {
 	m             int                    // Number of messages to be received.
 	k             int                    // Security parameter.
 	l             int                    // Bit length of each message.
-	selectionBits []byte                 // Receiver R holds m selection bits r = (r_1, ..., r_m).
+	selectionBits []uint64               // Receiver R holds m selection bits r = (r_1, ..., r_m), packed into 64-bit words.
 	seeds         []*utils.Seed          // Messages (seeds) to be sent, when invoking the Regular OT functionality k times.
 	PublicKeys    []*utils.PublicKeyPair // Public keys received from the OTSender when invoking the Regular OT functionality k times.
-	T             [][]byte               // Bit matrix T of size m × κ.
+	T             *utils.BitMatrix       // Bit matrix T of size m × κ.
+	extraOTs      int                    // Extra OTs with random selection bits, sacrificed in the KOS correlation check.
+	offset        int                    // Bit position in the PRG output of the first OT, when extending OTs in chunks.
+	workers       int                    // Number of goroutines used for PRG expansion, transposition and hashing.
+	crHash        *utils.CRHash          // Fixed-key AES hash received with ReceiveHashFunction, or nil for SHA-256.
+	hashKey       []byte                 // Key of crHash as received, before it is keyed for the session.
+	random        io.Reader              // Source of randomness set with SetRandom, or nil for crypto/rand.
+	sessionID     []byte                 // Session ID of the run, or nil before JoinSession (see Session.go).
+
+	variableLengths bool // Whether every pair has its own padded length (see InitVariableLengths).
+
+	challengeCommitment []byte // Commitment to the OTSender's challenge seed (KOS only).
+	challengeSeed       []byte // The OTReceiver's share of the coin-tossed challenge seed (KOS only).
+	challengeSeedSender []byte // The OTSender's opened share of the challenge seed (KOS only).
 }
 
-func (receiver *OTReceiver) Init(selectionBits []byte, k int, l int) {
+// Initialize the receiver with its selection bits. Returns an error wrapping ErrInvalidSecurityParameter if k is not
+// 128, 192 or 256, ErrInvalidMessageLength if l is not positive, or ErrInvalidChoiceBit if a selection bit is not 0 or 1.
+func (receiver *OTReceiver) Init(selectionBits []byte, k int, l int) error {
+
+	if err := utils.ValidateSecurityParameter(k); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return err
+	}
+	if err := utils.ValidateSelectionBits(selectionBits); err != nil {
+		return err
+	}
 
 	receiver.l = l
 	receiver.m = len(selectionBits)
-	receiver.selectionBits = selectionBits
+	receiver.selectionBits = utils.PackBits(selectionBits)
 	receiver.k = k
+	return nil
 }
 
-// The receiver chooses k pairs of k-bit seeds {(k0_i , k1_i )} from i = 1 to k using a secure random number generator.
-func (receiver *OTReceiver) ChooseSeeds() {
+// Initialize the receiver with its selection bits for message pairs of any lengths (see OTSender.InitVariableLengths).
+// The length of every message is learned from the ciphertexts. Returns an error wrapping ErrInvalidSecurityParameter
+// if k is not 128, 192 or 256, or ErrInvalidChoiceBit if a selection bit is not 0 or 1.
+func (receiver *OTReceiver) InitVariableLengths(selectionBits []byte, k int) error {
 
-	k := receiver.k
+	if err := utils.ValidateSecurityParameter(k); err != nil {
+		return err
+	}
+	if err := utils.ValidateSelectionBits(selectionBits); err != nil {
+		return err
+	}
 
-	seeds := make([]*utils.Seed, receiver.k)
+	receiver.l = 0 // The bit length of pair j is 8·len(Y0_j).
+	receiver.m = len(selectionBits)
+	receiver.selectionBits = utils.PackBits(selectionBits)
+	receiver.k = k
+	receiver.variableLengths = true
+	return nil
+}
 
-	for i := 0; i < k; i++ {
-		// Generate a k-bit random number for seed0
-		seed0, err := rand.Int(rand.Reader, big.NewInt(1).Lsh(big.NewInt(1), uint(k)))
-		if err != nil {
-			panic("Error in ChooseSeeds for seed0: " + err.Error())
-		}
-		// Generate a k-bit random number for seed1
-		seed1, err := rand.Int(rand.Reader, big.NewInt(1).Lsh(big.NewInt(1), uint(k)))
-		if err != nil {
-			panic("Error in ChooseSeeds for seed1: " + err.Error())
-		}
-		seeds[i] = &utils.Seed{
-			Seed0: seed0,
-			Seed1: seed1,
-		}
+// Method for extending OTs in chunks. The receiver continues with the next chunk of selection bits, where offset
+// is the number of OTs extended before it, so the PRG columns continue from the same position. k and l are kept
+// from Init, and offset must be a multiple of 64.
+func (receiver *OTReceiver) InitChunk(selectionBits []byte, offset int) error {
+
+	if err := utils.ValidateSelectionBits(selectionBits); err != nil {
+		return err
 	}
-	receiver.seeds = seeds
+	if err := validateOffset(offset); err != nil {
+		return err
+	}
+
+	receiver.m = len(selectionBits)
+	receiver.selectionBits = utils.PackBits(selectionBits)
+	receiver.offset = offset
+	return nil
 }
 
-// DEBUGGING METHOD. The receiver chooses k pairs of k-bit seeds {(k0_i , k1_i )} from i = 1 to k using fixed values.
-func (receiver *OTReceiver) ChooseFixedSeeds() {
+// Initialize the receiver for random OT, where the receiver chooses m random selection bits itself
+// using a secure random number generator.
+func (receiver *OTReceiver) InitRandom(m int, k int, l int) error {
 
-	k := receiver.k
+	if err := validateParameters(k, l, m); err != nil {
+		return err
+	}
 
-	// Define fixed seeds
-	fixedSeeds := []struct {
-		Seed0 *big.Int
-		Seed1 *big.Int
-	}{
-		// Fixed values for seeds
-		{big.NewInt(12345), big.NewInt(67890)},
+	selectionBits, err := utils.RandomPackedBitsFrom(receiver.random, m)
+	if err != nil {
+		return err
 	}
 
+	receiver.l = l
+	receiver.m = m
+	receiver.selectionBits = selectionBits
+	receiver.k = k
+	return nil
+}
+
+// SetRandom sets the source of the receiver's randomness: its seeds, random selection bits and challenge seed,
+// and the ElGamal encryptions in the base OTs. The default (nil) is crypto/rand, and the ElGamal's own source for
+// the encryptions. Tests can supply a seeded deterministic stream (utils.NewDeterministicReader) to reproduce an
+// exact transcript.
+func (receiver *OTReceiver) SetRandom(random io.Reader) {
+	receiver.random = random
+}
+
+// SetWorkers sets the number of goroutines the receiver uses to expand the PRG columns, transpose T and
+// hash its rows. A value ≤ 0 uses parallel.DefaultWorkers (GOMAXPROCS), which is also the default.
+func (receiver *OTReceiver) SetWorkers(workers int) {
+	receiver.workers = workers
+}
+
+// workerCount returns the number of goroutines to use, defaulting to parallel.DefaultWorkers.
+func (receiver *OTReceiver) workerCount() int {
+	if receiver.workers <= 0 {
+		return parallel.DefaultWorkers()
+	}
+	return receiver.workers
+}
+
+// numOTs returns the number of OTs to extend, including the extra OTs sacrificed in the KOS correlation check.
+func (receiver *OTReceiver) numOTs() int {
+	return receiver.m + receiver.extraOTs
+}
+
+// Method for the KOS protocol. The receiver appends k + s random selection bits to its own,
+// so m + k + s OTs are extended. The extra OTs mask the real selection bits in the correlation check,
+// and are discarded afterwards.
+func (receiver *OTReceiver) AddCheckOTs() error {
+
+	receiver.extraOTs = receiver.k + StatisticalSecurity
+	numOTs := receiver.numOTs()
+
+	// Generate the extra random selection bits and copy the real selection bits into their place.
+	selectionBits, err := utils.RandomPackedBitsFrom(receiver.random, numOTs)
+	if err != nil {
+		return err
+	}
+	for j := 0; j < receiver.m; j++ {
+		utils.SetBit(selectionBits, j, utils.GetBit(receiver.selectionBits, j))
+	}
+
+	receiver.selectionBits = selectionBits
+	return nil
+}
+
+// The receiver chooses k pairs of k-bit seeds {(k0_i , k1_i )} from i = 1 to k using a secure random number generator.
+// The seeds are byte strings of k/8 bytes, which the PRG turns into AES keys with a KDF.
+func (receiver *OTReceiver) ChooseSeeds() error {
+
+	k := receiver.k
+	seedLength := utils.SeedLength(k)
+
 	seeds := make([]*utils.Seed, k)
 
 	for i := 0; i < k; i++ {
-		// Use fixed seeds instead of generating them and ake sure the number of fixed seeds is at least k
-		seed0 := fixedSeeds[i%len(fixedSeeds)].Seed0
-		seed1 := fixedSeeds[i%len(fixedSeeds)].Seed1
-
+		// Generate a k-bit random seed for seed0
+		seed0, err := utils.RandomSeedFrom(receiver.random, seedLength)
+		if err != nil {
+			return err
+		}
+		// Generate a k-bit random seed for seed1
+		seed1, err := utils.RandomSeedFrom(receiver.random, seedLength)
+		if err != nil {
+			return err
+		}
 		seeds[i] = &utils.Seed{
 			Seed0: seed0,
 			Seed1: seed1,
 		}
 	}
 	receiver.seeds = seeds
+	return nil
 }
 
 // Method to receive Public keys, when the parties invoke the regular OT functionality k times,
 // where the OTSender plays the receiver and OTReceiver plays the sender.
-func (receiver *OTReceiver) ReceiveKeys(PublicKeys []*utils.PublicKeyPair) {
+// Returns an error wrapping ErrDimensionMismatch if there is not one pair of public keys per base OT, or
+// ErrInvalidElement if a key is not an element of the group or is the identity, which would reveal the seeds.
+func (receiver *OTReceiver) ReceiveKeys(PublicKeys []*utils.PublicKeyPair, elGamal *elgamal.ElGamal) error {
 
-	receiver.PublicKeys = make([]*utils.PublicKeyPair, receiver.k)
+	if err := utils.ValidatePublicKeyPairs(PublicKeys, receiver.k, elGamal); err != nil {
+		return err
+	}
 	receiver.PublicKeys = PublicKeys
+	return nil
 }
 
 // Method to encrypt messages (seeds) when the parties invoke the regular OT functionality k times,
-// where the OTSender plays the receiver and OTReceiver plays the sender.
-func (receiver *OTReceiver) EncryptSeeds(elGamal *elgamal.ElGamal) []*utils.CiphertextPair {
-
-	k := receiver.k
-
-	ciphertextPairs := make([]*utils.CiphertextPair, k)
-
-	for i := 0; i < k; i++ {
+// where the OTSender plays the receiver and OTReceiver plays the sender, as in ElGamalBaseOT. The seeds are bound to
+// the session ID and the received public keys.
+// Returns an error if the source of randomness fails.
+func (receiver *OTReceiver) EncryptSeeds(elGamal *elgamal.ElGamal) ([]*utils.CiphertextPair, error) {
+	return elGamalBaseOTEncrypt(elGamal.WithRandom(receiver.random), receiver.sessionID, receiver.PublicKeys, receiver.BaseOTSeeds())
+}
 
-		ciphertextPairs[i] = &utils.CiphertextPair{} // Initialize the ciphertext pair
+// BaseOTSeeds returns the k seed pairs to be sent in the base OTs, e.g. with a BaseOT. After JoinSession, the seeds
+// are masked for the session, and the PRG uses the seeds derived from them for the session afterwards, so this method
+// must be called exactly once.
+func (receiver *OTReceiver) BaseOTSeeds() []*utils.Seed {
+	seeds := make([]*utils.Seed, len(receiver.seeds))
+	for i, seed := range receiver.seeds {
+		seeds[i] = &utils.Seed{
+			Seed0: maskSeed(receiver.sessionID, i, 0, seed.Seed0),
+			Seed1: maskSeed(receiver.sessionID, i, 1, seed.Seed1),
+		}
 
-		// Encrypt the messages using the public keys received from the OTSender(who is receiver in this phase)
-		ciphertextPairs[i].Ciphertext0 = elGamal.Encrypt(receiver.seeds[i].Seed0, receiver.PublicKeys[i].MessageKey0)
-		ciphertextPairs[i].Ciphertext1 = elGamal.Encrypt(receiver.seeds[i].Seed1, receiver.PublicKeys[i].MessageKey1)
+		// Derive the PRG seeds of the session from the seeds that are sent.
+		receiver.seeds[i] = &utils.Seed{
+			Seed0: sessionSeed(receiver.sessionID, i, 0, seed.Seed0),
+			Seed1: sessionSeed(receiver.sessionID, i, 1, seed.Seed1),
+		}
 	}
-	return ciphertextPairs
+	return seeds
 }
 
-// Method for generating the bit matrices T and U of size m × κ.
-// Notice this method is inefficient, since it accesses the entire matrix T (e.g. m x k entries).
-func (receiver *OTReceiver) GenerateMatrixTAndU() [][]byte {
+// Method for generating the bit matrices T and U of size m × κ with rows packed into 64-bit words.
+// Notice this method is inefficient, since it accesses the entire matrix T bit by bit (e.g. m x k entries).
+func (receiver *OTReceiver) GenerateMatrixTAndU() (*utils.BitMatrix, error) {
 	k := receiver.k
-	m := receiver.m
+	m := receiver.numOTs()
 
-	// Initialize the matrix T of size m × κ.
-	T := make([][]byte, m) // m rows.
-	U := make([][]byte, m) // m rows.
-	for i := range T {
-		T[i] = make([]byte, k) // k columns per row.
-		U[i] = make([]byte, k) // k columns per row.
-	}
+	// Initialize the matrices T and U of size m × κ.
+	T := utils.NewBitMatrix(m, k)
+	U := utils.NewBitMatrix(m, k)
 
 	// Generate each column of T.
 	for i := 0; i < k; i++ {
 		// Generate pseudo-random bitstrings of m bits using the seeds
-		bitstringT, err1 := utils.PseudoRandomGenerator(receiver.seeds[i].Seed0, m)
-		bitstringU, err2 := utils.PseudoRandomGenerator(receiver.seeds[i].Seed1, m)
-
-		if err1 != nil || err2 != nil {
-			panic("Error from pseudoRandomGenerator in GenerateMatrixT: " + err1.Error() + err2.Error())
+		bitstringT, err1 := utils.PseudoRandomGeneratorWordsFrom(receiver.seeds[i].Seed0, receiver.offset, m)
+		bitstringU, err2 := utils.PseudoRandomGeneratorWordsFrom(receiver.seeds[i].Seed1, receiver.offset, m)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
 		}
 
 		for j := 0; j < m; j++ {
-			T[j][i] = bitstringT[j] // Assign the bit to the matrix T at position (j,i).
+			T_idx := utils.GetBit(bitstringT, j)
+			G_idx := utils.GetBit(bitstringU, j)
+			selection_bit := utils.GetBit(receiver.selectionBits, j)
 
-			T_idx := T[j][i]
-			G_idx := bitstringU[j]
-			selection_bit := receiver.selectionBits[j]
-			U[j][i] = T_idx ^ G_idx ^ selection_bit
+			T.SetBit(j, i, T_idx) // Assign the bit to the matrix T at position (j,i).
+			U.SetBit(j, i, T_idx^G_idx^selection_bit)
 		}
 	}
 	// Assign the generated matrix to the receiver.
 	receiver.T = T
-	return U // Send U to the OTSender
+	return U, nil // Send U to the OTSender
+}
+
+// generateMatrixTAndURowWise generates the matrices T and U of size κ × m row-wise, which is the transpose of
+// the m × κ matrices in the protocol. Each row is a whole packed PRG output, so u^i = t^i ⊕ G(k^1_i) ⊕ r
+// is computed with one XOR per 64 bits.
+func (receiver *OTReceiver) generateMatrixTAndURowWise() (*utils.BitMatrix, *utils.BitMatrix, error) {
+	k := receiver.k
+	m := receiver.numOTs()
+
+	// Initialize the matrix T and U of size k × m (T is transposed later).
+	T := utils.NewBitMatrix(k, m)
+	U := utils.NewBitMatrix(k, m)
+
+	// The columns are expanded in parallel, since every column only depends on its own pair of seeds.
+	err := parallel.ForErr(k, receiver.workerCount(), func(start int, end int) error {
+		for i := start; i < end; i++ {
+			// Generate pseudo-random bitstrings of m bits using the seeds
+			bitstringT, err1 := utils.PseudoRandomGeneratorWordsFrom(receiver.seeds[i].Seed0, receiver.offset, m)
+			bitstringU, err2 := utils.PseudoRandomGeneratorWordsFrom(receiver.seeds[i].Seed1, receiver.offset, m)
+			if err := errors.Join(err1, err2); err != nil {
+				return err
+			}
+			copy(T.Row(i), bitstringT)
+
+			U_i := U.Row(i)
+			utils.XORWords(U_i, bitstringT, bitstringU)
+			utils.XORWords(U_i, U_i, receiver.selectionBits)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return T, U, nil
 }
 
 // More efficient method than the previous one for generating the bit matrices T and U of size m × κ.
 // It generates the matrix T and U row-wise for transposing afterwards.
 // Notice, only T is transposed here, as U is needed for the OTSender to generate Q row-wise.
-func (receiver *OTReceiver) GenerateMatrixTAndUTranspose() [][]byte {
-	k := receiver.k
-	m := receiver.m
+func (receiver *OTReceiver) GenerateMatrixTAndUTranspose() (*utils.BitMatrix, error) {
 
-	// Initialize the matrix T and U of size k × m (transposed later)
-	T := make([][]byte, k) // m rows.
-	U := make([][]byte, k) // m rows.
-	for i := range T {
-		T[i] = make([]byte, m) // k columns per row.
-		U[i] = make([]byte, m) // k columns per row.
+	T, U, err := receiver.generateMatrixTAndURowWise()
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < k; i++ {
-		// Generate pseudo-random bitstrings of m bits using the seeds
-		bitstringT, err1 := utils.PseudoRandomGenerator(receiver.seeds[i].Seed0, m)
-		bitstringU, err2 := utils.PseudoRandomGenerator(receiver.seeds[i].Seed1, m)
-		if err1 != nil || err2 != nil {
-			panic("Error from pseudoRandomGenerator in GenerateMatrixTAndUTranspose: " + err1.Error() + err2.Error())
-		}
-		T[i] = bitstringT
-
-		xor1, err1 := xor.XORBytes(T[i], bitstringU)
-		xor2, err2 := xor.XORBytes(xor1, receiver.selectionBits)
-		if err1 != nil || err2 != nil {
-			panic("Error from XOR in GenerateMatrixTAndUTranspose: " + err1.Error() + err2.Error())
-		}
-		U[i] = xor2
-	}
 	// Assign the generated matrix to the receiver after transposition.
-	receiver.T = utils.TransposeMatrix(T)
+	receiver.T = utils.TransposeBitMatrix(T)
 
-	return U // Send U to the OTSender
+	return U, nil // Send U to the OTSender
 }
 
 // Even more efficient Method than the previous one for generating the bit matrices T and U of size m × κ.
-// It generates the matrix T and U row-wise for transposing afterwards using Eklundh's algorithm.
+// It generates the matrix T and U row-wise for transposing afterwards using Eklundh's algorithm on 64-bit words.
 // Notice, only T is transposed here, as U is needed for the OTSender to generate Q row-wise.
-func (receiver *OTReceiver) GenerateMatrixTAndUEklundh(multithreaded bool) [][]byte {
-	k := receiver.k
-	m := receiver.m
+// The transposition uses the receiver's worker count if multithreaded is true, and a single goroutine otherwise.
+func (receiver *OTReceiver) GenerateMatrixTAndUEklundh(multithreaded bool) (*utils.BitMatrix, error) {
 
-	// Initialize the matrix T and U of size k × m (transposed later).
-	T := make([][]byte, k) // m rows.
-	U := make([][]byte, k) // m rows.
-	for i := range T {
-		T[i] = make([]byte, m) // k columns per row.
-		U[i] = make([]byte, m) // k columns per row.
+	T, U, err := receiver.generateMatrixTAndURowWise()
+	if err != nil {
+		return nil, err
 	}
 
-	for i := 0; i < k; i++ {
-		// Generate pseudo-random bitstrings of m bits using the seed.
-		bitstringT, err1 := utils.PseudoRandomGenerator(receiver.seeds[i].Seed0, m)
-		bitstringU, err2 := utils.PseudoRandomGenerator(receiver.seeds[i].Seed1, m)
-		if err1 != nil || err2 != nil {
-			panic("Error from pseudoRandomGenerator in GenerateMatrixTEklundh: " + err1.Error() + err2.Error())
-		}
-		T[i] = bitstringT
+	// Assign the generated matrix to the receiver, where Eklundh's algorithm is used to transpose.
+	workers := 1
+	if multithreaded {
+		workers = receiver.workerCount()
+	}
+	receiver.T = utils.EklundhTransposeBitMatrix(T, workers)
+
+	return U, nil // Send U to the OTSender
+}
+
+// Method for the coin tossing of the KOS challenge. The receiver stores the OTSender's commitment to its seed,
+// and answers with a random seed of its own.
+func (receiver *OTReceiver) ChooseChallengeSeed(commitment []byte) ([]byte, error) {
+
+	if len(commitment) != sha256.Size {
+		return nil, fmt.Errorf("%w: commitment has %d bytes, expected %d", ErrDimensionMismatch, len(commitment), sha256.Size)
+	}
+
+	receiver.challengeCommitment = commitment
+	challengeSeed, err := utils.RandomSeedFrom(receiver.random, challengeSeedLength)
+	if err != nil {
+		return nil, err
+	}
+	receiver.challengeSeed = challengeSeed
+	return receiver.challengeSeed, nil // Send the seed to the OTSender
+}
+
+// Method for receiving the OTSender's opened challenge seed. It returns ErrCommitmentMismatch if the seed
+// does not match the commitment, since the sender could otherwise choose the challenges after seeing U.
+func (receiver *OTReceiver) ReceiveChallengeSeed(seedSender []byte) error {
+
+	commitment := sha256.Sum256(seedSender)
+	if !bytes.Equal(commitment[:], receiver.challengeCommitment) {
+		return ErrCommitmentMismatch
+	}
+	receiver.challengeSeedSender = seedSender
+	return nil
+}
 
-		xor1, err1 := xor.XORBytes(T[i], bitstringU)
-		xor2, err2 := xor.XORBytes(xor1, receiver.selectionBits)
-		if err1 != nil || err2 != nil {
-			panic("Error from XOR in GenerateMatrixTAndUEklundh: " + err1.Error() + err2.Error())
+// Method for the KOS correlation check. The receiver computes x = ⊕_j χ_j·r_j and t = ⊕_j χ_j·t_j in GF(2^k)
+// over all m + k + s rows of T, using the coin-tossed challenges χ_j.
+func (receiver *OTReceiver) MakeCorrelationCheck() (*utils.CorrelationCheck, error) {
+
+	k := receiver.k
+	numOTs := receiver.numOTs()
+	words := utils.WordsFor(k)
+
+	x := make([]uint64, words)
+	t := make([]uint64, 2*words) // Unreduced sum of products, reduced once at the end.
+	chi := make([]uint64, words)
+
+	generator, err := newChallengeGenerator(receiver.challengeSeedSender, receiver.challengeSeed, k)
+	if err != nil {
+		return nil, err
+	}
+	for j := 0; j < numOTs; j++ {
+		generator.next(chi)
+		if utils.GetBit(receiver.selectionBits, j) == 1 {
+			utils.XORWords(x, x, chi)
 		}
-		U[i] = xor2
+		utils.CarrylessMultiplyAdd(t, chi, receiver.T.Row(j))
 	}
-	// Assign the generated matrix to the receiver, where Eklundh's algorithm is used to transpose .
-	receiver.T = utils.EklundhTranspose(T, multithreaded)
 
-	return U // Send U to the OTSender
+	reducedT, err := utils.GFReduce(t, k)
+	if err != nil {
+		return nil, err
+	}
+	return &utils.CorrelationCheck{X: x, T: reducedT}, nil // Send the check to the OTSender
 }
 
 // Method for decrypting the ciphertexts received from the OTSender.
-// The receiver computes x^(r_j)_j = y^(r_j)_j ⊕ H(j, t_j) for every 1 ≤ j ≤ m.
-func (receiver *OTReceiver) DecryptCiphertexts(ByteCiphertextPairs []*utils.ByteCiphertextPair) [][]byte {
+// The receiver computes x^(r_j)_j = y^(r_j)_j ⊕ H(j, t_j) for every 1 ≤ j ≤ m, and removes the padding after
+// InitVariableLengths. Returns an error wrapping ErrDimensionMismatch or ErrInvalidMessageLength if the ciphertexts
+// do not match m and l, or if the padding is invalid.
+func (receiver *OTReceiver) DecryptCiphertexts(ByteCiphertextPairs []*utils.ByteCiphertextPair) ([][]byte, error) {
 
 	m := receiver.m
 	l := receiver.l
 
+	if err := utils.ValidateCount("ByteCiphertextPairs", len(ByteCiphertextPairs), m); err != nil {
+		return nil, err
+	}
+	for j, pair := range ByteCiphertextPairs {
+		if pair == nil {
+			return nil, fmt.Errorf("%w: ciphertext pair %d is missing", ErrDimensionMismatch, j)
+		}
+		if receiver.variableLengths {
+			if len(pair.Y0) == 0 || len(pair.Y0) != len(pair.Y1) {
+				return nil, fmt.Errorf("%w: ciphertext pair %d has %d and %d bytes, expected the same non-zero length", ErrInvalidMessageLength, j, len(pair.Y0), len(pair.Y1))
+			}
+		} else if err := errors.Join(utils.ValidateBytes("Y0", pair.Y0, l), utils.ValidateBytes("Y1", pair.Y1, l)); err != nil {
+			return nil, err
+		}
+	}
+
 	plaintexts := make([][]byte, m)
 
+	// The rows are hashed in parallel, since every plaintext only depends on its own row of T.
+	err := parallel.ForErr(m, receiver.workerCount(), func(start int, end int) error {
+		for j := start; j < end; j++ {
+
+			var y_j []byte
+
+			// Choose the ciphertext to decrypt based on the selection bit.
+			if utils.GetBit(receiver.selectionBits, j) == 0 {
+				y_j = ByteCiphertextPairs[j].Y0
+			} else {
+				y_j = ByteCiphertextPairs[j].Y1
+			}
+
+			l_j := l
+			if receiver.variableLengths {
+				l_j = 8 * len(y_j)
+			}
+
+			hash := hashRow(receiver.crHash, receiver.sessionID, receiver.offset+j, receiver.T.Row(j), l_j) // Generate hash of length l from the j'th row of T.
+
+			xor, err := xor.XORBytes(y_j, hash) // XOR the ciphertext with the hash.
+			if err != nil {
+				return err
+			}
+
+			// Remove the padding to the length of the pair.
+			if receiver.variableLengths {
+				if xor, err = utils.UnpadMessage(xor); err != nil {
+					return fmt.Errorf("ciphertext pair %d: %w", j, err)
+				}
+			}
+			plaintexts[j] = xor
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return plaintexts, nil
+}
+
+// Method for random OT. Instead of decrypting ciphertexts, the receiver outputs its random selection bits c_j
+// and the matching random messages x^(c_j)_j = H(t_j) of l bits.
+func (receiver *OTReceiver) MakeRandomMessages() ([]byte, [][]byte) {
+
+	m := receiver.m
+	l := receiver.l
+
+	messages := make([][]byte, m)
 	for j := 0; j < m; j++ {
+		messages[j] = hashRow(receiver.crHash, receiver.sessionID, receiver.offset+j, receiver.T.Row(j), l) // Generate hash of length l from the j'th row of T.
+	}
+	return utils.UnpackBits(receiver.selectionBits, m), messages
+}
 
-		var y_j []byte
+// Method for decrypting the correlated OT ciphertexts received from the OTSender.
+// The receiver computes x^(r_j)_j = H(t_j) if r_j = 0 and x^(r_j)_j = y_j ⊕ H(t_j) if r_j = 1, for every 1 ≤ j ≤ m.
+// Returns an error wrapping ErrDimensionMismatch or ErrInvalidMessageLength if the ciphertexts do not match m and l.
+func (receiver *OTReceiver) DecryptCorrelatedCiphertexts(ciphertexts [][]byte) ([][]byte, error) {
 
-		// Choose the ciphertext to decrypt based on the selection bit.
-		if receiver.selectionBits[j] == 0 {
-			y_j = ByteCiphertextPairs[j].Y0
-		} else if receiver.selectionBits[j] == 1 {
-			y_j = ByteCiphertextPairs[j].Y1
-		} else {
-			panic("Receiver choice bits are not 0 or 1 in DecryptCiphertexts")
+	m := receiver.m
+	l := receiver.l
+
+	if err := utils.ValidateCount("ciphertexts", len(ciphertexts), m); err != nil {
+		return nil, err
+	}
+	for _, y_j := range ciphertexts {
+		if err := utils.ValidateBytes("ciphertext", y_j, l); err != nil {
+			return nil, err
 		}
+	}
 
-		hash := utils.Hash(receiver.T[j], l) // Generate hash of length l from the j'th row of T.
+	plaintexts := make([][]byte, m)
 
-		xor, err := xor.XORBytes(y_j, hash) // XOR the ciphertext with the hash.
-		if err != nil {
-			panic("Error from XOR in DecryptCiphertexts: " + err.Error())
-		}
-		plaintexts[j] = xor
+	for j := 0; j < m; j++ {
+
+		hash := hashRow(receiver.crHash, receiver.sessionID, receiver.offset+j, receiver.T.Row(j), l) // Generate hash of length l from the j'th row of T.
 
+		if utils.GetBit(receiver.selectionBits, j) == 0 {
+			plaintexts[j] = hash
+		} else {
+			xor, err := xor.XORBytes(ciphertexts[j], hash) // XOR the ciphertext with the hash.
+			if err != nil {
+				return nil, err
+			}
+			plaintexts[j] = xor
+		}
 	}
-	return plaintexts
+	return plaintexts, nil
 }
@@ -0,0 +1,129 @@
+// OTReceiverN.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/parallel"
+	"cryptographic-computing/project/utils"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/xor"
+)
+
+// OTReceiverN is the receiver of the 1-out-of-N OT extension (KK13). It reuses the base OT phase and
+// the matrix T of the OTReceiver with k = WalshHadamardLength, but encodes its choices with the Walsh–Hadamard code.
+type OTReceiverN struct {
+	OTReceiver
+	n       int    // Number of messages in each tuple.
+	choices []byte // Receiver R holds m choices r_j ∈ [0, N) of log N bits.
+}
+
+// Initialize the receiver with its choices. Returns an error wrapping ErrInvalidParameter if N is not between 2 and 256,
+// ErrInvalidMessageLength if l is not positive, or ErrInvalidChoiceBit if a choice is not in [0, N).
+func (receiver *OTReceiverN) Init(choices []byte, n int, l int) error {
+
+	if err := validateN(n); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return err
+	}
+	for j, r_j := range choices {
+		if int(r_j) >= n {
+			return fmt.Errorf("%w: choice %d is %d, expected a value in [0, %d)", ErrInvalidChoiceBit, j, r_j, n)
+		}
+	}
+
+	receiver.l = l
+	receiver.m = len(choices)
+	receiver.k = WalshHadamardLength
+	receiver.n = n
+	receiver.choices = choices
+	return nil
+}
+
+// Method for generating the bit matrices T and U of size m × k row-wise, with T transposed afterwards using
+// Eklundh's algorithm. Instead of XORing every column with the selection bits r, column i of U is XORed with
+// column i of the code matrix, whose j'th row is the codeword C(r_j): u^i = t^i ⊕ G(k^1_i) ⊕ c^i.
+func (receiver *OTReceiverN) GenerateMatrixTAndUEklundh(multithreaded bool) (*utils.BitMatrix, error) {
+	k := receiver.k
+	m := receiver.m
+
+	// Initialize the matrix T and U of size k × m (T is transposed later).
+	T := utils.NewBitMatrix(k, m)
+	U := utils.NewBitMatrix(k, m)
+	// The columns are expanded in parallel, since every column only depends on its own pair of seeds.
+	err := parallel.ForErr(k, receiver.workerCount(), func(start int, end int) error {
+		column := make([]uint64, utils.WordsFor(m))
+
+		for i := start; i < end; i++ {
+			// Generate pseudo-random bitstrings of m bits using the seeds
+			bitstringT, err1 := utils.PseudoRandomGeneratorWords(receiver.seeds[i].Seed0, m)
+			bitstringU, err2 := utils.PseudoRandomGeneratorWords(receiver.seeds[i].Seed1, m)
+			if err := errors.Join(err1, err2); err != nil {
+				return err
+			}
+			copy(T.Row(i), bitstringT)
+
+			// Column i of the code matrix holds bit i of every codeword C(r_j).
+			for j, r_j := range receiver.choices {
+				utils.SetBit(column, j, walshHadamardBit(r_j, i))
+			}
+
+			U_i := U.Row(i)
+			utils.XORWords(U_i, bitstringT, bitstringU)
+			utils.XORWords(U_i, U_i, column)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Assign the generated matrix to the receiver, where Eklundh's algorithm is used to transpose.
+	workers := 1
+	if multithreaded {
+		workers = receiver.workerCount()
+	}
+	receiver.T = utils.EklundhTransposeBitMatrix(T, workers)
+
+	return U, nil // Send U to the OTSenderN
+}
+
+// Method for decrypting the ciphertexts received from the OTSenderN.
+// The receiver computes x^(r_j)_j = y^(r_j)_j ⊕ H(j, t_j) for every 1 ≤ j ≤ m.
+// Returns an error wrapping ErrDimensionMismatch or ErrInvalidMessageLength if the ciphertexts do not match m, N and l.
+func (receiver *OTReceiverN) DecryptCiphertexts(ByteCiphertextTuples []*utils.ByteCiphertextTuple) ([][]byte, error) {
+
+	m := receiver.m
+	l := receiver.l
+
+	if err := utils.ValidateCount("ByteCiphertextTuples", len(ByteCiphertextTuples), m); err != nil {
+		return nil, err
+	}
+	for j, tuple := range ByteCiphertextTuples {
+		if tuple == nil {
+			return nil, fmt.Errorf("%w: ciphertext tuple %d is missing", ErrDimensionMismatch, j)
+		}
+		if err := utils.ValidateCount(fmt.Sprintf("ciphertext tuple %d", j), len(tuple.Y), receiver.n); err != nil {
+			return nil, err
+		}
+		if err := utils.ValidateBytes(fmt.Sprintf("ciphertext %d of tuple %d", receiver.choices[j], j), tuple.Y[receiver.choices[j]], l); err != nil {
+			return nil, err
+		}
+	}
+
+	plaintexts := make([][]byte, m)
+
+	for j := 0; j < m; j++ {
+		y_j := ByteCiphertextTuples[j].Y[receiver.choices[j]] // Choose the ciphertext to decrypt based on the choice.
+
+		hash := hashIndexedRow(receiver.sessionID, j, receiver.T.Row(j), l) // Generate hash of length l from j and the j'th row of T.
+
+		xor, err := xor.XORBytes(y_j, hash) // XOR the ciphertext with the hash.
+		if err != nil {
+			return nil, err
+		}
+		plaintexts[j] = xor
+	}
+	return plaintexts, nil
+}
@@ -2,9 +2,13 @@
 package OTExtension
 
 import (
-	"crypto/rand"
+	"crypto/sha256"
 	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/parallel"
 	"cryptographic-computing/project/utils"
+	"errors"
+	"fmt"
+	"io"
 	"math/big"
 
 	"github.com/hashicorp/vault/sdk/helper/xor"
@@ -15,256 +19,499 @@ type OTSender struct {
 	l          int                    // Bit length of each message
 	m          int                    // Number of messages to be sent
 	k          int                    // Security parameter
-	s          []byte                 // Random list of 0's and 1's: s = (s_1, ... , s_k).
+	s          []uint64               // Random list of 0's and 1's: s = (s_1, ... , s_k), packed into 64-bit words.
 	secretKeys []*big.Int             // Secret keys for each seed to be received and decrypted. (Used for k regular OTs)
+	baseOTKeys []*utils.PublicKeyPair // Public keys sent in the k regular OTs, which the seeds are bound to.
 	PublicKeys []*utils.PublicKeyPair // Public keys to be received from the OTReceiver - one oblivious and one real for each message to be sent
-	seeds      []*big.Int             // Seed values to be received from the k regular OTs
-	q          [][]byte               // Bit matrix Q of size m × κ to be calculated in the OTExtension Phase
+	seeds      [][]byte               // Seeds of k/8 bytes to be received from the k regular OTs
+	q          *utils.BitMatrix       // Bit matrix Q of size m × κ to be calculated in the OTExtension Phase
+	extraOTs   int                    // Extra OTs sacrificed in the KOS correlation check.
+	offset     int                    // Bit position in the PRG output of the first OT, when extending OTs in chunks.
+	workers    int                    // Number of goroutines used for PRG expansion, transposition and hashing.
+	delta      []byte                 // Global correlation Δ of l bits (correlated OT only).
+	crHash     *utils.CRHash          // Fixed-key AES hash chosen with ChooseHashFunction, or nil for SHA-256.
+	hashKey    []byte                 // Key of crHash as chosen, before it is keyed for the session.
+	random     io.Reader              // Source of randomness set with SetRandom, or nil for crypto/rand.
+
+	sessionShare []byte // The OTSender's share of the session ID, chosen with StartSession.
+	sessionID    []byte // Session ID of the run, or nil before CompleteSession (see Session.go).
+
+	variableLengths bool // Whether every pair has its own length, and both messages are padded to it (see InitVariableLengths).
+
+	challengeSeed         []byte // The OTSender's share of the coin-tossed challenge seed (KOS only).
+	challengeSeedReceiver []byte // The OTReceiver's share of the coin-tossed challenge seed (KOS only).
 }
 
-func (sender *OTSender) Init(messages []*utils.MessagePair, k int, l int) {
+// Initialize the sender with its message pairs. Returns an error wrapping ErrInvalidSecurityParameter if k is not
+// 128, 192 or 256, or ErrInvalidMessageLength if l is not positive or a message is not an l-bit string.
+func (sender *OTSender) Init(messages []*utils.MessagePair, k int, l int) error {
+
+	if err := utils.ValidateSecurityParameter(k); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessagePairs(messages, l); err != nil {
+		return err
+	}
 
 	sender.l = l
 	sender.m = len(messages)
 	sender.k = k
 	sender.messages = messages // Message pairs (x0_j, x1_j) of l-bit strings, for every 1 ≤ j ≤ m.
-
+	return nil
 }
 
-// S choose a random list of 0's and 1's of length k: s = (s_1, ... , s_k)
-func (sender *OTSender) ChooseRandomS() {
-	sender.s = make([]byte, sender.k) // Allocate space for the array
+// Initialize the sender with message pairs of any lengths, where the messages of a pair may differ in length.
+// Both messages of pair j are padded to utils.PaddedLength, so the receiver learns the length of its own message
+// and the length of the longer message of the pair, but not which of the two messages is the longer one.
+// Returns an error wrapping ErrInvalidSecurityParameter if k is not 128, 192 or 256, or ErrInvalidMessageLength
+// if a message pair is missing.
+func (sender *OTSender) InitVariableLengths(messages []*utils.MessagePair, k int) error {
 
-	for i := uint(0); i < uint(sender.k); i++ {
-		randomBit, err := rand.Int(rand.Reader, big.NewInt(2)) // Generate a random bit
-		if err != nil {
-			panic("Error in ChooseRandomS: " + err.Error())
+	if err := utils.ValidateSecurityParameter(k); err != nil {
+		return err
+	}
+	for j, pair := range messages {
+		if pair == nil {
+			return fmt.Errorf("%w: message pair %d is missing", ErrInvalidMessageLength, j)
 		}
-		// Set 0 or 1 in the array
-		sender.s[i] = byte(randomBit.Int64())
 	}
+
+	sender.l = 0 // The bit length of pair j is 8·utils.PaddedLength(messages[j]).
+	sender.m = len(messages)
+	sender.k = k
+	sender.messages = messages
+	sender.variableLengths = true
+	return nil
+}
+
+// Method for extending OTs in chunks. The sender continues with the next chunk of message pairs, where offset
+// is the number of OTs extended before it, so the PRG columns continue from the same position. k and l are kept
+// from Init, and offset must be a multiple of 64.
+func (sender *OTSender) InitChunk(messages []*utils.MessagePair, offset int) error {
+
+	if err := utils.ValidateMessagePairs(messages, sender.l); err != nil {
+		return err
+	}
+	if err := validateOffset(offset); err != nil {
+		return err
+	}
+
+	sender.m = len(messages)
+	sender.messages = messages
+	sender.offset = offset
+	return nil
 }
 
-// DEBUGGING METHOD. S choose a fixed list of 0's and 1's of length k: s = (s_1, ... , s_k)
-func (sender *OTSender) ChooseFixedS() {
+// Initialize the sender for correlated OT with a global correlation Δ of l bits. Instead of supplying messages,
+// the sender receives m random pairs (x0_j, x1_j) with x1_j = x0_j ⊕ Δ from MakeAndSendCorrelatedCiphertexts.
+func (sender *OTSender) InitCorrelated(delta []byte, m int, k int, l int) error {
 
-	fixedS := []byte{
-		1, 1, 1, 1, // ... Define a fixed list of 0's and 1's up to sender.k elements
+	if err := validateParameters(k, l, m); err != nil {
+		return err
 	}
-	sender.s = make([]byte, sender.k) // Allocate space for the array
+	if err := utils.ValidateBytes("delta", delta, l); err != nil {
+		return err
+	}
+
+	sender.l = l
+	sender.m = m
+	sender.k = k
+	sender.delta = delta
+	return nil
+}
+
+// Initialize the sender for random OT, where the sender supplies no messages. The sender receives m random pairs
+// (H(q_j), H(q_j ⊕ s)) of l-bit strings from MakeRandomMessages.
+func (sender *OTSender) InitRandom(m int, k int, l int) error {
 
-	for i := 0; i < sender.k; i++ {
-		sender.s[i] = fixedS[i%len(fixedS)] // Make sure the number of fixed elements is at least sender.k
+	if err := validateParameters(k, l, m); err != nil {
+		return err
 	}
+
+	sender.l = l
+	sender.m = m
+	sender.k = k
+	return nil
 }
 
-// Method for the k regular OTs, where the OTSender plays the receiver with random string s = (s_1, ... , s_k) as input.
-func (sender *OTSender) Choose(elGamal *elgamal.ElGamal) []*utils.PublicKeyPair {
+// Messages returns the sender's message pairs. After MakeAndSendCorrelatedCiphertexts these are the
+// random correlated pairs (x0_j, x0_j ⊕ Δ).
+func (sender *OTSender) Messages() []*utils.MessagePair {
+	return sender.messages
+}
 
-	k := sender.k
+// SetRandom sets the source of the sender's randomness: its string s, challenge seed and hash key, and the ElGamal
+// keys in the base OTs. The default (nil) is crypto/rand, and the ElGamal's own source for the keys. Tests can
+// supply a seeded deterministic stream (utils.NewDeterministicReader) to reproduce an exact transcript.
+func (sender *OTSender) SetRandom(random io.Reader) {
+	sender.random = random
+}
 
-	// Generate secretkeys for each of the messages to be received
-	sender.secretKeys = make([]*big.Int, k)
-	for i := 0; i < k; i++ {
-		sender.secretKeys[i] = elGamal.MakeSecretKey()
+// SetWorkers sets the number of goroutines the sender uses to expand the PRG columns, transpose Q and
+// hash its rows. A value ≤ 0 uses parallel.DefaultWorkers (GOMAXPROCS), which is also the default.
+func (sender *OTSender) SetWorkers(workers int) {
+	sender.workers = workers
+}
+
+// workerCount returns the number of goroutines to use, defaulting to parallel.DefaultWorkers.
+func (sender *OTSender) workerCount() int {
+	if sender.workers <= 0 {
+		return parallel.DefaultWorkers()
 	}
+	return sender.workers
+}
 
-	// Make two public keys - one oblivious and one real for every message(seed) to be received.
-	// The bits from the sender's string s chooses which public key to use for each message (seed).
-	publicKeys := make([]*utils.PublicKeyPair, k)
-	for i := 0; i < k; i++ {
+// numOTs returns the number of OTs to extend, including the extra OTs sacrificed in the KOS correlation check.
+func (sender *OTSender) numOTs() int {
+	return sender.m + sender.extraOTs
+}
 
-		publicKeys[i] = &utils.PublicKeyPair{} // Initialize a new public key pair to store the keys for the current message
+// Method for the KOS protocol. The sender expects the OTReceiver to extend k + s extra OTs for the correlation check.
+func (sender *OTSender) AddCheckOTs() {
+	sender.extraOTs = sender.k + StatisticalSecurity
+}
 
-		if sender.s[i] == 0 {
-			publicKeys[i].MessageKey0 = elGamal.Gen(sender.secretKeys[i])
-			publicKeys[i].MessageKey1 = elGamal.OGen()
-		} else if sender.s[i] == 1 {
-			publicKeys[i].MessageKey0 = elGamal.OGen()
-			publicKeys[i].MessageKey1 = elGamal.Gen(sender.secretKeys[i])
-		} else {
-			panic("Receiver string s bits are not 0 or 1 in Choose")
-		}
+// S choose a random list of 0's and 1's of length k: s = (s_1, ... , s_k)
+func (sender *OTSender) ChooseRandomS() error {
+	s, err := utils.RandomPackedBitsFrom(sender.random, sender.k)
+	if err != nil {
+		return err
 	}
-	return publicKeys
+	sender.s = s // Packed into 64-bit words
+	return nil
+}
+
+// Method for the k regular OTs, where the OTSender plays the receiver with random string s = (s_1, ... , s_k) as input.
+// The sender makes two public keys - one oblivious and one real - for every seed to be received, as in ElGamalBaseOT.
+// Returns an error if the source of randomness fails.
+func (sender *OTSender) Choose(elGamal *elgamal.ElGamal) ([]*utils.PublicKeyPair, error) {
+	secretKeys, publicKeys, err := elGamalBaseOTKeys(elGamal.WithRandom(sender.random), sender.BaseOTChoiceBits())
+	if err != nil {
+		return nil, err
+	}
+	sender.secretKeys = secretKeys
+	sender.baseOTKeys = publicKeys
+	return publicKeys, nil
 }
 
 // Method to decrypt the Seeds (messages) sent by the OTReceiver, for the k regular OTs,
 // where the OTSender plays the receiver, and OTReceiver plays the sender.
-func (sender *OTSender) DecryptSeeds(ciphertextPairs []*utils.CiphertextPair, elGamal *elgamal.ElGamal) {
-
-	// Initialize a list of Seeds to be decrypted
-	plaintextSeeds := make([]*big.Int, len(ciphertextPairs))
+// The seeds are bound to the session ID, so CompleteSession must be called first if there is a session.
+// Returns an error wrapping ErrDimensionMismatch if there is not one ciphertext pair per base OT, or a seed is too long,
+// which is the case for a ciphertext encrypted under another session's public key.
+func (sender *OTSender) DecryptSeeds(ciphertextPairs []*utils.CiphertextPair, elGamal *elgamal.ElGamal) error {
+	seeds, err := elGamalBaseOTDecrypt(elGamal, sender.sessionID, sender.BaseOTChoiceBits(), sender.secretKeys, sender.baseOTKeys, ciphertextPairs, utils.SeedLength(sender.k))
+	if err != nil {
+		return err
+	}
+	return sender.ReceiveBaseOTSeeds(seeds)
+}
 
-	// Decrypt the message based on the receiver's bits from string s.
-	for i := 0; i < sender.k; i++ {
+// BaseOTChoiceBits returns the bits of the sender's string s, one byte per bit, which are the choice bits of the
+// sender in the base OTs (see BaseOT).
+func (sender *OTSender) BaseOTChoiceBits() []byte {
+	return utils.UnpackBits(sender.s, sender.k)
+}
 
-		if sender.s[i] == 0 {
-			plaintextSeeds[i] = elGamal.Decrypt(ciphertextPairs[i].Ciphertext0.C1, ciphertextPairs[i].Ciphertext0.C2, sender.secretKeys[i])
-		} else if sender.s[i] == 1 {
-			plaintextSeeds[i] = elGamal.Decrypt(ciphertextPairs[i].Ciphertext1.C1, ciphertextPairs[i].Ciphertext1.C2, sender.secretKeys[i])
-		} else {
-			panic("Receiver string s bits are not 0 or 1 in DecryptSeeds")
+// Method for receiving the seeds of the base OTs, the seed k^(s_i)_i of every pair i, e.g. from a BaseOT.
+// The PRG seeds of the session are derived from them (see Session.go). Returns an error wrapping
+// ErrDimensionMismatch if there are not k seeds, or ErrInvalidMessageLength if a seed does not have k/8 bytes.
+func (sender *OTSender) ReceiveBaseOTSeeds(seeds [][]byte) error {
+	if err := utils.ValidateCount("seeds", len(seeds), sender.k); err != nil {
+		return err
+	}
+	plaintextSeeds := make([][]byte, len(seeds))
+	for i, seed := range seeds {
+		if err := utils.ValidateBytes(fmt.Sprintf("seed %d", i), seed, 8*utils.SeedLength(sender.k)); err != nil {
+			return err
 		}
+		b := utils.GetBit(sender.s, i)
+		plaintextSeeds[i] = sessionSeed(sender.sessionID, i, b, maskSeed(sender.sessionID, i, b, seed))
 	}
-	sender.seeds = make([]*big.Int, sender.k)
 	sender.seeds = plaintextSeeds
+	return nil
 }
 
-// Method for generating the bit matrix Q of size m × κ.
-// Notice this method is inefficient, since it accesses the entire matrix T (e.g. m x k entries).
-func (sender *OTSender) GenerateMatrixQ(U [][]byte) {
+// Method for generating the bit matrix Q of size m × κ with rows packed into 64-bit words.
+// Notice this method is inefficient, since it accesses the entire matrix Q bit by bit (e.g. m x k entries).
+// U is the m × κ matrix from OTReceiver.GenerateMatrixTAndU. Returns an error wrapping ErrDimensionMismatch if U has another size.
+func (sender *OTSender) GenerateMatrixQ(U *utils.BitMatrix) error {
 
 	k := sender.k
-	m := sender.m
+	m := sender.numOTs()
 
-	// Initialize the matrix Q of size m × κ.
-	Q := make([][]byte, m) // m rows.
-	for i := range Q {
-		Q[i] = make([]byte, k) // k cols per row.
+	if err := validateMatrix("U", U, m, k); err != nil {
+		return err
 	}
 
+	// Initialize the matrix Q of size m × κ.
+	Q := utils.NewBitMatrix(m, k)
+
 	// The OTSender defines q^i = (s_i · u^i) ⊕ G(k^(s_i)_i. Note that q^i = (s_i · r) ⊕ t^i)
 	for i := 0; i < k; i++ {
 
-		bitstring, err := utils.PseudoRandomGenerator(sender.seeds[i], m)
+		bitstring, err := utils.PseudoRandomGeneratorWordsFrom(sender.seeds[i], sender.offset, m)
+		if err != nil {
+			return err
+		}
+		s_i := utils.GetBit(sender.s, i)
+
 		for j := 0; j < m; j++ {
+			// If the bit from string s is 0, q^i = G(k^(0)_i. If it is 1, q^i = u^i ⊕ G(k^(1)_i
+			Q.SetBit(j, i, (s_i&U.Bit(j, i))^utils.GetBit(bitstring, j))
+		}
+	}
+	sender.q = Q
+	return nil
+}
+
+// generateMatrixQRowWise generates the matrix Q of size κ × m row-wise, which is the transpose of the m × κ
+// matrix in the protocol. U is the κ × m matrix sent by the OTReceiver.
+func (sender *OTSender) generateMatrixQRowWise(U *utils.BitMatrix) (*utils.BitMatrix, error) {
+
+	k := sender.k
+	m := sender.numOTs()
+
+	if err := validateMatrix("U", U, k, m); err != nil {
+		return nil, err
+	}
+
+	// Initialize the matrix Q of size κ × m (transposed later).
+	Q := utils.NewBitMatrix(k, m)
+
+	// The OTSender defines q^i = (s_i · u^i) ⊕ G(k^(s_i)_i. Note that q^i = (s_i · r) ⊕ t^i)
+	// The columns are expanded in parallel, since every column only depends on its own seed.
+	err := parallel.ForErr(k, sender.workerCount(), func(start int, end int) error {
+		for i := start; i < end; i++ {
+
+			bitstring, err := utils.PseudoRandomGeneratorWordsFrom(sender.seeds[i], sender.offset, m)
 			if err != nil {
-				panic("Error from pseudoRandomGenerator in GenerateQMatrix: " + err.Error())
+				return err
 			}
 
-			// If the bit from string s is 0, q^i = ⊕ G(k^(0)_i
-			if sender.s[i] == 0 {
+			// If the bit from string s is 0, q^i = G(k^(0)_i
+			if utils.GetBit(sender.s, i) == 0 {
 
-				Q[j][i] = bitstring[j]
+				copy(Q.Row(i), bitstring)
 
 				// If the bit from string s is 1, q^i = u^i ⊕ G(k^(1)_i
-			} else if sender.s[i] == 1 {
-
-				Q[j][i] = U[j][i] ^ bitstring[j]
-
 			} else {
-				panic("Receiver s idx is not 0 or 1 in GenerateQMatrix")
+
+				utils.XORWords(Q.Row(i), U.Row(i), bitstring)
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	sender.q = Q
+	return Q, nil
 }
 
 // A more efficient method for generating the bit matrix Q of size m × κ.
 // It generates the matrix Q row-wise for transposing afterwards.
-func (sender *OTSender) GenerateMatrixQTranspose(U [][]byte) {
-
-	k := sender.k
-	m := sender.m
+// Returns an error wrapping ErrDimensionMismatch if U is not the κ × m matrix from the OTReceiver.
+func (sender *OTSender) GenerateMatrixQTranspose(U *utils.BitMatrix) error {
 
-	// Initialize the matrix Q of size κ × m (transposed later).
-	Q := make([][]byte, k) // m rows.
-	for i := range Q {
-		Q[i] = make([]byte, m) // k col per row.
+	Q, err := sender.generateMatrixQRowWise(U)
+	if err != nil {
+		return err
 	}
+	sender.q = utils.TransposeBitMatrix(Q) // Transpose the matrix Q
+	return nil
+}
 
-	// The OTSender defines q^i = (s_i · u^i) ⊕ G(k^(s_i)_i. Note that q^i = (s_i · r) ⊕ t^i)
-	for i := 0; i < k; i++ {
-
-		bitstring, err := utils.PseudoRandomGenerator(sender.seeds[i], m)
-		if err != nil {
-			panic("Error from pseudoRandomGenerator in GenerateQMatrix: " + err.Error())
-		}
+// An even more efficient method for generating the bit matrix Q of size m × κ.
+// It generates the matrix Q row-wise for transposing afterwards using Eklundh's algorithm on 64-bit words.
+// The transposition uses the sender's worker count if multithreaded is true, and a single goroutine otherwise.
+// Returns an error wrapping ErrDimensionMismatch if U is not the κ × m matrix from the OTReceiver.
+func (sender *OTSender) GenerateMatrixQEklundh(U *utils.BitMatrix, multithreaded bool) error {
+
+	Q, err := sender.generateMatrixQRowWise(U)
+	if err != nil {
+		return err
+	}
 
-		// If the bit from string s is 0, q^i = ⊕ G(k^(0)_i
-		if sender.s[i] == 0 {
+	workers := 1
+	if multithreaded {
+		workers = sender.workerCount()
+	}
+	sender.q = utils.EklundhTransposeBitMatrix(Q, workers) // Transpose the matrix Q using Eklundh's algorithm
+	return nil
+}
 
-			Q[i] = bitstring
+// Method for the coin tossing of the KOS challenge. The sender chooses a random seed after receiving U,
+// and sends a commitment H(seed) to the OTReceiver before seeing the receiver's seed.
+func (sender *OTSender) CommitChallengeSeed() ([]byte, error) {
 
-			// If the bit from string s is 1, q^i = u^i ⊕ G(k^(1)_i
-		} else if sender.s[i] == 1 {
+	challengeSeed, err := utils.RandomSeedFrom(sender.random, challengeSeedLength)
+	if err != nil {
+		return nil, err
+	}
+	sender.challengeSeed = challengeSeed
+	commitment := sha256.Sum256(sender.challengeSeed)
+	return commitment[:], nil // Send the commitment to the OTReceiver
+}
 
-			xorRes, err := xor.XORBytes(U[i], bitstring)
-			if err != nil {
-				panic("Error from XORBytes in GenerateMatrixQTranspose: " + err.Error())
-			}
-			Q[i] = xorRes
+// Method for receiving the OTReceiver's challenge seed. The sender opens its own seed in return,
+// after which both parties derive the challenges χ_j from the two seeds.
+// Returns an error wrapping ErrDimensionMismatch if the receiver's seed has the wrong length.
+func (sender *OTSender) OpenChallengeSeed(seedReceiver []byte) ([]byte, error) {
 
-		} else {
-			panic("Receiver S idx are not 0 or 1 in GenerateMatrixQTranspose")
-		}
+	if len(seedReceiver) != challengeSeedLength {
+		return nil, fmt.Errorf("%w: challenge seed has %d bytes, expected %d", ErrDimensionMismatch, len(seedReceiver), challengeSeedLength)
 	}
-	sender.q = utils.TransposeMatrix(Q) // Transpose the matrix Q
+	sender.challengeSeedReceiver = seedReceiver
+	return sender.challengeSeed, nil // Send the opened seed to the OTReceiver
 }
 
-// An even more efficient method for generating the bit matrix Q of size m × κ.
-// It generates the matrix Q row-wise for transposing afterwards using Eklundh's algorithm.
-func (sender *OTSender) GenerateMatrixQEklundh(U [][]byte, multithreaded bool) {
+// Method for verifying the KOS correlation check from the OTReceiver. If every row satisfies q_j = t_j ⊕ (r_j · s),
+// then ⊕_j χ_j·q_j = t ⊕ x·s in GF(2^k). A U matrix that is not consistent with a single vector r makes the check
+// fail except with probability 2^-s, in which case ErrCorrelationCheckFailed is returned.
+func (sender *OTSender) VerifyCorrelationCheck(check *utils.CorrelationCheck) error {
 
 	k := sender.k
-	m := sender.m
+	numOTs := sender.numOTs()
+	words := utils.WordsFor(k)
 
-	// Initialize the matrix Q of size m × κ (transposed later).
-	Q := make([][]byte, k) // m rows.
-	for i := range Q {
-		Q[i] = make([]byte, m) // k columns per row.
+	if check == nil || len(check.X) != words || len(check.T) != words {
+		return ErrCorrelationCheckFailed
 	}
 
-	// The OTSender defines q^i = (s_i · u^i) ⊕ G(k^(s_i)_i. Note that q^i = (s_i · r) ⊕ t^i)
-	for i := 0; i < k; i++ {
+	q := make([]uint64, 2*words) // Unreduced sum of products, reduced once at the end.
+	chi := make([]uint64, words)
 
-		bitstring, err := utils.PseudoRandomGenerator(sender.seeds[i], m)
-		if err != nil {
-			panic("Error from pseudoRandomGenerator in GenerateQMatrixEklundh: " + err.Error())
+	generator, err := newChallengeGenerator(sender.challengeSeed, sender.challengeSeedReceiver, k)
+	if err != nil {
+		return err
+	}
+	for j := 0; j < numOTs; j++ {
+		generator.next(chi)
+		utils.CarrylessMultiplyAdd(q, chi, sender.q.Row(j))
+	}
+	reducedQ, err := utils.GFReduce(q, k)
+	if err != nil {
+		return err
+	}
+
+	// Compute t ⊕ x·s and compare it with q.
+	xs, err := utils.GFMultiply(check.X, sender.s, k)
+	if err != nil {
+		return err
+	}
+	utils.XORWords(xs, xs, check.T)
+
+	for i := range reducedQ {
+		if reducedQ[i] != xs[i] {
+			return ErrCorrelationCheckFailed
 		}
+	}
+	return nil
+}
 
-		// If the bit from string s is 0, q^i = ⊕ G(k^(0)_i
-		if sender.s[i] == 0 {
+// Method for generating the ciphertexts to be sent to the OTReceiver.
+// The OTSender sends m ciphertext pairs (y0_j, y1_j) of l-bit strings, for every 1 ≤ j ≤ m,
+// where y0_j = x0_j ⊕ H(q_j) and y1_j = x1_j ⊕ H(q_j ⊕ s). With InitVariableLengths, x0_j and x1_j are the
+// padded messages, and both strings of pair j have the padded length of the pair.
+func (sender *OTSender) MakeAndSendCiphertexts() ([]*utils.ByteCiphertextPair, error) {
 
-			Q[i] = bitstring
+	m := sender.m
+	l := sender.l
 
-			// If the bit from string s is 1, q^i = u^i ⊕ G(k^(1)_i
-		} else if sender.s[i] == 1 {
+	ByteCiphertextPairs := make([]*utils.ByteCiphertextPair, m)
 
-			xorRes, err := xor.XORBytes(U[i], bitstring)
-			if err != nil {
-				panic("Error from XORBytes in GenerateQMatrixEklundh: " + err.Error())
+	// The rows are hashed in parallel, since every ciphertext pair only depends on its own row of Q.
+	err := parallel.ForErr(m, sender.workerCount(), func(start int, end int) error {
+		q_jXORs := make([]uint64, len(sender.s))
+
+		for j := start; j < end; j++ {
+			x0_j := sender.messages[j].Message0
+			x1_j := sender.messages[j].Message1
+			l_j := l
+
+			// Pad both messages to the length of the pair, so the ciphertexts do not reveal which one is longer.
+			if sender.variableLengths {
+				length := utils.PaddedLength(sender.messages[j])
+				x0_j = utils.PadMessage(x0_j, length)
+				x1_j = utils.PadMessage(x1_j, length)
+				l_j = 8 * length
 			}
-			Q[i] = xorRes
 
-		} else {
-			panic("Receiver S idx are not 0 or 1 in GenerateQMatrix")
+			q_j := sender.q.Row(j)
+			utils.XORWords(q_jXORs, q_j, sender.s) // XOR the j'th row of Q with the Sender's string s.
+
+			hash0 := hashRow(sender.crHash, sender.sessionID, sender.offset+j, q_j, l_j)     // Generate hash of length l from the j'th row of Q.
+			hash1 := hashRow(sender.crHash, sender.sessionID, sender.offset+j, q_jXORs, l_j) // Generate hash of length l from the XOR of the j'th row of Q and the Sender's string s.
+
+			y0_j, err1 := xor.XORBytes(x0_j, hash0)
+			y1_j, err2 := xor.XORBytes(x1_j, hash1)
+			if err := errors.Join(err1, err2); err != nil {
+				return err
+			}
+
+			ByteCiphertextPairs[j] = &utils.ByteCiphertextPair{Y0: y0_j, Y1: y1_j}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	sender.q = utils.EklundhTranspose(Q, multithreaded) // Transpose the matrix Q using Eklundh's algorithm
+	return ByteCiphertextPairs, nil
 }
 
-// Method for generating the ciphertexts to be sent to the OTReceiver.
-// The OTSender sends m ciphertext pairs (y0_j, y1_j) of l-bit strings, for every 1 ≤ j ≤ m,
-// where y0_j = x0_j ⊕ H(q_j) and y1_j = x1_j ⊕ H(q_j ⊕ s).
-func (sender *OTSender) MakeAndSendCiphertexts() []*utils.ByteCiphertextPair {
+// Method for random OT. Instead of sending ciphertexts, the sender outputs the m random pairs
+// (x0_j, x1_j) = (H(q_j), H(q_j ⊕ s)) of l-bit strings, which are also stored as the sender's messages.
+func (sender *OTSender) MakeRandomMessages() []*utils.MessagePair {
 
 	m := sender.m
 	l := sender.l
 
-	ByteCiphertextPairs := make([]*utils.ByteCiphertextPair, m)
+	sender.messages = make([]*utils.MessagePair, m)
+	q_jXORs := make([]uint64, len(sender.s))
 
 	for j := 0; j < m; j++ {
-		x0_j := sender.messages[j].Message0
-		x1_j := sender.messages[j].Message1
+		q_j := sender.q.Row(j)
+		utils.XORWords(q_jXORs, q_j, sender.s) // XOR the j'th row of Q with the Sender's string s.
 
-		xor_res, err := xor.XORBytes(sender.q[j], sender.s) // XOR the j'th row of Q with the Sender's string s.
-		if err != nil {
-			panic("Error from XORBytes in MakeAndSendCiphertexts: " + err.Error())
+		sender.messages[j] = &utils.MessagePair{
+			Message0: hashRow(sender.crHash, sender.sessionID, sender.offset+j, q_j, l),     // H(q_j)
+			Message1: hashRow(sender.crHash, sender.sessionID, sender.offset+j, q_jXORs, l), // H(q_j ⊕ s)
 		}
+	}
+	return sender.messages
+}
+
+// Method for generating the correlated OT ciphertexts to be sent to the OTReceiver.
+// The sender's random messages are x0_j = H(q_j) and x1_j = x0_j ⊕ Δ, so only one l-bit string
+// y_j = H(q_j) ⊕ H(q_j ⊕ s) ⊕ Δ is sent per OT instead of the two strings of a ByteCiphertextPair.
+// The pairs (x0_j, x1_j) are stored as the sender's messages and can be read with Messages.
+func (sender *OTSender) MakeAndSendCorrelatedCiphertexts() ([][]byte, error) {
+
+	m := sender.m
+	l := sender.l
 
-		hash0 := utils.Hash(sender.q[j], l) // Generate hash of length l from the j'th row of Q.
-		hash1 := utils.Hash(xor_res, l)     // Generate hash of length l from the XOR of the j'th row of Q and the Sender's string s.
+	ciphertexts := make([][]byte, m)
+	sender.messages = make([]*utils.MessagePair, m)
+	q_jXORs := make([]uint64, len(sender.s))
+
+	for j := 0; j < m; j++ {
+		q_j := sender.q.Row(j)
+		utils.XORWords(q_jXORs, q_j, sender.s) // XOR the j'th row of Q with the Sender's string s.
 
-		y0_j, err1 := xor.XORBytes(x0_j, hash0)
-		y1_j, err2 := xor.XORBytes(x1_j, hash1)
+		x0_j := hashRow(sender.crHash, sender.sessionID, sender.offset+j, q_j, l)      // Random message x0_j = H(q_j)
+		hash1 := hashRow(sender.crHash, sender.sessionID, sender.offset+j, q_jXORs, l) // H(q_j ⊕ s)
 
-		if err1 != nil || err2 != nil {
-			panic("Error from XORBytes in MakeAndSendCiphertexts: " + err1.Error() + " " + err2.Error())
+		x1_j, err1 := xor.XORBytes(x0_j, sender.delta)
+		y_j, err2 := xor.XORBytes(x1_j, hash1)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
 		}
 
-		ByteCiphertextPairs[j] = &utils.ByteCiphertextPair{Y0: y0_j, Y1: y1_j}
+		sender.messages[j] = &utils.MessagePair{Message0: x0_j, Message1: x1_j}
+		ciphertexts[j] = y_j
 	}
-	return ByteCiphertextPairs
+	return ciphertexts, nil
 }
@@ -0,0 +1,91 @@
+// OTSenderN.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/utils"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/xor"
+)
+
+// OTSenderN is the sender of the 1-out-of-N OT extension (KK13). It reuses the base OT phase and
+// the matrix Q of the OTSender with k = WalshHadamardLength, where row q_j = t_j ⊕ (C(r_j) ∧ s).
+type OTSenderN struct {
+	OTSender
+	n      int                   // Number of messages in each tuple.
+	tuples []*utils.MessageTuple // Sender S holds m tuples (x^0_j, ..., x^(N-1)_j) of l-bit strings.
+}
+
+// Initialize the sender with its message tuples. Returns an error wrapping ErrInvalidParameter if N is not between
+// 2 and 256, ErrDimensionMismatch if a tuple does not contain N messages, or ErrInvalidMessageLength if l is not
+// positive or a message is not an l-bit string.
+func (sender *OTSenderN) Init(tuples []*utils.MessageTuple, n int, l int) error {
+
+	if err := validateN(n); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return err
+	}
+	for j, tuple := range tuples {
+		if tuple == nil {
+			return fmt.Errorf("%w: message tuple %d is missing", ErrDimensionMismatch, j)
+		}
+		if err := utils.ValidateCount(fmt.Sprintf("message tuple %d", j), len(tuple.Messages), n); err != nil {
+			return err
+		}
+		for r, message := range tuple.Messages {
+			if err := utils.ValidateBytes(fmt.Sprintf("message %d of tuple %d", r, j), message, l); err != nil {
+				return err
+			}
+		}
+	}
+
+	sender.l = l
+	sender.m = len(tuples)
+	sender.k = WalshHadamardLength
+	sender.n = n
+	sender.tuples = tuples
+	return nil
+}
+
+// Method for generating the ciphertexts to be sent to the OTReceiverN.
+// The OTSenderN sends m ciphertext tuples, where y^r_j = x^r_j ⊕ H(j, q_j ⊕ (C(r) ∧ s)) for every r ∈ [0, N).
+// Only for r = r_j is q_j ⊕ (C(r) ∧ s) = t_j, and the minimum distance of the code hides the other pads.
+func (sender *OTSenderN) MakeAndSendCiphertexts() ([]*utils.ByteCiphertextTuple, error) {
+
+	m := sender.m
+	l := sender.l
+	n := sender.n
+
+	// Precompute C(r) ∧ s for every r, which is the same for all rows.
+	maskedCodewords := make([][]uint64, n)
+	for r := 0; r < n; r++ {
+		codeword := walshHadamardCodeword(byte(r))
+		for i := range codeword {
+			codeword[i] &= sender.s[i]
+		}
+		maskedCodewords[r] = codeword
+	}
+
+	ByteCiphertextTuples := make([]*utils.ByteCiphertextTuple, m)
+	row := make([]uint64, len(sender.s))
+
+	for j := 0; j < m; j++ {
+		q_j := sender.q.Row(j)
+		tuple := &utils.ByteCiphertextTuple{Y: make([][]byte, n)}
+
+		for r := 0; r < n; r++ {
+			utils.XORWords(row, q_j, maskedCodewords[r])
+			hash := hashIndexedRow(sender.sessionID, j, row, l) // Generate hash of length l from j and q_j ⊕ (C(r) ∧ s).
+
+			y, err := xor.XORBytes(sender.tuples[j].Messages[r], hash)
+			if err != nil {
+				return nil, err
+			}
+			tuple.Y[r] = y
+		}
+		ByteCiphertextTuples[j] = tuple
+	}
+	return ByteCiphertextTuples, nil
+}
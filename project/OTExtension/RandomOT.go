@@ -0,0 +1,114 @@
+// RandomOT.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/utils"
+	"errors"
+	"fmt"
+
+	"github.com/hashicorp/vault/sdk/helper/xor"
+)
+
+// RandomOTSender stores the sender's output of m random OTs, which can be precomputed before the real messages
+// are known and derandomized into chosen-message OTs online with MakeAndSendCiphertexts.
+type RandomOTSender struct {
+	Pairs []*utils.MessagePair // Random pairs (m0_j, m1_j) = (H(q_j), H(q_j ⊕ s)).
+}
+
+// RandomOTReceiver stores the receiver's output of m random OTs, which can be precomputed before the real
+// selection bits are known and derandomized into chosen-message OTs online with MakeCorrections.
+type RandomOTReceiver struct {
+	ChoiceBits    []byte   // Random choice bits c_j.
+	Messages      [][]byte // Random messages m^(c_j)_j = H(t_j).
+	selectionBits []byte   // Real selection bits b_j, stored between sending the corrections and decrypting.
+}
+
+// Method for the receiver's online message in Beaver's derandomization.
+// The receiver sends the corrections d_j = b_j ⊕ c_j for its real selection bits b_j, which hide b_j since c_j is random.
+// Returns an error wrapping ErrInvalidChoiceBit or ErrDimensionMismatch if the selection bits do not match the random OTs.
+func (receiver *RandomOTReceiver) MakeCorrections(selectionBits []byte) ([]byte, error) {
+
+	if err := utils.ValidateSelectionBits(selectionBits); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateCount("selectionBits", len(selectionBits), len(receiver.ChoiceBits)); err != nil {
+		return nil, err
+	}
+
+	receiver.selectionBits = selectionBits
+
+	corrections := make([]byte, len(selectionBits))
+	for j, b_j := range selectionBits {
+		corrections[j] = b_j ^ receiver.ChoiceBits[j]
+	}
+	return corrections, nil // Send the corrections to the sender
+}
+
+// Method for the sender's online message in Beaver's derandomization.
+// The sender sends y0_j = x0_j ⊕ m^(d_j)_j and y1_j = x1_j ⊕ m^(1 ⊕ d_j)_j for its real messages (x0_j, x1_j).
+// Returns an error wrapping ErrDimensionMismatch, ErrInvalidChoiceBit or ErrInvalidMessageLength if the corrections or
+// messages do not match the random OTs.
+func (sender *RandomOTSender) MakeAndSendCiphertexts(corrections []byte, messages []*utils.MessagePair) ([]*utils.ByteCiphertextPair, error) {
+
+	if err := utils.ValidateCount("messages", len(messages), len(sender.Pairs)); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateCount("corrections", len(corrections), len(sender.Pairs)); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateSelectionBits(corrections); err != nil {
+		return nil, err
+	}
+	for j, message := range messages {
+		if message == nil || len(message.Message0) != len(sender.Pairs[j].Message0) || len(message.Message1) != len(sender.Pairs[j].Message1) {
+			return nil, fmt.Errorf("%w: message pair %d does not have the length of the random messages", ErrInvalidMessageLength, j)
+		}
+	}
+
+	ByteCiphertextPairs := make([]*utils.ByteCiphertextPair, len(messages))
+
+	for j, message := range messages {
+		pad0, pad1 := sender.Pairs[j].Message0, sender.Pairs[j].Message1
+		if corrections[j] == 1 {
+			pad0, pad1 = pad1, pad0 // Swap the random pair if the receiver's real choice differs from its random choice
+		}
+
+		y0_j, err1 := xor.XORBytes(message.Message0, pad0)
+		y1_j, err2 := xor.XORBytes(message.Message1, pad1)
+		if err := errors.Join(err1, err2); err != nil {
+			return nil, err
+		}
+
+		ByteCiphertextPairs[j] = &utils.ByteCiphertextPair{Y0: y0_j, Y1: y1_j}
+	}
+	return ByteCiphertextPairs, nil
+}
+
+// Method for decrypting the sender's online message in Beaver's derandomization.
+// The receiver computes x^(b_j)_j = y^(b_j)_j ⊕ m^(c_j)_j, since y^(b_j)_j is masked with m^(b_j ⊕ d_j)_j = m^(c_j)_j.
+// Returns an error wrapping ErrDimensionMismatch if there is not one ciphertext pair per correction.
+func (receiver *RandomOTReceiver) DecryptCiphertexts(ByteCiphertextPairs []*utils.ByteCiphertextPair) ([][]byte, error) {
+
+	if err := utils.ValidateCount("ByteCiphertextPairs", len(ByteCiphertextPairs), len(receiver.selectionBits)); err != nil {
+		return nil, err
+	}
+
+	plaintexts := make([][]byte, len(ByteCiphertextPairs))
+
+	for j, pair := range ByteCiphertextPairs {
+		if pair == nil {
+			return nil, fmt.Errorf("%w: ciphertext pair %d is missing", ErrDimensionMismatch, j)
+		}
+		y_j := pair.Y0
+		if receiver.selectionBits[j] == 1 {
+			y_j = pair.Y1
+		}
+
+		xor, err := xor.XORBytes(y_j, receiver.Messages[j])
+		if err != nil {
+			return nil, fmt.Errorf("%w: ciphertext pair %d: %v", ErrInvalidMessageLength, j, err)
+		}
+		plaintexts[j] = xor
+	}
+	return plaintexts, nil
+}
@@ -0,0 +1,111 @@
+// Session.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/utils"
+	"fmt"
+)
+
+// The session ID of a run is negotiated in the base OT phase without extra rounds: the parties send their shares with
+// the first message of the base OTs in each direction, e.g. the public keys and the seed ciphertexts of ElGamalBaseOT
+// (see utils.NewSessionID), encoded with utils.MarshalSessionShare. The session ID masks the seeds in the base OTs
+// and binds their encryptions to the transcript (see BaseOT), derives the PRG seeds from the transferred seeds, and
+// keys the row hashes, so messages replayed from another session decrypt to garbage or are rejected. Parties that never negotiate a session ID, e.g. in tests of single steps, run the protocol without it.
+
+// Method for starting the session. The sender chooses its random share of the session ID, which it sends to the
+// receiver together with its first message of the base OTs.
+func (sender *OTSender) StartSession() ([]byte, error) {
+	share, err := utils.RandomSeedFrom(sender.random, utils.SessionIDLength)
+	if err != nil {
+		return nil, err
+	}
+	sender.sessionShare = share
+	sender.sessionID = nil
+	return share, nil
+}
+
+// Method for joining the session started by the sender. The receiver chooses its own share, which it returns to be
+// sent with its first message of the base OTs, and derives the session ID. Must be called before EncryptSeeds or BaseOTSeeds.
+// Returns an error wrapping ErrInvalidMessageLength if the sender's share does not have utils.SessionIDLength bytes.
+func (receiver *OTReceiver) JoinSession(senderShare []byte) ([]byte, error) {
+	share, err := utils.RandomSeedFrom(receiver.random, utils.SessionIDLength)
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := utils.NewSessionID(senderShare, share)
+	if err != nil {
+		return nil, err
+	}
+	crHash, err := sessionCRHash(receiver.hashKey, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	receiver.sessionID = sessionID
+	receiver.crHash = crHash
+	return share, nil
+}
+
+// Method for completing the session with the receiver's share. Must be called before DecryptSeeds or
+// ReceiveBaseOTSeeds.
+// Returns an error wrapping ErrInvalidParameter if StartSession was not called, or ErrInvalidMessageLength if the
+// receiver's share does not have utils.SessionIDLength bytes.
+func (sender *OTSender) CompleteSession(receiverShare []byte) error {
+	if sender.sessionShare == nil {
+		return fmt.Errorf("%w: StartSession must be called before CompleteSession", ErrInvalidParameter)
+	}
+	sessionID, err := utils.NewSessionID(sender.sessionShare, receiverShare)
+	if err != nil {
+		return err
+	}
+	crHash, err := sessionCRHash(sender.hashKey, sessionID)
+	if err != nil {
+		return err
+	}
+	sender.sessionID = sessionID
+	sender.crHash = crHash
+	return nil
+}
+
+// SessionID returns the sender's session ID, or nil before CompleteSession.
+func (sender *OTSender) SessionID() []byte {
+	return sender.sessionID
+}
+
+// SessionID returns the receiver's session ID, or nil before JoinSession.
+func (receiver *OTReceiver) SessionID() []byte {
+	return receiver.sessionID
+}
+
+// sessionCRHash creates the fixed-key AES row hash from the sender's hash key, keyed for the session if there is a
+// session ID. Returns nil for a nil key, i.e. for HashSHA256.
+func sessionCRHash(key []byte, sessionID []byte) (*utils.CRHash, error) {
+	if key == nil {
+		return nil, nil
+	}
+	if sessionID != nil {
+		key = utils.SessionHashKey(sessionID, key)
+	}
+	crHash, err := utils.NewCRHash(key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidParameter, err)
+	}
+	return crHash, nil
+}
+
+// sessionSeed turns seed b of base OT i, as transferred in the base OT, into the PRG seed of the session.
+// Without a session ID the seed is used as it is.
+func sessionSeed(sessionID []byte, i int, b byte, seed []byte) []byte {
+	if sessionID == nil {
+		return seed
+	}
+	return utils.SessionPRGSeed(sessionID, i, b, seed)
+}
+
+// maskSeed masks or unmasks seed b of base OT i for its encryption in the session.
+// Without a session ID the seed is encrypted as it is.
+func maskSeed(sessionID []byte, i int, b byte, seed []byte) []byte {
+	if sessionID == nil {
+		return seed
+	}
+	return utils.SessionMaskSeed(sessionID, i, b, seed)
+}
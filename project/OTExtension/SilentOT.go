@@ -0,0 +1,231 @@
+// SilentOT.go
+package OTExtension
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"cryptographic-computing/project/parallel"
+	"cryptographic-computing/project/utils"
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+)
+
+// Silent OT expands a small pool of base correlated OTs (COTs) into many random COTs with the same Δ, using a
+// pseudorandom correlation generator from the LPN assumption with regular noise, as in Ferret (Yang et al. 2020).
+// A COT is a pair of 128-bit strings (v_j, v_j ⊕ Δ) for the sender, and a choice bit u_j with w_j = v_j ⊕ u_j·Δ
+// for the receiver. One iteration produces N COTs as
+//
+//	x = u'·A ⊕ e,  z = w'·A ⊕ r,  y = v'·A ⊕ s,
+//
+// where (u', w', v') are K base COTs for the LPN secret, A is a public K × N local linear code, and (e, r, s) are
+// N COTs where e is a regular noise vector of weight T, one point in each of the T blocks of N/T entries.
+// The noise COTs are made with one GGM tree per block (a puncturable PRF), which only costs log2(N/T) base COTs and
+// O(log2(N/T)) communication per block. Then z = y ⊕ x·Δ, and the first K + T·log2(N/T) outputs are kept as the
+// base COTs of the next iteration, so the communication per iteration is sublinear in N.
+// This is the semi-honest version of the protocol: no consistency check of the trees is made.
+
+// block is a 128-bit string of a COT, packed into two words as elsewhere in the package.
+type block = [2]uint64
+
+// SilentOTParameters are the LPN parameters of one silent OT iteration.
+type SilentOTParameters struct {
+	N int // Number of COTs produced per iteration, a multiple of T.
+	K int // Dimension of the LPN secret, i.e. the number of base COTs for the secret.
+	T int // Number of noise positions. N/T must be a power of two.
+}
+
+// Parameter sets from the Ferret paper for 128-bit security with regular noise. FerretParameters gives
+// 10,198,341 COTs per iteration, and FerretSmallParameters, which needs fewer base COTs, gives 602,114.
+var (
+	FerretParameters      = SilentOTParameters{N: 10805248, K: 589760, T: 1319}
+	FerretSmallParameters = SilentOTParameters{N: 642048, K: 19870, T: 2508}
+)
+
+// lpnWeight is the number d of nonzero entries in each column of the local linear code A.
+const lpnWeight = 10
+
+// Validate checks that the parameters describe an iteration that produces more COTs than it consumes.
+func (params SilentOTParameters) Validate() error {
+	if params.T <= 0 || params.N <= 0 || params.N%params.T != 0 {
+		return fmt.Errorf("%w: N must be a positive multiple of T, got N = %d and T = %d", ErrInvalidParameter, params.N, params.T)
+	}
+	blockSize := params.N / params.T
+	if blockSize < 2 || blockSize&(blockSize-1) != 0 || blockSize > 1<<30 {
+		return fmt.Errorf("%w: N/T must be a power of two between 2 and 2^30, got %d", ErrInvalidParameter, blockSize)
+	}
+	if params.K < lpnWeight {
+		return fmt.Errorf("%w: K must be at least %d, got %d", ErrInvalidParameter, lpnWeight, params.K)
+	}
+	if params.N <= params.BaseCOTs() {
+		return fmt.Errorf("%w: N = %d must exceed the %d base COTs used per iteration", ErrInvalidParameter, params.N, params.BaseCOTs())
+	}
+	return nil
+}
+
+// depth returns the depth h = log2(N/T) of the GGM trees.
+func (params SilentOTParameters) depth() int {
+	return bits.TrailingZeros(uint(params.N / params.T))
+}
+
+// BaseCOTs returns the number of base COTs used per iteration: T·h for the GGM trees and K for the LPN secret.
+// The base COTs of the trees come first in the pool.
+func (params SilentOTParameters) BaseCOTs() int {
+	return params.T*params.depth() + params.K
+}
+
+// Outputs returns the number of COTs an iteration gives to the caller, after keeping the base COTs of the next one.
+func (params SilentOTParameters) Outputs() int {
+	return params.N - params.BaseCOTs()
+}
+
+// Method for XORing two blocks.
+func xorBlock(a block, b block) block {
+	return block{a[0] ^ b[0], a[1] ^ b[1]}
+}
+
+// Method for converting a 16-byte string to a block, in the little-endian word order of utils.WordsToBytes.
+func bytesToBlock(bytes []byte) block {
+	return block{binary.LittleEndian.Uint64(bytes[0:8]), binary.LittleEndian.Uint64(bytes[8:16])}
+}
+
+// ggmPRG is the length-doubling PRG G(x) = (π_0(x) ⊕ x, π_1(x) ⊕ x) of the GGM trees, where π_0 and π_1 are AES under
+// two fixed public keys. Both parties must expand the trees in the same way.
+type ggmPRG struct {
+	left  cipher.Block
+	right cipher.Block
+}
+
+// Method for creating the GGM PRG with the fixed keys 0 and 1.
+func newGGMPRG() (*ggmPRG, error) {
+	key := make([]byte, aes.BlockSize)
+	left, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	key[0] = 1
+	right, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	return &ggmPRG{left: left, right: right}, nil
+}
+
+// Method for expanding a node of a GGM tree into its left and right children.
+func (prg *ggmPRG) expand(node block) (block, block) {
+	var input, output [aes.BlockSize]byte
+	binary.LittleEndian.PutUint64(input[0:8], node[0])
+	binary.LittleEndian.PutUint64(input[8:16], node[1])
+
+	prg.left.Encrypt(output[:], input[:])
+	left := xorBlock(bytesToBlock(output[:]), node)
+	prg.right.Encrypt(output[:], input[:])
+	right := xorBlock(bytesToBlock(output[:]), node)
+	return left, right
+}
+
+// Method for expanding a level of a GGM tree in place: nodes[0:width] are replaced by their 2·width children,
+// where the children of node i are at 2i and 2i+1.
+func (prg *ggmPRG) expandLevel(nodes []block, width int) {
+	for i := width - 1; i >= 0; i-- {
+		nodes[2*i], nodes[2*i+1] = prg.expand(nodes[i])
+	}
+}
+
+// lpnCode is the public K × N local linear code A of the LPN assumption, where column j has lpnWeight random
+// nonzero rows. The rows are derived from a seed chosen by the sender, by encrypting the counters (j, c) with AES,
+// so every column can be computed independently.
+type lpnCode struct {
+	cipher cipher.Block
+	k      int
+}
+
+// Method for creating the code A for LPN dimension k from a 16-byte seed.
+func newLPNCode(seed []byte, k int) (*lpnCode, error) {
+	aesCipher, err := aes.NewCipher(seed)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	return &lpnCode{cipher: aesCipher, k: k}, nil
+}
+
+// Method for computing the nonzero rows of column j of A.
+func (code *lpnCode) rows(j int, rows *[lpnWeight]int) {
+	var counter, output [aes.BlockSize]byte
+	binary.LittleEndian.PutUint64(counter[0:8], uint64(j))
+	for r := 0; r < lpnWeight; r++ {
+		if r%4 == 0 {
+			binary.LittleEndian.PutUint64(counter[8:16], uint64(r/4))
+			code.cipher.Encrypt(output[:], counter[:])
+		}
+		rows[r] = int(binary.LittleEndian.Uint32(output[4*(r%4):]) % uint32(code.k))
+	}
+}
+
+// padTweakFlag sets the top bit of the tweaks of the pads of the GGM tree keys, which separates them from the
+// OT indexes used as tweaks when hashing the outputs.
+const padTweakFlag = 1 << 63
+
+// Method for hashing a block with a tweak to a 128-bit pad.
+func hashBlock(crHash *utils.CRHash, tweak uint64, x block) block {
+	return bytesToBlock(crHash.Hash(tweak, x[:], 128))
+}
+
+// Method for computing the blocks noise ⊕ secret·A of an iteration. This is y = v'·A ⊕ s for the sender and
+// z = w'·A ⊕ r for the receiver.
+func (code *lpnCode) encode(noise []block, secret []block, workers int) []block {
+	encoded := make([]block, len(noise))
+	parallel.For(len(noise), workers, func(start int, end int) {
+		var rows [lpnWeight]int
+		for j := start; j < end; j++ {
+			code.rows(j, &rows)
+			sum := noise[j]
+			for _, row := range rows {
+				sum = xorBlock(sum, secret[row])
+			}
+			encoded[j] = sum
+		}
+	})
+	return encoded
+}
+
+// Method for computing the bits x = u'·A ⊕ e of an iteration for the receiver.
+func (code *lpnCode) encodeBits(noise []byte, secret []byte, workers int) []byte {
+	encoded := make([]byte, len(noise))
+	parallel.For(len(noise), workers, func(start int, end int) {
+		var rows [lpnWeight]int
+		for j := start; j < end; j++ {
+			code.rows(j, &rows)
+			sum := noise[j]
+			for _, row := range rows {
+				sum ^= secret[row]
+			}
+			encoded[j] = sum
+		}
+	})
+	return encoded
+}
+
+// validateSetup checks the length of the setup message with the hash key and the seed of the code A.
+func validateSetup(setup []byte) error {
+	if len(setup) != 2*aes.BlockSize {
+		return fmt.Errorf("%w: silent OT setup has %d bytes, expected %d", ErrInvalidMessageLength, len(setup), 2*aes.BlockSize)
+	}
+	return nil
+}
+
+// silentSetup creates the hash and the code A of a session from the setup message.
+func silentSetup(setup []byte, k int) (*utils.CRHash, *lpnCode, error) {
+	if err := validateSetup(setup); err != nil {
+		return nil, nil, err
+	}
+	crHash, err := utils.NewCRHash(setup[:aes.BlockSize])
+	if err != nil {
+		return nil, nil, err
+	}
+	code, err := newLPNCode(setup[aes.BlockSize:], k)
+	if err != nil {
+		return nil, nil, err
+	}
+	return crHash, code, nil
+}
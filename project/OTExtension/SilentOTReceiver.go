@@ -0,0 +1,264 @@
+// SilentOTReceiver.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/parallel"
+	"cryptographic-computing/project/utils"
+	"fmt"
+	"io"
+)
+
+// SilentOTReceiver is the receiver of a silent OT session (see SilentOT.go). It holds the choice bits u and the
+// strings w of its pool of base COTs, and learns every GGM tree of the sender except one leaf per block.
+type SilentOTReceiver struct {
+	params     SilentOTParameters
+	baseBits   []byte        // Choice bits u of the base COTs of the next iteration, in the order of base.
+	base       []block       // Strings w = v ⊕ u·Δ of the base COTs of the next iteration.
+	points     []int         // Punctured leaf α_i of each tree in the current iteration.
+	outputBits []byte        // Choice bits x_j of the COTs not yet given to the caller.
+	outputs    []block       // Strings z_j of the COTs not yet given to the caller.
+	outputIdx  int           // Index of the first COT in outputs, counted from the start of the session (tweak of the hash).
+	padTweak   uint64        // Number of base COTs used as pads of tree keys so far (tweak of the pads).
+	crHash     *utils.CRHash // Hash for the pads of the tree keys and for the random OTs, keyed by the setup message.
+	code       *lpnCode      // Public code A, seeded by the setup message.
+	prg        *ggmPRG       // PRG of the GGM trees.
+	workers    int           // Number of goroutines used for the trees, the code and hashing.
+	random     io.Reader     // Source of randomness set with SetRandom, or nil for crypto/rand.
+}
+
+// Initialize the receiver with the parameters of the iterations. Returns an error wrapping ErrInvalidParameter if
+// the parameters are invalid.
+func (receiver *SilentOTReceiver) Init(params SilentOTParameters) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	prg, err := newGGMPRG()
+	if err != nil {
+		return err
+	}
+	receiver.params = params
+	receiver.prg = prg
+	return nil
+}
+
+// SetRandom sets the source of the receiver's randomness: the choice bits of the base COTs and the punctured
+// leaves. The default (nil) is crypto/rand.
+func (receiver *SilentOTReceiver) SetRandom(random io.Reader) {
+	receiver.random = random
+}
+
+// SetWorkers sets the number of goroutines the receiver uses to rebuild the trees, apply the code and hash the
+// outputs. A value ≤ 0 uses parallel.DefaultWorkers (GOMAXPROCS), which is also the default.
+func (receiver *SilentOTReceiver) SetWorkers(workers int) {
+	receiver.workers = workers
+}
+
+// workerCount returns the number of goroutines to use, defaulting to parallel.DefaultWorkers.
+func (receiver *SilentOTReceiver) workerCount() int {
+	if receiver.workers <= 0 {
+		return parallel.DefaultWorkers()
+	}
+	return receiver.workers
+}
+
+// Buffered returns the number of COTs produced by the iterations so far and not yet given to the caller.
+func (receiver *SilentOTReceiver) Buffered() int {
+	return len(receiver.outputs)
+}
+
+// Method for choosing the random choice bits u of the base COTs, which the receiver uses as selection bits.
+func (receiver *SilentOTReceiver) ChooseBaseBits() ([]byte, error) {
+	return utils.RandomSelectionBitsFrom(receiver.random, receiver.params.BaseCOTs())
+}
+
+// Method for storing the receiver's output of the base COTs: the choice bits u and the 128-bit strings
+// w_j = x^(u_j)_j. Returns an error wrapping ErrDimensionMismatch, ErrInvalidChoiceBit or ErrInvalidMessageLength
+// if they do not match BaseCOTs.
+func (receiver *SilentOTReceiver) ReceiveBaseCOTs(choiceBits []byte, messages [][]byte) error {
+	if err := utils.ValidateCount("base COT choice bits", len(choiceBits), receiver.params.BaseCOTs()); err != nil {
+		return err
+	}
+	if err := utils.ValidateCount("base COTs", len(messages), receiver.params.BaseCOTs()); err != nil {
+		return err
+	}
+	if err := utils.ValidateSelectionBits(choiceBits); err != nil {
+		return err
+	}
+	receiver.baseBits = append([]byte(nil), choiceBits...)
+	receiver.base = make([]block, len(messages))
+	for j, message := range messages {
+		if err := utils.ValidateBytes(fmt.Sprintf("base COT %d", j), message, 128); err != nil {
+			return err
+		}
+		receiver.base[j] = bytesToBlock(message)
+	}
+	return nil
+}
+
+// Method for receiving the sender's setup message with the key of the hash and the seed of the public code A.
+// Returns an error wrapping ErrInvalidMessageLength if the message does not have 32 bytes.
+func (receiver *SilentOTReceiver) ReceiveSetup(setup []byte) error {
+	crHash, code, err := silentSetup(setup, receiver.params.K)
+	if err != nil {
+		return err
+	}
+	receiver.crHash = crHash
+	receiver.code = code
+	return nil
+}
+
+// Method for the receiver's first step of an iteration. The receiver chooses a random leaf α_i to puncture in
+// each tree, and for level l of tree i sends the correction d = u ⊕ ¬α_(i,l) for the base COT of that level,
+// so it learns the key of the sibling of its path, K_(¬α_(i,l)).
+func (receiver *SilentOTReceiver) ChoosePoints() ([]byte, error) {
+	params := receiver.params
+	h := params.depth()
+
+	pathBits, err := utils.RandomSelectionBitsFrom(receiver.random, params.T*h)
+	if err != nil {
+		return nil, err
+	}
+	receiver.points = make([]int, params.T)
+	corrections := make([]byte, params.T*h)
+	for i := range receiver.points {
+		for level := 0; level < h; level++ {
+			index := i*h + level
+			receiver.points[i] = receiver.points[i]<<1 | int(pathBits[index])
+			corrections[index] = receiver.baseBits[index] ^ pathBits[index] ^ 1
+		}
+	}
+	return corrections, nil
+}
+
+// Method for the receiver's second step of an iteration. The receiver rebuilds every tree except the leaf α_i
+// from the sender's messages, and computes r_(α_i) = Sum ⊕ (⊕_(j≠α_i) s_j) = s_(α_i) ⊕ Δ, so r = s ⊕ e·Δ for the
+// noise vector e with a one at every α_i. It then adds x = u'·A ⊕ e and z = w'·A ⊕ r to its outputs.
+// Returns an error wrapping ErrDimensionMismatch if the messages do not match the parameters.
+func (receiver *SilentOTReceiver) ReceiveTrees(messages []*utils.GGMTreeMessage) error {
+	params := receiver.params
+	h := params.depth()
+	width := params.N / params.T
+
+	if receiver.points == nil {
+		return fmt.Errorf("%w: ChoosePoints must be called before ReceiveTrees", ErrInvalidParameter)
+	}
+	if err := utils.ValidateCount("tree messages", len(messages), params.T); err != nil {
+		return err
+	}
+	for i, message := range messages {
+		if message == nil || len(message.Keys0) != h || len(message.Keys1) != h {
+			return fmt.Errorf("%w: tree message %d does not have %d levels", ErrDimensionMismatch, i, h)
+		}
+	}
+
+	noise := make([]block, params.N)
+	noiseBits := make([]byte, params.N)
+	parallel.For(params.T, receiver.workerCount(), func(start int, end int) {
+		for i := start; i < end; i++ {
+			receiver.rebuildTree(i, noise[i*width:(i+1)*width], messages[i])
+			noiseBits[i*width+receiver.points[i]] = 1
+		}
+	})
+	receiver.padTweak += uint64(params.T * h)
+	receiver.points = nil
+
+	// The outputs of the iteration: the first BaseCOTs are the base COTs of the next iteration.
+	workers := receiver.workerCount()
+	outputs := receiver.code.encode(noise, receiver.base[params.T*h:], workers)
+	outputBits := receiver.code.encodeBits(noiseBits, receiver.baseBits[params.T*h:], workers)
+	receiver.base = append([]block(nil), outputs[:params.BaseCOTs()]...)
+	receiver.baseBits = append([]byte(nil), outputBits[:params.BaseCOTs()]...)
+	receiver.outputs = append(receiver.outputs, outputs[params.BaseCOTs():]...)
+	receiver.outputBits = append(receiver.outputBits, outputBits[params.BaseCOTs():]...)
+	return nil
+}
+
+// Method for rebuilding tree i into leaves. On every level the receiver expands the nodes it knows, and recovers
+// the sibling of its path from the unmasked key of the level and the other nodes on the same side.
+func (receiver *SilentOTReceiver) rebuildTree(i int, leaves []block, message *utils.GGMTreeMessage) {
+	h := len(message.Keys0)
+	point := receiver.points[i]
+	path := 0 // Index of the punctured node on the current level.
+
+	for level := 0; level < h; level++ {
+		if level > 0 {
+			receiver.prg.expandLevel(leaves, 1<<level)
+		}
+
+		// Unmask K_(¬α) = c_(¬α) ⊕ H(w), since w = x_u and c_(¬α) is masked with x_(¬α ⊕ d) = x_u.
+		pathBit := (point >> (h - 1 - level)) & 1
+		index := i*h + level
+		tweak := padTweakFlag | (receiver.padTweak + uint64(index))
+		key := message.Keys1[level]
+		if pathBit == 1 {
+			key = message.Keys0[level]
+		}
+		key = xorBlock(key, hashBlock(receiver.crHash, tweak, receiver.base[index]))
+
+		// The sibling is the key minus all other nodes on its side. The children of the punctured node are not
+		// known, and the one on the path stays punctured.
+		sibling := 2*path + (1 - pathBit)
+		for node := 1 - pathBit; node < 2<<level; node += 2 {
+			if node != sibling {
+				key = xorBlock(key, leaves[node])
+			}
+		}
+		leaves[sibling] = key
+		path = 2*path + pathBit
+		leaves[path] = block{}
+	}
+
+	// r_α = Sum ⊕ (⊕_(j≠α) s_j) = s_α ⊕ Δ.
+	sum := message.Sum
+	for _, leaf := range leaves {
+		sum = xorBlock(sum, leaf)
+	}
+	leaves[point] = sum
+}
+
+// Method for taking the next m COTs from the outputs.
+func (receiver *SilentOTReceiver) take(m int) ([]byte, []block, int, error) {
+	if m <= 0 || m > len(receiver.outputs) {
+		return nil, nil, 0, fmt.Errorf("%w: m must be between 1 and the %d buffered COTs, got %d", ErrInvalidParameter, len(receiver.outputs), m)
+	}
+	choiceBits := receiver.outputBits[:m:m]
+	outputs := receiver.outputs[:m]
+	offset := receiver.outputIdx
+	receiver.outputBits = receiver.outputBits[m:]
+	receiver.outputs = receiver.outputs[m:]
+	receiver.outputIdx += m
+	return choiceBits, outputs, offset, nil
+}
+
+// Method for giving the next m COTs to the caller as choice bits x_j and 128-bit strings z_j = y_j ⊕ x_j·Δ.
+func (receiver *SilentOTReceiver) MakeCorrelatedMessages(m int) ([]byte, [][]byte, error) {
+	choiceBits, outputs, _, err := receiver.take(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages := make([][]byte, m)
+	for j, z := range outputs {
+		messages[j] = utils.WordsToBytes(z[:])
+	}
+	return choiceBits, messages, nil
+}
+
+// Method for giving the next m COTs to the caller as random OTs with choice bits x_j and l-bit strings H(j, z_j),
+// as in OTExtensionProtocolRandom.
+func (receiver *SilentOTReceiver) MakeRandomMessages(m int, l int) ([]byte, [][]byte, error) {
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return nil, nil, err
+	}
+	choiceBits, outputs, offset, err := receiver.take(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	messages := make([][]byte, m)
+	parallel.For(m, receiver.workerCount(), func(start int, end int) {
+		for j := start; j < end; j++ {
+			z := outputs[j]
+			messages[j] = receiver.crHash.Hash(uint64(offset+j), z[:], l)
+		}
+	})
+	return choiceBits, messages, nil
+}
@@ -0,0 +1,228 @@
+// SilentOTSender.go
+package OTExtension
+
+import (
+	"crypto/aes"
+	"cryptographic-computing/project/parallel"
+	"cryptographic-computing/project/utils"
+	"fmt"
+	"io"
+)
+
+// SilentOTSender is the sender of a silent OT session (see SilentOT.go). It holds Δ and the strings v of its pool of
+// base COTs, and expands one GGM tree per noise block in every iteration.
+type SilentOTSender struct {
+	params    SilentOTParameters
+	delta     block         // Global correlation Δ of all COTs.
+	base      []block       // Strings v of the base COTs of the next iteration: T·h for the trees, then K for the LPN secret.
+	outputs   []block       // Strings y_j of the COTs not yet given to the caller.
+	outputIdx int           // Index of the first COT in outputs, counted from the start of the session (tweak of the hash).
+	padTweak  uint64        // Number of base COTs used as pads of tree keys so far (tweak of the pads).
+	crHash    *utils.CRHash // Hash for the pads of the tree keys and for the random OTs, keyed by the setup message.
+	code      *lpnCode      // Public code A, seeded by the setup message.
+	prg       *ggmPRG       // PRG of the GGM trees.
+	workers   int           // Number of goroutines used for the trees, the code and hashing.
+	random    io.Reader     // Source of randomness set with SetRandom, or nil for crypto/rand.
+}
+
+// Initialize the sender with the parameters of the iterations. Returns an error wrapping ErrInvalidParameter if
+// the parameters are invalid.
+func (sender *SilentOTSender) Init(params SilentOTParameters) error {
+	if err := params.Validate(); err != nil {
+		return err
+	}
+	prg, err := newGGMPRG()
+	if err != nil {
+		return err
+	}
+	sender.params = params
+	sender.prg = prg
+	return nil
+}
+
+// SetRandom sets the source of the sender's randomness: Δ, the setup message and the roots of the GGM trees.
+// The default (nil) is crypto/rand.
+func (sender *SilentOTSender) SetRandom(random io.Reader) {
+	sender.random = random
+}
+
+// SetWorkers sets the number of goroutines the sender uses to expand the trees, apply the code and hash the outputs.
+// A value ≤ 0 uses parallel.DefaultWorkers (GOMAXPROCS), which is also the default.
+func (sender *SilentOTSender) SetWorkers(workers int) {
+	sender.workers = workers
+}
+
+// workerCount returns the number of goroutines to use, defaulting to parallel.DefaultWorkers.
+func (sender *SilentOTSender) workerCount() int {
+	if sender.workers <= 0 {
+		return parallel.DefaultWorkers()
+	}
+	return sender.workers
+}
+
+// Buffered returns the number of COTs produced by the iterations so far and not yet given to the caller.
+func (sender *SilentOTSender) Buffered() int {
+	return len(sender.outputs)
+}
+
+// Method for choosing the global correlation Δ of 128 bits, which the sender uses as input to the base COTs.
+func (sender *SilentOTSender) ChooseDelta() ([]byte, error) {
+	delta, err := utils.RandomSeedFrom(sender.random, aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	sender.delta = bytesToBlock(delta)
+	return delta, nil
+}
+
+// Method for storing the sender's output of the base COTs, e.g. of OTExtensionProtocolCorrelated with ChooseDelta
+// as Δ. Only x0_j is kept, since x1_j = x0_j ⊕ Δ. Returns an error wrapping ErrDimensionMismatch or
+// ErrInvalidMessageLength if there are not BaseCOTs pairs of 128-bit strings.
+func (sender *SilentOTSender) ReceiveBaseCOTs(pairs []*utils.MessagePair) error {
+	if err := utils.ValidateCount("base COTs", len(pairs), sender.params.BaseCOTs()); err != nil {
+		return err
+	}
+	if err := utils.ValidateMessagePairs(pairs, 128); err != nil {
+		return err
+	}
+	sender.base = make([]block, len(pairs))
+	for j, pair := range pairs {
+		sender.base[j] = bytesToBlock(pair.Message0)
+	}
+	return nil
+}
+
+// Method for choosing the setup message: a 16-byte key of the hash and a 16-byte seed of the public code A,
+// which the sender sends to the receiver.
+func (sender *SilentOTSender) ChooseSetup() ([]byte, error) {
+	setup, err := utils.RandomSeedFrom(sender.random, 2*aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+	crHash, code, err := silentSetup(setup, sender.params.K)
+	if err != nil {
+		return nil, err
+	}
+	sender.crHash = crHash
+	sender.code = code
+	return setup, nil
+}
+
+// Method for the sender's step of an iteration. For the receiver's corrections d (one bit per base COT of the
+// trees), the sender expands one GGM tree per block from a random root, and sends for every level i the XORs
+// (K0_i, K1_i) of the left and right nodes masked with H(x_d) and H(x_(1⊕d)) of the base COT, and the sum of the
+// leaves and Δ. The leaves are the noise strings s, and the sender adds y = v'·A ⊕ s to its outputs.
+// Returns an error wrapping ErrDimensionMismatch or ErrInvalidChoiceBit if the corrections are invalid.
+func (sender *SilentOTSender) ExpandTrees(corrections []byte) ([]*utils.GGMTreeMessage, error) {
+	params := sender.params
+	h := params.depth()
+	width := params.N / params.T
+
+	if err := utils.ValidateCount("corrections", len(corrections), params.T*h); err != nil {
+		return nil, err
+	}
+	if err := utils.ValidateSelectionBits(corrections); err != nil {
+		return nil, err
+	}
+	roots, err := utils.RandomSeedFrom(sender.random, params.T*aes.BlockSize)
+	if err != nil {
+		return nil, err
+	}
+
+	messages := make([]*utils.GGMTreeMessage, params.T)
+	noise := make([]block, params.N)
+	parallel.For(params.T, sender.workerCount(), func(start int, end int) {
+		for i := start; i < end; i++ {
+			leaves := noise[i*width : (i+1)*width]
+			leaves[0] = bytesToBlock(roots[i*aes.BlockSize:])
+			messages[i] = sender.expandTree(i, leaves, corrections[i*h:(i+1)*h])
+		}
+	})
+	sender.padTweak += uint64(params.T * h)
+
+	// The outputs of the iteration: the first BaseCOTs are the base COTs of the next iteration.
+	outputs := sender.code.encode(noise, sender.base[params.T*h:], sender.workerCount())
+	sender.base = append([]block(nil), outputs[:params.BaseCOTs()]...)
+	sender.outputs = append(sender.outputs, outputs[params.BaseCOTs():]...)
+	return messages, nil
+}
+
+// Method for expanding tree i from the root in leaves[0] into its leaves, and making its message.
+func (sender *SilentOTSender) expandTree(i int, leaves []block, corrections []byte) *utils.GGMTreeMessage {
+	h := len(corrections)
+	message := &utils.GGMTreeMessage{Keys0: make([][2]uint64, h), Keys1: make([][2]uint64, h)}
+
+	for level := 0; level < h; level++ {
+		sender.prg.expandLevel(leaves, 1<<level)
+		var key0, key1 block
+		for node := 0; node < 2<<level; node += 2 {
+			key0 = xorBlock(key0, leaves[node])
+			key1 = xorBlock(key1, leaves[node+1])
+		}
+
+		// Mask K0 with H(x_d) and K1 with H(x_(1⊕d)), where x_0 = v and x_1 = v ⊕ Δ.
+		index := i*h + level
+		tweak := padTweakFlag | (sender.padTweak + uint64(index))
+		x0 := sender.base[index]
+		x1 := xorBlock(x0, sender.delta)
+		if corrections[level] == 1 {
+			x0, x1 = x1, x0
+		}
+		message.Keys0[level] = xorBlock(key0, hashBlock(sender.crHash, tweak, x0))
+		message.Keys1[level] = xorBlock(key1, hashBlock(sender.crHash, tweak, x1))
+	}
+
+	message.Sum = sender.delta
+	for _, leaf := range leaves {
+		message.Sum = xorBlock(message.Sum, leaf)
+	}
+	return message
+}
+
+// Method for taking the next m COTs from the outputs.
+func (sender *SilentOTSender) take(m int) ([]block, int, error) {
+	if m <= 0 || m > len(sender.outputs) {
+		return nil, 0, fmt.Errorf("%w: m must be between 1 and the %d buffered COTs, got %d", ErrInvalidParameter, len(sender.outputs), m)
+	}
+	outputs := sender.outputs[:m]
+	offset := sender.outputIdx
+	sender.outputs = sender.outputs[m:]
+	sender.outputIdx += m
+	return outputs, offset, nil
+}
+
+// Method for giving the next m COTs to the caller as correlated pairs (y_j, y_j ⊕ Δ) of 128-bit strings.
+func (sender *SilentOTSender) MakeCorrelatedMessages(m int) ([]*utils.MessagePair, error) {
+	outputs, _, err := sender.take(m)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]*utils.MessagePair, m)
+	for j, y := range outputs {
+		yDelta := xorBlock(y, sender.delta)
+		pairs[j] = &utils.MessagePair{Message0: utils.WordsToBytes(y[:]), Message1: utils.WordsToBytes(yDelta[:])}
+	}
+	return pairs, nil
+}
+
+// Method for giving the next m COTs to the caller as random OTs of l-bit strings (H(j, y_j), H(j, y_j ⊕ Δ)), which
+// breaks the correlation, as in OTExtensionProtocolRandom.
+func (sender *SilentOTSender) MakeRandomMessages(m int, l int) ([]*utils.MessagePair, error) {
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return nil, err
+	}
+	outputs, offset, err := sender.take(m)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]*utils.MessagePair, m)
+	parallel.For(m, sender.workerCount(), func(start int, end int) {
+		for j := start; j < end; j++ {
+			y := outputs[j]
+			yDelta := xorBlock(y, sender.delta)
+			tweak := uint64(offset + j)
+			pairs[j] = &utils.MessagePair{Message0: sender.crHash.Hash(tweak, y[:], l), Message1: sender.crHash.Hash(tweak, yDelta[:], l)}
+		}
+	})
+	return pairs, nil
+}
@@ -0,0 +1,171 @@
+// SilentOTSession.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/utils"
+	"fmt"
+)
+
+// SilentOTSession runs the base COTs of a silent OT once with OTExtensionProtocolCorrelated, and then serves
+// batches of random OTs from silent OT iterations (see SilentOT.go), which only send the GGM tree messages.
+// The batches have the same types as OTExtensionSession.ExtendRandom, so they can be derandomized with
+// OTDerandomizeProtocol in the same way.
+type SilentOTSession struct {
+	sender        SilentOTSender
+	receiver      SilentOTReceiver
+	l             int                 // Bit length of the random OTs of Extend.
+	iterations    int                 // Number of silent OT iterations so far.
+	communication utils.Communication // Communication of the base COTs and all iterations so far.
+}
+
+// NewSilentOTSession creates a session for random OTs of l-bit strings with the given LPN parameters, e.g.
+// FerretParameters. It runs BaseCOTs correlated OTs with k = 128 between the sender and the receiver, whose
+// strings are the base COTs of the first iteration. Both parties draw their randomness from the source of elGamal.
+func NewSilentOTSession(params SilentOTParameters, l int, elGamal elgamal.ElGamal, multithreaded bool) (*SilentOTSession, error) {
+	if err := utils.ValidateMessageLength(l); err != nil {
+		return nil, err
+	}
+	session := &SilentOTSession{l: l}
+	session.sender.SetRandom(elGamal.Random())
+	session.receiver.SetRandom(elGamal.Random())
+	if err := session.sender.Init(params); err != nil {
+		return nil, err
+	}
+	if err := session.receiver.Init(params); err != nil {
+		return nil, err
+	}
+	if !multithreaded {
+		session.SetWorkers(1)
+	}
+
+	// The sender chooses Δ and the receiver random choice bits, and the parties run the base COTs.
+	delta, err := session.sender.ChooseDelta()
+	if err != nil {
+		return nil, err
+	}
+	choiceBits, err := session.receiver.ChooseBaseBits()
+	if err != nil {
+		return nil, err
+	}
+	pairs, messages, communication, err := OTExtensionProtocolCorrelated(128, 128, params.BaseCOTs(), choiceBits, delta, elGamal, multithreaded)
+	if err != nil {
+		return nil, err
+	}
+	session.communication.Add(communication)
+	if err := session.sender.ReceiveBaseCOTs(pairs); err != nil {
+		return nil, err
+	}
+	if err := session.receiver.ReceiveBaseCOTs(choiceBits, messages); err != nil {
+		return nil, err
+	}
+
+	// The sender sends the key of the hash and the seed of the code A to the receiver.
+	setup, err := session.sender.ChooseSetup()
+	if err != nil {
+		return nil, err
+	}
+	session.communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, utils.ByteStringsWireLength(len(setup)))
+	if err := session.receiver.ReceiveSetup(setup); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+// SetWorkers sets the number of goroutines both parties use for the trees, the code and hashing.
+// A value ≤ 0 uses parallel.DefaultWorkers (GOMAXPROCS).
+func (session *SilentOTSession) SetWorkers(workers int) {
+	session.sender.SetWorkers(workers)
+	session.receiver.SetWorkers(workers)
+}
+
+// Iterations returns the number of silent OT iterations run so far.
+func (session *SilentOTSession) Iterations() int {
+	return session.iterations
+}
+
+// Communication returns the communication of the session so far: the base COTs, the setup message, and every
+// iteration. The returned value is a copy, which does not change with the following batches.
+func (session *SilentOTSession) Communication() *utils.Communication {
+	communication := session.communication
+	return &communication
+}
+
+// iterate runs one silent OT iteration, which adds params.Outputs() COTs to the outputs of both parties.
+func (session *SilentOTSession) iterate() error {
+	params := session.sender.params
+
+	// The receiver chooses the punctured leaves, and sends one correction bit per level of every tree.
+	corrections, err := session.receiver.ChoosePoints()
+	if err != nil {
+		return err
+	}
+	session.communication.Record(utils.PhaseExpansion, utils.ReceiverToSender, utils.ByteStringsWireLength(utils.ByteLength(len(corrections))))
+
+	// The sender expands the trees and sends the masked level keys and the sums of the leaves.
+	trees, err := session.sender.ExpandTrees(corrections)
+	if err != nil {
+		return err
+	}
+	blockLengths := make([]int, params.T*(2*params.depth()+1))
+	for i := range blockLengths {
+		blockLengths[i] = 16
+	}
+	session.communication.Record(utils.PhaseExpansion, utils.SenderToReceiver, utils.ByteStringsWireLength(blockLengths...))
+	if err := session.receiver.ReceiveTrees(trees); err != nil {
+		return err
+	}
+
+	session.iterations++
+	return nil
+}
+
+// fill runs iterations until both parties have at least m COTs.
+func (session *SilentOTSession) fill(m int) error {
+	if m <= 0 {
+		return fmt.Errorf("%w: m must be positive, got %d", ErrInvalidParameter, m)
+	}
+	for session.receiver.Buffered() < m {
+		if err := session.iterate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extend gives m random OTs of l-bit strings, running silent OT iterations as needed. The sender gets the pairs
+// (H(j, y_j), H(j, y_j ⊕ Δ)) and the receiver the choice bits x_j and H(j, z_j), as in ExtendRandom of an
+// OTExtensionSession. No two batches share a COT or a tweak of the hash.
+func (session *SilentOTSession) Extend(m int) (*RandomOTSender, *RandomOTReceiver, error) {
+	if err := session.fill(m); err != nil {
+		return nil, nil, err
+	}
+	pairs, err := session.sender.MakeRandomMessages(m, session.l)
+	if err != nil {
+		return nil, nil, err
+	}
+	choiceBits, messages, err := session.receiver.MakeRandomMessages(m, session.l)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &RandomOTSender{Pairs: pairs}, &RandomOTReceiver{ChoiceBits: choiceBits, Messages: messages}, nil
+}
+
+// ExtendCorrelated gives m random COTs of 128-bit strings with the session's Δ, running silent OT iterations as
+// needed. The sender gets the pairs (y_j, y_j ⊕ Δ), as returned by OTExtensionProtocolCorrelated, and the receiver
+// the choice bits x_j and z_j = y_j ⊕ x_j·Δ.
+func (session *SilentOTSession) ExtendCorrelated(m int) ([]*utils.MessagePair, *RandomOTReceiver, error) {
+	if err := session.fill(m); err != nil {
+		return nil, nil, err
+	}
+	pairs, err := session.sender.MakeCorrelatedMessages(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	choiceBits, messages, err := session.receiver.MakeCorrelatedMessages(m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pairs, &RandomOTReceiver{ChoiceBits: choiceBits, Messages: messages}, nil
+}
@@ -0,0 +1,37 @@
+// WalshHadamard.go
+package OTExtension
+
+import (
+	"cryptographic-computing/project/utils"
+	"encoding/binary"
+	"math/bits"
+)
+
+// Length of the Walsh–Hadamard code used in the 1-out-of-N OT extension (KK13). The code has 256 codewords
+// of 256 bits with minimum distance 128, so it supports N ≤ 256 and replaces the k columns of IKNP by 256 columns.
+const WalshHadamardLength = 256
+
+// walshHadamardBit returns bit i of the Walsh–Hadamard codeword C(r), which is the parity of r ∧ i.
+func walshHadamardBit(r byte, i int) byte {
+	return byte(bits.OnesCount8(r&byte(i)) & 1)
+}
+
+// walshHadamardCodeword returns the codeword C(r) packed into 64-bit words.
+func walshHadamardCodeword(r byte) []uint64 {
+	codeword := make([]uint64, utils.WordsFor(WalshHadamardLength))
+	for i := 0; i < WalshHadamardLength; i++ {
+		utils.SetBit(codeword, i, walshHadamardBit(r, i))
+	}
+	return codeword
+}
+
+// hashIndexedRow hashes the j'th row of Q or T together with the index j and the session ID to l bits, i.e.
+// H(sid, j, row). The index is needed in the 1-out-of-N OT extension, where the sender reveals several hashes of
+// related rows.
+func hashIndexedRow(sessionID []byte, j int, row []uint64, l int) []byte {
+	data := make([]byte, 0, len(sessionID)+8+8*len(row))
+	data = append(data, sessionID...)
+	data = binary.LittleEndian.AppendUint64(data, uint64(j))
+	data = append(data, utils.WordsToBytes(row)...)
+	return utils.Hash(data, l)
+}
@@ -20,11 +20,14 @@ func TestMakeDataFixL(iterations int) {
 		log.Fatalf("failed creating file: %s", err)
 	}
 	csvwriter := csv.NewWriter(csvFile)
-	_ = csvwriter.Write([]string{"m_size", "time_OT_Basic", "time_OT_Extension", "time_OT_Extension_Transpose", "time_OT_Extension_Eklundh", "time_OT_Extension_Eklundh_Multithreaded"})
+	header := []string{"m_size", "time_OT_Basic", "time_OT_Extension", "time_OT_Extension_Transpose", "time_OT_Extension_Eklundh", "time_OT_Extension_Eklundh_Multithreaded"}
+	_ = csvwriter.Write(append(header, communicationHeader("OT_Extension_Eklundh")...))
 
 	fmt.Println("Calculating El Gamal Parameters")
 	elGamal := elgamal.ElGamal{}
-	elGamal.Init()
+	if err := elGamal.Init(); err != nil {
+		log.Fatal(err)
+	}
 	k := 128
 	l := 1
 
@@ -46,33 +49,184 @@ func TestMakeDataFixL(iterations int) {
 		}
 
 		// time_start := time.Now()
-		// OTBasic.OTBasicProtocol(l, m, selectionBits, messages, elGamal)
+		// OTBasic.OTBasicProtocol(m, selectionBits, messages, elGamal)
 		// time_end := time.Since(time_start).Seconds()
 		// time_OT_Basic := fmt.Sprintf("%.2f", time_end)
 		time_OT_Basic := "0"
 
 		/* time_start := time.Now()
-		OTExt.OTExtensionProtocol(k, l, m, selectionBits, messages, elGamal)
+		OTExt.OTExtensionProtocol(k, m, selectionBits, messages, elGamal)
 		time_end := time.Since(time_start).Seconds() */
 		time_OT_Extension := "0" //fmt.Sprintf("%.2f", time_end)
 
 		time_start := time.Now()
-		OTExt.OTExtensionProtocolTranspose(k, l, m, selectionBits, messages, elGamal)
+		if _, _, err := OTExt.OTExtensionProtocolTranspose(k, l, m, selectionBits, messages, elGamal); err != nil {
+			log.Fatal(err)
+		}
 		time_end := time.Since(time_start).Seconds()
 		time_OT_Extension_Transpose := fmt.Sprintf("%.2f", time_end)
 
 		time_start = time.Now()
-		OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
+		_, communication, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
+		if err != nil {
+			log.Fatal(err)
+		}
 		time_end = time.Since(time_start).Seconds()
 		time_OT_Extension_Eklundh := fmt.Sprintf("%.2f", time_end)
 
 		time_start = time.Now()
-		OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true)
+		if _, _, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true); err != nil {
+			log.Fatal(err)
+		}
 		time_end = time.Since(time_start).Seconds()
 		time_OT_Extension_Eklundh_Multithreaded := fmt.Sprintf("%.2f", time_end)
 
-		_ = csvwriter.Write([]string{strconv.Itoa(m), time_OT_Basic, time_OT_Extension, time_OT_Extension_Transpose, time_OT_Extension_Eklundh, time_OT_Extension_Eklundh_Multithreaded})
+		row := []string{strconv.Itoa(m), time_OT_Basic, time_OT_Extension, time_OT_Extension_Transpose, time_OT_Extension_Eklundh, time_OT_Extension_Eklundh_Multithreaded}
+		_ = csvwriter.Write(append(row, communicationColumns(communication)...))
+		csvwriter.Flush()
+	}
+
+}
+
+// communicationHeader returns the CSV headers of the columns written by communicationColumns for the given protocol:
+// the bytes sent in each direction and the number of rounds of every phase.
+func communicationHeader(protocol string) []string {
+	var header []string
+	for phase := utils.Phase(0); phase < utils.NumPhases; phase++ {
+		header = append(header,
+			fmt.Sprintf("bytes_sender_to_receiver_%s_%s", phase, protocol),
+			fmt.Sprintf("bytes_receiver_to_sender_%s_%s", phase, protocol),
+			fmt.Sprintf("rounds_%s_%s", phase, protocol))
+	}
+	return header
+}
+
+// communicationColumns returns the CSV columns with the communication of a protocol run, in the order of communicationHeader.
+func communicationColumns(communication *utils.Communication) []string {
+	var columns []string
+	for _, phaseCommunication := range communication.Phases {
+		columns = append(columns,
+			strconv.Itoa(phaseCommunication.SenderToReceiver),
+			strconv.Itoa(phaseCommunication.ReceiverToSender),
+			strconv.Itoa(phaseCommunication.Rounds))
+	}
+	return columns
+}
+
+// TestMakeDataWorkers times the OT extension with an increasing number of worker goroutines, writing workers_data.csv.
+// The base OTs are run once in an OTExtensionSession, and each row times a batch of m OTs
+// with the given number of workers, doubling from 1 up to maxWorkers.
+// No results are kept in the repository: any speedup depends on the cores of the machine, so run it where the
+// scaling is to be evaluated, with maxWorkers up to runtime.NumCPU().
+func TestMakeDataWorkers(m int, maxWorkers int) {
+	csvFile, err := os.Create("./workers_data.csv")
+	if err != nil {
+		log.Fatalf("failed creating file: %s", err)
+	}
+	csvwriter := csv.NewWriter(csvFile)
+	_ = csvwriter.Write([]string{"m_size", "workers", "time_OT_Extension_Eklundh"})
+
+	// Only the extension is timed, so a standard group is used instead of generating one
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		log.Fatal(err)
+	}
+	k := 128
+	l := 1
+
+	// create messages and selection bits for algorithms.
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	session, err := OTExt.NewOTExtensionSession(k, l, elGamal, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for workers := 1; workers <= maxWorkers; workers *= 2 {
+
+		fmt.Println("Running with workers:", workers)
+
+		session.SetWorkers(workers)
+		time_start := time.Now()
+		if _, err := session.Extend(m, selectionBits, messages); err != nil {
+			log.Fatal(err)
+		}
+		time_end := time.Since(time_start).Seconds()
+		time_OT_Extension_Eklundh := fmt.Sprintf("%.2f", time_end)
+
+		_ = csvwriter.Write([]string{strconv.Itoa(m), strconv.Itoa(workers), time_OT_Extension_Eklundh})
 		csvwriter.Flush()
 	}
+}
+
+// TestMakeDataHash compares the SHA-256 hash with the tweakable fixed-key AES hash in the OT extension.
+// The base OTs are run once in an OTExtensionSession, and each row times a batch of m = 2^i OTs
+// with each hash function, for i up to maxM.
+func TestMakeDataHash(maxM int, l int) {
+	csvFile, err := os.Create("./hash_data.csv")
+	if err != nil {
+		log.Fatalf("failed creating file: %s", err)
+	}
+	csvwriter := csv.NewWriter(csvFile)
+	_ = csvwriter.Write([]string{"m_size", "time_OT_Extension_SHA256", "time_OT_Extension_FixedKeyAES"})
+
+	fmt.Println("Calculating El Gamal Parameters")
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.Init(); err != nil {
+		log.Fatal(err)
+	}
+	k := 128
+
+	session, err := OTExt.NewOTExtensionSession(k, l, elGamal, true)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	for i := 1; i <= maxM; i++ {
+		m := 1 << i
+
+		fmt.Println("Running with m:", m)
+
+		// create messages and selection bits for algorithms.
+		selectionBits := utils.RandomSelectionBits(m)
+		var messages []*utils.MessagePair
+		for i := 0; i < m; i++ {
+			msg := utils.MessagePair{
+				Message0: utils.RandomBits(l),
+				Message1: utils.RandomBits(l),
+			}
+			messages = append(messages, &msg)
+		}
 
+		if err := session.SetHashFunction(OTExt.HashSHA256); err != nil {
+			log.Fatal(err)
+		}
+		time_start := time.Now()
+		if _, err := session.Extend(m, selectionBits, messages); err != nil {
+			log.Fatal(err)
+		}
+		time_end := time.Since(time_start).Seconds()
+		time_OT_Extension_SHA256 := fmt.Sprintf("%.2f", time_end)
+
+		if err := session.SetHashFunction(OTExt.HashFixedKeyAES); err != nil {
+			log.Fatal(err)
+		}
+		time_start = time.Now()
+		if _, err := session.Extend(m, selectionBits, messages); err != nil {
+			log.Fatal(err)
+		}
+		time_end = time.Since(time_start).Seconds()
+		time_OT_Extension_FixedKeyAES := fmt.Sprintf("%.2f", time_end)
+
+		_ = csvwriter.Write([]string{strconv.Itoa(m), time_OT_Extension_SHA256, time_OT_Extension_FixedKeyAES})
+		csvwriter.Flush()
+	}
 }
@@ -0,0 +1,82 @@
+package elgamal
+
+import (
+	"cryptographic-computing/project/parallel"
+	"math/big"
+)
+
+// The batch methods run many independent operations, e.g. the k base OTs of an OT extension, split across
+// goroutines. The randomness of a batch is drawn in order before the work is split, so a batch consumes the
+// source of randomness exactly like the same operations one by one, and a seeded run stays reproducible. If the
+// source fails, the batch returns the error before any work is done.
+
+// SetWorkers sets the number of goroutines used by the batch methods. If workers ≤ 0, GOMAXPROCS goroutines are
+// used, and 1 runs the batches on the calling goroutine. Like the source of randomness, the number is kept when the
+// ElGamal is copied.
+func (elGamal *ElGamal) SetWorkers(workers int) {
+	elGamal.workers = workers
+}
+
+// workerCount returns the number of goroutines to use, defaulting to GOMAXPROCS.
+func (elGamal *ElGamal) workerCount() int {
+	if elGamal.workers <= 0 {
+		return parallel.DefaultWorkers()
+	}
+	return elGamal.workers
+}
+
+// parallelFor calls body for every index in [0, n), split into at most workers contiguous ranges with parallel.For.
+func parallelFor(n int, workers int, body func(i int)) {
+	parallel.For(n, workers, func(start int, end int) {
+		for i := start; i < end; i++ {
+			body(i)
+		}
+	})
+}
+
+// randomExponents draws n random exponents r ∈ [1, q-1] in order, for the encryptions of a batch.
+func (elGamal *ElGamal) randomExponents(n int) ([]*big.Int, error) {
+	randomness := make([]*big.Int, n)
+	for i := range randomness {
+		r, err := elGamal.randomExponent()
+		if err != nil {
+			return nil, err
+		}
+		randomness[i] = r
+	}
+	return randomness, nil
+}
+
+// GenBatch generates the public key g^sk of every secret key, like Gen.
+func (elGamal *ElGamal) GenBatch(secretKeys []*big.Int) []*big.Int {
+	publicKeys := make([]*big.Int, len(secretKeys))
+	parallelFor(len(secretKeys), elGamal.workerCount(), func(i int) {
+		publicKeys[i] = elGamal.Gen(secretKeys[i])
+	})
+	return publicKeys
+}
+
+// EncryptBatch encrypts messages[i] under publicKeys[i] for every i, like Encrypt.
+// The slices must have the same length.
+func (elGamal *ElGamal) EncryptBatch(messages []*big.Int, publicKeys []*big.Int) ([]*Ciphertext, error) {
+	randomness, err := elGamal.randomExponents(len(messages))
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertexts := make([]*Ciphertext, len(messages))
+	parallelFor(len(messages), elGamal.workerCount(), func(i int) {
+		ciphertexts[i] = elGamal.encryptWith(messages[i], publicKeys[i], randomness[i])
+	})
+	return ciphertexts, nil
+}
+
+// DecryptBatch decrypts ciphertexts[i] with secretKeys[i] for every i, like Decrypt.
+// The slices must have the same length.
+func (elGamal *ElGamal) DecryptBatch(ciphertexts []*Ciphertext, secretKeys []*big.Int) []*big.Int {
+	plaintexts := make([]*big.Int, len(ciphertexts))
+	parallelFor(len(ciphertexts), elGamal.workerCount(), func(i int) {
+		plaintexts[i] = elGamal.Decrypt(ciphertexts[i].C1, ciphertexts[i].C2, secretKeys[i])
+	})
+	return plaintexts
+}
@@ -2,13 +2,15 @@ package elgamal
 
 import (
 	"crypto/rand"
+	"fmt"
+	"io"
 	"math/big"
 )
 
 type ElGamal struct {
-	q *big.Int // order of group G (cyclic subgroup of F_p). Notice, q | p-1
-	p *big.Int // prime number defining the finite field F_p
-	g *big.Int // generator of group G
+	group   Group     // group G of prime order q with generator g, set by Init or SetGroup
+	random  io.Reader // source of randomness. crypto/rand.Reader if nil
+	workers int       // number of goroutines of the batch methods. GOMAXPROCS if ≤ 0
 }
 
 // Ciphertext is a struct containing the two parts of a ciphertext (Due to GO being unable to return a list of tuple values).
@@ -18,113 +20,214 @@ type Ciphertext struct {
 	C2 *big.Int
 }
 
-// Generate the public parameters p, q, g for the ElGamal cryptosystem
-func (elGamal *ElGamal) Init() {
+// SetRandom sets the source of randomness for generating parameters, keys and encryptions. The default is
+// crypto/rand.Reader. Tests can supply a seeded deterministic stream (utils.NewDeterministicReader) to reproduce
+// an exact run. The source is kept when the ElGamal is copied, e.g. when it is passed to a protocol.
+func (elGamal *ElGamal) SetRandom(random io.Reader) {
+	elGamal.random = random
+}
+
+// Random returns the source set with SetRandom, or nil if the default crypto/rand.Reader is used.
+func (elGamal *ElGamal) Random() io.Reader {
+	return elGamal.random
+}
+
+// WithRandom returns a copy of the ElGamal with the same parameters, which draws its randomness from random.
+// If random is nil, the ElGamal itself is returned.
+func (elGamal *ElGamal) WithRandom(random io.Reader) *ElGamal {
+	if random == nil {
+		return elGamal
+	}
+	copied := *elGamal
+	copied.random = random
+	return &copied
+}
 
+// SetGroup sets the group of the cryptosystem instead of generating one with Init, e.g. NewP256Group() for fast
+// base OTs. Like the source of randomness, the group is kept when the ElGamal is copied.
+func (elGamal *ElGamal) SetGroup(group Group) {
+	elGamal.group = group
+}
+
+// Group returns the group set by Init or SetGroup, or nil if neither was called.
+func (elGamal *ElGamal) Group() Group {
+	return elGamal.group
+}
+
+// randomness returns the source of randomness, defaulting to crypto/rand.Reader.
+func (elGamal *ElGamal) randomness() io.Reader {
+	if elGamal.random == nil {
+		return rand.Reader
+	}
+	return elGamal.random
+}
+
+// randomPrime returns a prime of the given bit length drawn from the source of randomness, like crypto/rand.Prime.
+// crypto/rand.Prime always uses a secure source since Go 1.26, which would make Init irreproducible.
+// Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) randomPrime(bits int) (*big.Int, error) {
+	bytes := make([]byte, (bits+7)/8)
+	topBits := uint(bits % 8)
+	if topBits == 0 {
+		topBits = 8
+	}
+	for {
+		_, err := io.ReadFull(elGamal.randomness(), bytes)
+		if err != nil {
+			return nil, fmt.Errorf("error reading randomness: %v", err)
+		}
+		bytes[0] &= uint8(int(1<<topBits) - 1) // Clear the bits above the bit length
+		bytes[0] |= 3 << (topBits - 2)         // Set the two most significant bits, so the prime has the full bit length
+		bytes[len(bytes)-1] |= 1               // Make the candidate odd
+
+		p := new(big.Int).SetBytes(bytes)
+		if p.ProbablyPrime(20) {
+			return p, nil
+		}
+	}
+}
+
+// randomInt returns a uniform random number in [0, max) drawn from the source of randomness.
+// Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) randomInt(max *big.Int) (*big.Int, error) {
+	r, err := rand.Int(elGamal.randomness(), max)
+	if err != nil {
+		return nil, fmt.Errorf("error reading randomness: %v", err)
+	}
+	return r, nil
+}
+
+// Generate the public parameters p, q, g for the ElGamal cryptosystem, and use the group G of order q in Z_p^*.
+// Returns an error if the source of randomness fails, and the ElGamal is left unchanged.
+func (elGamal *ElGamal) Init() error {
+
+	var p, q *big.Int
 	// Generate primes q and p such that p = kq + 1 for some k
 	for {
 		// Generate a large prime q of 2048 bits length.
 		// FOR TESTING CHANGE TO 256 BITS.
-		elGamal.q, _ = rand.Prime(rand.Reader, 2048)
+		var err error
+		q, err = elGamal.randomPrime(2048)
+		if err != nil {
+			return err
+		}
 
-		elGamal.p = new(big.Int).Mul((big.NewInt(2)), elGamal.q) // p = kq (we use k = 2 for simplicity as suggested in lecture notes)
-		elGamal.p = elGamal.p.Add(elGamal.p, big.NewInt(1))      // p = kq + 1
+		p = new(big.Int).Mul((big.NewInt(2)), q) // p = kq (we use k = 2 for simplicity as suggested in lecture notes)
+		p = p.Add(p, big.NewInt(1))              // p = kq + 1
 
-		if elGamal.p.ProbablyPrime(40) { // Test with 40 rounds of Miller-Rabin. Otherwise try new q and p values (Rand.Prime calls Miller-Rabin internally for q)
+		if p.ProbablyPrime(40) { // Test with 40 rounds of Miller-Rabin. Otherwise try new q and p values (Rand.Prime calls Miller-Rabin internally for q)
 			break
 		}
 
 	}
 	// Generate a DDH-safe group g of order q in Z_p^* by using the second suggesting from the notes:
 	// "Pick arbitrary x from Z_p^* where x != 1 and x != -1, and compute g = x2 mod p".
-	pMinusTwo := new(big.Int).Sub(elGamal.p, big.NewInt(1))   // pMinusTwo = p-2
-	x, _ := rand.Int(rand.Reader, pMinusTwo)                  // random number x ∈ [0, p-3]
-	x = x.Add(x, big.NewInt(2))                               // random number x ∈ [2, p-1]
-	elGamal.g = new(big.Int).Exp(x, big.NewInt(2), elGamal.p) // g = x^2 mod p
+	pMinusTwo := new(big.Int).Sub(p, big.NewInt(1)) // pMinusTwo = p-2
+	x, err := elGamal.randomInt(pMinusTwo)          // random number x ∈ [0, p-3]
+	if err != nil {
+		return err
+	}
+	x = x.Add(x, big.NewInt(2))                // random number x ∈ [2, p-1]
+	g := new(big.Int).Exp(x, big.NewInt(2), p) // g = x^2 mod p
 
+	elGamal.group = NewModPGroup(p, q, g)
+	return nil
 }
 
-func (elGamal *ElGamal) MakeSecretKey() *big.Int {
-	// sk ∈ [1, q-1]. Notice, we exclude 0 due to weak properties.
-	qMinusOne := new(big.Int).Sub(elGamal.q, big.NewInt(1))
-	x, _ := rand.Int(rand.Reader, qMinusOne) // random number x ∈ [0, q-2]
-	sk := x.Add(x, big.NewInt(1))            // random sk ∈ [1, q-1]
+// InitStandard uses the named standard group, e.g. FFDHE2048, instead of generating new parameters with Init, which
+// takes seconds to minutes. Both parties then agree on the parameters by name.
+// Returns an error wrapping ErrInvalidGroup if the name is unknown.
+func (elGamal *ElGamal) InitStandard(name string) error {
+	group, err := StandardGroup(name)
+	if err != nil {
+		return err
+	}
+	elGamal.group = group
+	return nil
+}
 
-	return sk
+// Validate checks the parameters of the group, e.g. after receiving them from the other party (see Group.Validate).
+// Returns an error wrapping ErrInvalidGroup if no group is set or it is invalid.
+func (elGamal *ElGamal) Validate() error {
+	if elGamal.group == nil {
+		return fmt.Errorf("%w: Init, InitStandard or SetGroup must be called first", ErrInvalidGroup)
+	}
+	return elGamal.group.Validate()
 }
 
-// Generate a "real" public key h = g^sk mod p from a secret key sk
-func (elGamal *ElGamal) Gen(sk *big.Int) *big.Int {
+// ValidateElement checks a public key or the encapsulation c1 of a ciphertext received from the other party.
+// Returns an error wrapping ErrInvalidElement if it is not an element of the group, e.g. a point that is not on the
+// curve, or if it is the identity, which would make the shared element pk^r = 1 known to everyone.
+func (elGamal *ElGamal) ValidateElement(element *big.Int) error {
+	if element == nil || !elGamal.group.Contains(element) {
+		return fmt.Errorf("%w: not an element of %s", ErrInvalidElement, elGamal.group.Name())
+	}
+	if element.Cmp(elGamal.group.Identity()) == 0 {
+		return fmt.Errorf("%w: the identity of %s", ErrInvalidElement, elGamal.group.Name())
+	}
+	return nil
+}
 
-	h := new(big.Int).Exp(elGamal.g, sk, elGamal.p) // h = g^sk mod p
+// randomExponent returns a random exponent r ∈ [1, q-1]. Notice, we exclude 0 due to weak properties.
+// Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) randomExponent() (*big.Int, error) {
+	qMinusOne := new(big.Int).Sub(elGamal.group.Order(), big.NewInt(1))
+	x, err := elGamal.randomInt(qMinusOne) // random number x ∈ [0, q-2]
+	if err != nil {
+		return nil, err
+	}
+	return x.Add(x, big.NewInt(1)), nil // random number ∈ [1, q-1]
+}
 
-	return h // return public key
+// MakeSecretKey returns a random secret key sk ∈ [1, q-1], or an error if the source of randomness fails.
+func (elGamal *ElGamal) MakeSecretKey() (*big.Int, error) {
+	return elGamal.randomExponent()
 }
 
-// OGen is the oblivious version of Gen. It returns a random "fake" public key following the second method in exercise 5
-func (elGamal *ElGamal) OGen() *big.Int {
+// Generate a "real" public key h = g^sk from a secret key sk
+func (elGamal *ElGamal) Gen(sk *big.Int) *big.Int {
 
-	n := elGamal.p.BitLen() // Get the bit length of p
+	h := elGamal.group.ExpBase(sk) // h = g^sk
 
-	// Create 2^(2n) upper bound for random number
-	upperBound := new(big.Int).Lsh(big.NewInt(1), uint(2*n)) // Left shift is equivalent to multiplying by 2 raised to a power
+	return h // return public key
+}
 
-	// Generate a random big int r ∈ [1, 2^(2n)]
-	r, _ := rand.Int(rand.Reader, upperBound)
-	if r.Sign() == 0 {
-		r = r.Add(r, big.NewInt(1)) // Ensure r is not zero
+// OGen is the oblivious version of Gen. It returns a random "fake" public key, a random element of the group
+// whose secret key nobody knows (see Group.RandomElement). Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) OGen() (*big.Int, error) {
+	h, err := elGamal.group.RandomElement(elGamal.randomness())
+	if err != nil {
+		return nil, fmt.Errorf("error generating oblivious key: %v", err)
 	}
-
-	// Return r mod p
-	return r.Mod(r, elGamal.p)
+	return h, nil
 }
 
-// The encrypt method first encodes the message m with the third encoding method from the notes:
-// "check if (m + 1)^q = 1 mod p. If yes, encrypt M = m + 1. If not, encrypt M = −(m + 1)".
-// Afterwards, it use the ElGamal encryption scheme to encrypt the encoded message M.
-func (elGamal *ElGamal) Encrypt(m *big.Int, pk *big.Int) *Ciphertext {
+// The encrypt method uses the ElGamal encryption scheme c1 = g^r, c2 = M · pk^r, where the message m is encoded
+// into M · pk^r by the group (see Group.MaskMessage). Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) Encrypt(m *big.Int, pk *big.Int) (*Ciphertext, error) {
 
-	// Encoding of m
-	var M *big.Int
-	mPlusOne := new(big.Int).Add(m, big.NewInt(1))                                // m + 1
-	if new(big.Int).Exp(mPlusOne, elGamal.q, elGamal.p).Cmp(big.NewInt(1)) == 0 { // Check If (m + 1)^q = 1 mod p
-		M = mPlusOne // M = m + 1.
-	} else {
-		M = new(big.Int).Neg(mPlusOne) // M = -(m + 1)
+	r, err := elGamal.randomExponent() // random number r ∈ [1, q-1]
+	if err != nil {
+		return nil, err
 	}
 
-	// Generate a random number r ∈ [1, q-1]. Notice, we exclude 0 due to weak properties.
-	qMinusOne := new(big.Int).Sub(elGamal.q, big.NewInt(1))
-	r, _ := rand.Int(rand.Reader, qMinusOne) // random number ∈ [0, q-2]
-	r = r.Add(r, big.NewInt(1))              // random number ∈ [1, q-1]
+	return elGamal.encryptWith(m, pk, r), nil
 
-	c1 := new(big.Int).Exp(elGamal.g, r, elGamal.p)               // c1 = g^r mod p
-	c2 := new(big.Int).Mul(M, new(big.Int).Exp(pk, r, elGamal.p)) // c2 = m * (pk^r mod p)
-	c2 = c2.Mod(c2, elGamal.p)                                    // c2 = m * pk^r mod p
+}
 
-	return &Ciphertext{c1, c2} // return ciphertext struct since GO does not support tuple values
+// encryptWith encrypts m under pk with the randomness r.
+func (elGamal *ElGamal) encryptWith(m *big.Int, pk *big.Int, r *big.Int) *Ciphertext {
+	c1 := elGamal.group.ExpBase(r)                               // c1 = g^r
+	c2 := elGamal.group.MaskMessage(m, elGamal.group.Exp(pk, r)) // c2 = M * pk^r
 
+	return &Ciphertext{c1, c2} // return ciphertext struct since GO does not support tuple values
 }
 
-// Regular ElGamal decryption method with decoding afterwards. First M = c2 * (c1^sk)^-1 mod p is computed.
-// Then the decoding method from the notes is used: "If M ≤ q, then m = M − 1, otherwise m = −M − 1."
+// Regular ElGamal decryption method. The shared element c1^sk is computed, and the group decodes the message
+// from c2 with it (see Group.UnmaskMessage).
 func (elGamal *ElGamal) Decrypt(c1 *big.Int, c2 *big.Int, sk *big.Int) *big.Int {
 
-	// Calculate M = c2 * c1^-sk mod p
-	negSk := new(big.Int).Neg(sk)               // -sk
-	s := new(big.Int).Exp(c1, negSk, elGamal.p) // s = c1^-sk mod p
-	M := new(big.Int).Mul(c2, s)                // M = c2 * s
-	M = M.Mod(M, elGamal.p)                     // M = c2 * s mod p
-
-	// Decode M
-	var m *big.Int
-	// Check if M ≤ q and set m accordingly
-	if M.Cmp(elGamal.q) <= 0 {
-		m = new(big.Int).Sub(M, big.NewInt(1)) // m = M - 1. If M ≤ q
-	} else {
-		negatedM := new(big.Int).Neg(M)
-		m = new(big.Int).Sub(negatedM, big.NewInt(1)) // m = -M - 1
-	}
+	shared := elGamal.group.Exp(c1, sk) // shared = c1^sk
 
-	return m.Mod(m, elGamal.p) // M = m mod p
+	return elGamal.group.UnmaskMessage(c2, shared)
 }
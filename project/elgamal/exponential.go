@@ -0,0 +1,123 @@
+package elgamal
+
+import (
+	"errors"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"math/big"
+)
+
+// Exponential ElGamal encrypts a number m in the exponent, c1 = g^r and c2 = g^m · pk^r, which makes the scheme
+// additively homomorphic: the componentwise product of two ciphertexts encrypts the sum of their plaintexts.
+// Decryption recovers g^m, so only small results can be decrypted, with a baby-step giant-step search in
+// O(√max) time and memory. The ciphertexts are regular Ciphertext values, and must not be mixed with the ones
+// of Encrypt, whose messages are encoded differently.
+
+// ErrPlaintextOutOfRange is returned when the plaintext of an exponential ciphertext is not in the searched range.
+var ErrPlaintextOutOfRange = errors.New("plaintext out of range")
+
+// MaxExponentialPlaintext is the largest maximum of DecryptExponential, 2^40. The table of baby steps then has at most
+// 2^20 entries (about 40 MB), and a larger maximum would take too much memory and time.
+const MaxExponentialPlaintext = 1 << 40
+
+// EncryptExponential encrypts the number m under the public key pk in the exponent: c1 = g^r, c2 = g^m · pk^r.
+// The number is reduced mod q, so a negative m encrypts q + m. Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) EncryptExponential(m *big.Int, pk *big.Int) (*Ciphertext, error) {
+	r, err := elGamal.randomExponent() // random number r ∈ [1, q-1]
+	if err != nil {
+		return nil, err
+	}
+
+	c1 := elGamal.group.ExpBase(r)                                              // c1 = g^r
+	c2 := elGamal.group.Mul(elGamal.group.ExpBase(m), elGamal.group.Exp(pk, r)) // c2 = g^m * pk^r
+
+	return &Ciphertext{c1, c2}, nil
+}
+
+// Add returns an encryption of the sum of the plaintexts of two exponential ciphertexts under the same key:
+// (a1 · b1, a2 · b2) = (g^(r+s), g^(m+n) · pk^(r+s)).
+func (elGamal *ElGamal) Add(a *Ciphertext, b *Ciphertext) *Ciphertext {
+	return &Ciphertext{
+		C1: elGamal.group.Mul(a.C1, b.C1),
+		C2: elGamal.group.Mul(a.C2, b.C2),
+	}
+}
+
+// ScalarMul returns an encryption of k times the plaintext of an exponential ciphertext: (c1^k, c2^k).
+// The result has the randomness k·r, so it should be rerandomized before it is sent to the key owner if k must
+// stay hidden.
+func (elGamal *ElGamal) ScalarMul(ciphertext *Ciphertext, k *big.Int) *Ciphertext {
+	return &Ciphertext{
+		C1: elGamal.group.Exp(ciphertext.C1, k),
+		C2: elGamal.group.Exp(ciphertext.C2, k),
+	}
+}
+
+// Rerandomize returns a fresh encryption of the same plaintext under pk by adding an encryption of 0, so the
+// result cannot be linked to the original ciphertext. It works for the ciphertexts of Encrypt as well.
+// Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) Rerandomize(ciphertext *Ciphertext, pk *big.Int) (*Ciphertext, error) {
+	r, err := elGamal.randomExponent() // random number r ∈ [1, q-1]
+	if err != nil {
+		return nil, err
+	}
+	return &Ciphertext{
+		C1: elGamal.group.Mul(ciphertext.C1, elGamal.group.ExpBase(r)), // c1 * g^r
+		C2: elGamal.group.Mul(ciphertext.C2, elGamal.group.Exp(pk, r)), // c2 * pk^r
+	}, nil
+}
+
+// DecryptExponential decrypts an exponential ciphertext with the secret key sk, for a plaintext in [0, max].
+// It computes g^m = c2 · (c1^sk)^-1 and finds m with the baby-step giant-step algorithm: with n = ⌈√(max+1)⌉,
+// the baby steps g^j for j ∈ [0, n) are stored in a table, and the giant steps g^m · g^(-n·i) are looked up in
+// the table until g^m · g^(-n·i) = g^j, i.e. m = n·i + j.
+// The table is keyed by a 64-bit hash of the encoding of g^j, and a match is confirmed by recomputing g^m.
+// Returns an error wrapping ErrPlaintextOutOfRange if the plaintext is not in [0, max] or max is not in
+// [0, MaxExponentialPlaintext], or ErrDecryptionFailed if the ciphertext is not a pair of group elements.
+func (elGamal *ElGamal) DecryptExponential(ciphertext *Ciphertext, sk *big.Int, max int64) (*big.Int, error) {
+	if max < 0 || max > MaxExponentialPlaintext {
+		return nil, fmt.Errorf("%w: the maximum %d is not in [0, %d]", ErrPlaintextOutOfRange, max, int64(MaxExponentialPlaintext))
+	}
+	group := elGamal.group
+	if ciphertext == nil || !group.Contains(ciphertext.C1) || !group.Contains(ciphertext.C2) {
+		return nil, fmt.Errorf("%w: the ciphertext is not a pair of group elements", ErrDecryptionFailed)
+	}
+
+	// g^m = c2 * (c1^sk)^-1
+	gm := group.Mul(ciphertext.C2, group.Inverse(group.Exp(ciphertext.C1, sk)))
+	seed := maphash.MakeSeed()
+	key := func(element *big.Int) uint64 {
+		return maphash.Bytes(seed, group.MarshalElement(element))
+	}
+
+	n := int64(math.Ceil(math.Sqrt(float64(max) + 1)))
+
+	// Baby steps: g^j for j ∈ [0, n)
+	babySteps := make(map[uint64]int64, n)
+	g := group.Generator()
+	element := group.Identity()
+	for j := int64(0); j < n; j++ {
+		if _, ok := babySteps[key(element)]; !ok {
+			babySteps[key(element)] = j
+		}
+		element = group.Mul(element, g)
+	}
+
+	// Giant steps: g^m * g^(-n·i) for i ∈ [0, n]
+	giantStep := group.ExpBase(big.NewInt(-n)) // g^-n, the exponent is reduced mod q
+	target := gm
+	for i := int64(0); i <= n; i++ {
+		if j, ok := babySteps[key(target)]; ok {
+			m := n*i + j
+			if m > max {
+				break
+			}
+			if group.ExpBase(big.NewInt(m)).Cmp(gm) == 0 { // Not a collision of the keys
+				return big.NewInt(m), nil
+			}
+		}
+		target = group.Mul(target, giantStep)
+	}
+	return nil, fmt.Errorf("%w: the plaintext is not in [0, %d]", ErrPlaintextOutOfRange, max)
+}
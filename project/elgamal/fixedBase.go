@@ -0,0 +1,58 @@
+package elgamal
+
+import (
+	"math/big"
+	"sync"
+)
+
+// Width in bits of the windows of the fixed-base tables. A table for a 2048-bit q has 410 windows of 32 elements
+// (3.4 MB for a 2048-bit p), and an exponentiation takes 410 multiplications instead of 2048 squarings.
+const fixedBaseWindow = 5
+
+// fixedBaseTable holds the powers base^(d·2^(w·i)) mod p of a fixed base for every window i of w bits of an
+// exponent and every digit d ∈ [0, 2^w - 1], so base^e is the product of one table entry per digit of e. The entry
+// of the digit 0 is 1, so every window takes one multiplication, and the number of multiplications does not depend
+// on the exponent. The table is built on first use, and shared by all copies of the group.
+type fixedBaseTable struct {
+	once  sync.Once
+	table [][]*big.Int // table[i][d] = base^(d·2^(w·i)) mod p
+}
+
+// build computes the table for exponents of up to bits bits.
+func (fixedBase *fixedBaseTable) build(base *big.Int, modulus *big.Int, bits int) {
+	windows := (bits + fixedBaseWindow - 1) / fixedBaseWindow
+	fixedBase.table = make([][]*big.Int, windows)
+
+	windowBase := new(big.Int).Set(base) // base^(2^(w·i))
+	for i := range fixedBase.table {
+		row := make([]*big.Int, 1<<fixedBaseWindow)
+		row[0] = big.NewInt(1)
+		row[1] = new(big.Int).Set(windowBase)
+		for d := 2; d < len(row); d++ {
+			row[d] = new(big.Int).Mul(row[d-1], windowBase)
+			row[d].Mod(row[d], modulus)
+		}
+		fixedBase.table[i] = row
+
+		windowBase.Mul(row[len(row)-1], windowBase) // base^((2^w - 1)·2^(w·i)) · base^(2^(w·i)) = base^(2^(w·(i+1)))
+		windowBase.Mod(windowBase, modulus)
+	}
+}
+
+// exp returns base^exponent mod p for 0 ≤ exponent < 2^bits, building the table on first use.
+func (fixedBase *fixedBaseTable) exp(base *big.Int, modulus *big.Int, bits int, exponent *big.Int) *big.Int {
+	fixedBase.once.Do(func() { fixedBase.build(base, modulus, bits) })
+
+	result := big.NewInt(1)
+	product := new(big.Int)
+	for i, row := range fixedBase.table {
+		// Digit i of the exponent, i.e. bits [w·i, w·i + w)
+		digit := 0
+		for b := fixedBaseWindow - 1; b >= 0; b-- {
+			digit = digit<<1 | int(exponent.Bit(fixedBaseWindow*i+b))
+		}
+		product.Mul(result, row[digit])
+		result.Mod(product, modulus)
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package elgamal
+
+import (
+	"errors"
+	"io"
+	"math/big"
+)
+
+// ErrInvalidGroup is returned when group parameters are unknown, malformed or fail validation.
+var ErrInvalidGroup = errors.New("invalid group parameters")
+
+// ErrInvalidElement is returned when a received public key or ciphertext component is not an element of the group,
+// or is the identity.
+var ErrInvalidElement = errors.New("invalid group element")
+
+// Group is a cyclic group of prime order q in which DDH is assumed to be hard, the setting of the ElGamal
+// cryptosystem. Elements are represented as *big.Int, so public keys and ciphertexts have the same type and wire
+// format in every group: a residue mod p in ModPGroup, and the integer value of the compressed point encoding in
+// P256Group. Methods other than Contains and UnmarshalElement assume their arguments are elements of the group, so
+// elements received from the other party must be checked first (see ElGamal.ValidateElement).
+type Group interface {
+	// Name returns a short name of the group, e.g. "P-256".
+	Name() string
+
+	// Order returns the prime order q of the group.
+	Order() *big.Int
+
+	// Generator returns the generator g of the group.
+	Generator() *big.Int
+
+	// Identity returns the identity element, i.e. the point at infinity on an elliptic curve.
+	Identity() *big.Int
+
+	// Validate checks that the parameters of the group are sound, e.g. after receiving them from the other party.
+	Validate() error
+
+	// Exp returns element^exponent, i.e. a scalar multiplication on an elliptic curve. The exponent is reduced mod q.
+	Exp(element *big.Int, exponent *big.Int) *big.Int
+
+	// ExpBase returns g^exponent for the generator g. Implementations use precomputed powers of g, so it is faster
+	// than Exp(Generator(), exponent).
+	ExpBase(exponent *big.Int) *big.Int
+
+	// Mul returns the product a·b of two elements, i.e. a point addition on an elliptic curve.
+	Mul(a *big.Int, b *big.Int) *big.Int
+
+	// Inverse returns the inverse of an element.
+	Inverse(element *big.Int) *big.Int
+
+	// Contains reports whether a value is an element of the group.
+	Contains(element *big.Int) bool
+
+	// RandomElement returns a uniformly random element whose discrete logarithm is not known to anyone, drawn from
+	// random. Used as the oblivious public keys of OGen, which must be indistinguishable from real keys g^sk.
+	RandomElement(random io.Reader) (*big.Int, error)
+
+	// MarshalElement returns the fixed-length byte encoding of an element.
+	MarshalElement(element *big.Int) []byte
+
+	// UnmarshalElement decodes an element encoded with MarshalElement, and returns an error if it is not in the group.
+	UnmarshalElement(data []byte) (*big.Int, error)
+
+	// MessageBits returns the maximum bit length of the messages that MaskMessage can encrypt.
+	MessageBits() int
+
+	// MaskMessage encodes the message m of at most MessageBits bits with the shared element pk^r of an encryption,
+	// giving the second part c2 of the ciphertext.
+	MaskMessage(m *big.Int, shared *big.Int) *big.Int
+
+	// UnmaskMessage recovers the message from c2 and the shared element c1^sk.
+	UnmaskMessage(c2 *big.Int, shared *big.Int) *big.Int
+}
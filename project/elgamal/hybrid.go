@@ -0,0 +1,127 @@
+package elgamal
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// The hybrid mode encrypts byte strings of any length and content, which the ElGamal messages of Encrypt cannot
+// hold: a message is a number smaller than q, so it loses its leading zero bytes. The group element
+// encapsulates a key (KEM), c1 = g^r with the key derived from pk^r, and the message is encrypted with AES-256-GCM
+// under that key (DEM). Every key encrypts a single message, so the GCM nonce is fixed to zero.
+// The key derivation also takes a context, e.g. the session ID of a protocol run, so a ciphertext only decrypts with
+// the context it was encrypted with. A nil context is the same as an empty one.
+
+// ErrDecryptionFailed is returned when a ciphertext does not decrypt, e.g. a hybrid ciphertext that was modified or
+// encrypted under another public key.
+var ErrDecryptionFailed = errors.New("decryption failed")
+
+// Label of the key derivation of the hybrid mode.
+const hybridKeyLabel = "ElGamal hybrid key"
+
+// HybridCiphertext is a ciphertext of the hybrid mode: the encapsulation C1 = g^r and the AES-GCM ciphertext of the
+// message, which is 16 bytes longer than the message.
+type HybridCiphertext struct {
+	C1   *big.Int
+	Data []byte
+}
+
+// hybridAEAD derives the AES-256-GCM key from the context, the encapsulation c1 and the shared element pk^r = c1^sk.
+func (elGamal *ElGamal) hybridAEAD(context []byte, c1 *big.Int, shared *big.Int) cipher.AEAD {
+	hash := sha256.New()
+	hash.Write([]byte(hybridKeyLabel))
+	hash.Write(binary.BigEndian.AppendUint32(nil, uint32(len(context))))
+	hash.Write(context)
+	encapsulation := c1.Bytes()
+	hash.Write(binary.BigEndian.AppendUint32(nil, uint32(len(encapsulation))))
+	hash.Write(encapsulation)
+	hash.Write(elGamal.group.MarshalElement(shared))
+
+	block, err := aes.NewCipher(hash.Sum(nil))
+	if err != nil {
+		panic(err) // A 32-byte key is always valid
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return aead
+}
+
+// EncryptHybrid encrypts a message of any length under the public key pk and the context with the hybrid mode.
+// Returns an error if the source of randomness fails.
+func (elGamal *ElGamal) EncryptHybrid(message []byte, pk *big.Int, context []byte) (*HybridCiphertext, error) {
+	r, err := elGamal.randomExponent() // random number r ∈ [1, q-1]
+	if err != nil {
+		return nil, err
+	}
+	return elGamal.encryptHybridWith(message, pk, context, r), nil
+}
+
+// encryptHybridWith encrypts the message under pk and the context with the randomness r.
+func (elGamal *ElGamal) encryptHybridWith(message []byte, pk *big.Int, context []byte, r *big.Int) *HybridCiphertext {
+	c1 := elGamal.group.ExpBase(r)     // c1 = g^r
+	shared := elGamal.group.Exp(pk, r) // shared = pk^r
+	aead := elGamal.hybridAEAD(context, c1, shared)
+	return &HybridCiphertext{C1: c1, Data: aead.Seal(nil, make([]byte, aead.NonceSize()), message, nil)}
+}
+
+// DecryptHybrid decrypts a ciphertext of EncryptHybrid with the secret key sk and the context, and returns the exact
+// message. Returns an error wrapping ErrDecryptionFailed if the ciphertext is malformed, was modified, or was
+// encrypted under another public key or context, and also ErrInvalidElement if the encapsulation is not a valid
+// group element.
+func (elGamal *ElGamal) DecryptHybrid(ciphertext *HybridCiphertext, sk *big.Int, context []byte) ([]byte, error) {
+	if ciphertext == nil {
+		return nil, fmt.Errorf("%w: the ciphertext is missing", ErrDecryptionFailed)
+	}
+	if err := elGamal.ValidateElement(ciphertext.C1); err != nil {
+		return nil, fmt.Errorf("%w: the encapsulation: %w", ErrDecryptionFailed, err)
+	}
+	shared := elGamal.group.Exp(ciphertext.C1, sk) // shared = c1^sk = pk^r
+	aead := elGamal.hybridAEAD(context, ciphertext.C1, shared)
+	message, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext.Data, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	if message == nil {
+		message = []byte{} // An empty message decrypts to an empty, not a nil, slice
+	}
+	return message, nil
+}
+
+// EncryptHybridBatch encrypts messages[i] under publicKeys[i] and the common context for every i, like EncryptHybrid.
+// The slices must have the same length.
+func (elGamal *ElGamal) EncryptHybridBatch(messages [][]byte, publicKeys []*big.Int, context []byte) ([]*HybridCiphertext, error) {
+	randomness, err := elGamal.randomExponents(len(messages))
+	if err != nil {
+		return nil, err
+	}
+
+	ciphertexts := make([]*HybridCiphertext, len(messages))
+	parallelFor(len(messages), elGamal.workerCount(), func(i int) {
+		ciphertexts[i] = elGamal.encryptHybridWith(messages[i], publicKeys[i], context, randomness[i])
+	})
+	return ciphertexts, nil
+}
+
+// DecryptHybridBatch decrypts ciphertexts[i] with secretKeys[i] and the common context for every i, like
+// DecryptHybrid, and returns the error of the first ciphertext that does not decrypt. The slices must have the same
+// length.
+func (elGamal *ElGamal) DecryptHybridBatch(ciphertexts []*HybridCiphertext, secretKeys []*big.Int, context []byte) ([][]byte, error) {
+	messages := make([][]byte, len(ciphertexts))
+	errs := make([]error, len(ciphertexts))
+	parallelFor(len(ciphertexts), elGamal.workerCount(), func(i int) {
+		messages[i], errs[i] = elGamal.DecryptHybrid(ciphertexts[i], secretKeys[i], context)
+	})
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("ciphertext %d: %w", i, err)
+		}
+	}
+	return messages, nil
+}
@@ -0,0 +1,196 @@
+package elgamal
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// ModPGroup is the subgroup G of order q of the quadratic residues in Z_p^*, for a safe prime p = 2q + 1, with
+// generator g. This is the group of the original protocol, generated by ElGamal.Init.
+type ModPGroup struct {
+	p *big.Int // prime number defining the finite field F_p
+	q *big.Int // order of group G (cyclic subgroup of F_p). Notice, q | p-1
+	g *big.Int // generator of group G
+
+	name      string          // name of a standard group, or empty
+	fixedBase *fixedBaseTable // precomputed powers of g for ExpBase
+}
+
+// NewModPGroup creates the group of order q in Z_p^* generated by g. The parameters are not checked.
+func NewModPGroup(p *big.Int, q *big.Int, g *big.Int) *ModPGroup {
+	return &ModPGroup{p: p, q: q, g: g, fixedBase: &fixedBaseTable{}}
+}
+
+// Name returns the name of a standard group, e.g. "ffdhe2048", or otherwise "modp-" followed by the bit length of p.
+func (group *ModPGroup) Name() string {
+	if group.name != "" {
+		return group.name
+	}
+	return fmt.Sprintf("modp-%d", group.p.BitLen())
+}
+
+// Modulus returns the prime p.
+func (group *ModPGroup) Modulus() *big.Int {
+	return group.p
+}
+
+func (group *ModPGroup) Order() *big.Int {
+	return group.q
+}
+
+func (group *ModPGroup) Generator() *big.Int {
+	return group.g
+}
+
+// Validate checks parameters received from the other party or loaded from a file: p and q must be prime, q must
+// divide p-1, and g must have order q. The message encoding moreover needs the safe prime p = 2q + 1.
+// Returns an error wrapping ErrInvalidGroup if a check fails.
+func (group *ModPGroup) Validate() error {
+	one := big.NewInt(1)
+	if group.p == nil || group.q == nil || group.g == nil {
+		return fmt.Errorf("%w: p, q and g must be set", ErrInvalidGroup)
+	}
+	if !group.p.ProbablyPrime(20) {
+		return fmt.Errorf("%w: p is not prime", ErrInvalidGroup)
+	}
+	if !group.q.ProbablyPrime(20) {
+		return fmt.Errorf("%w: q is not prime", ErrInvalidGroup)
+	}
+	pMinusOne := new(big.Int).Sub(group.p, one)
+	cofactor, remainder := new(big.Int).QuoRem(pMinusOne, group.q, new(big.Int))
+	if remainder.Sign() != 0 {
+		return fmt.Errorf("%w: q does not divide p-1", ErrInvalidGroup)
+	}
+	if cofactor.Cmp(big.NewInt(2)) != 0 {
+		return fmt.Errorf("%w: p is not the safe prime 2q + 1", ErrInvalidGroup)
+	}
+	// g ∈ [2, p-2] and g^q = 1 mod p, so the order of g divides the prime q and is not 1, i.e. it is q
+	if group.g.Cmp(one) <= 0 || group.g.Cmp(pMinusOne) >= 0 || !group.Contains(group.g) {
+		return fmt.Errorf("%w: g does not have order q", ErrInvalidGroup)
+	}
+	return nil
+}
+
+// Exp returns element^exponent mod p, with the exponent reduced mod q.
+func (group *ModPGroup) Exp(element *big.Int, exponent *big.Int) *big.Int {
+	e := new(big.Int).Mod(exponent, group.q)
+	return new(big.Int).Exp(element, e, group.p)
+}
+
+// ExpBase returns g^exponent mod p with the fixed-base table of g, which is built on the first call.
+func (group *ModPGroup) ExpBase(exponent *big.Int) *big.Int {
+	e := new(big.Int).Mod(exponent, group.q)
+	if group.fixedBase == nil {
+		return new(big.Int).Exp(group.g, e, group.p)
+	}
+	return group.fixedBase.exp(group.g, group.p, group.q.BitLen(), e)
+}
+
+// Mul returns a·b mod p.
+func (group *ModPGroup) Mul(a *big.Int, b *big.Int) *big.Int {
+	product := new(big.Int).Mul(a, b)
+	return product.Mod(product, group.p)
+}
+
+// Inverse returns element^-1 mod p.
+func (group *ModPGroup) Inverse(element *big.Int) *big.Int {
+	return new(big.Int).ModInverse(element, group.p)
+}
+
+// Identity returns 1.
+func (group *ModPGroup) Identity() *big.Int {
+	return big.NewInt(1)
+}
+
+// Contains reports whether 0 < element < p and element^q = 1 mod p. For a safe prime p = 2q + 1, G is the group of
+// quadratic residues, so the Jacobi symbol decides it much faster than the exponentiation.
+func (group *ModPGroup) Contains(element *big.Int) bool {
+	if element == nil || element.Sign() <= 0 || element.Cmp(group.p) >= 0 {
+		return false
+	}
+	if safe := new(big.Int).Lsh(group.q, 1); safe.Add(safe, big.NewInt(1)).Cmp(group.p) == 0 {
+		return big.Jacobi(element, group.p) == 1
+	}
+	return new(big.Int).Exp(element, group.q, group.p).Cmp(big.NewInt(1)) == 0
+}
+
+// RandomElement returns a random "fake" public key following the second method in exercise 5: a random r of twice
+// the bit length of p, so r mod p is close to uniform in Z_p^*. It is squared to land in G, since a non-residue
+// would tell it apart from a real key g^sk.
+func (group *ModPGroup) RandomElement(random io.Reader) (*big.Int, error) {
+	n := group.p.BitLen() // Get the bit length of p
+
+	// Create 2^(2n) upper bound for random number
+	upperBound := new(big.Int).Lsh(big.NewInt(1), uint(2*n)) // Left shift is equivalent to multiplying by 2 raised to a power
+
+	for {
+		r, err := rand.Int(random, upperBound)
+		if err != nil {
+			return nil, fmt.Errorf("error reading randomness: %v", err)
+		}
+		r.Mod(r, group.p)
+		if r.Sign() != 0 { // Ensure r is not zero
+			return r.Exp(r, big.NewInt(2), group.p), nil
+		}
+	}
+}
+
+// MarshalElement returns the element as a big-endian number of the byte length of p.
+func (group *ModPGroup) MarshalElement(element *big.Int) []byte {
+	return element.FillBytes(make([]byte, (group.p.BitLen()+7)/8))
+}
+
+func (group *ModPGroup) UnmarshalElement(data []byte) (*big.Int, error) {
+	element := new(big.Int).SetBytes(data)
+	if len(data) != (group.p.BitLen()+7)/8 || !group.Contains(element) {
+		return nil, errors.New("invalid element of the group")
+	}
+	return element, nil
+}
+
+// MessageBits returns one less than the bit length of q, since the messages are encoded as m + 1 ≤ q.
+func (group *ModPGroup) MessageBits() int {
+	return group.q.BitLen() - 1
+}
+
+// MaskMessage first encodes the message m with the third encoding method from the notes:
+// "check if (m + 1)^q = 1 mod p. If yes, encrypt M = m + 1. If not, encrypt M = −(m + 1)".
+// The check is computed as the Legendre symbol of m + 1, which is much faster than the exponentiation.
+// Afterwards, c2 = M · shared mod p as in the ElGamal encryption scheme.
+func (group *ModPGroup) MaskMessage(m *big.Int, shared *big.Int) *big.Int {
+
+	// Encoding of m
+	var M *big.Int
+	mPlusOne := new(big.Int).Add(m, big.NewInt(1)) // m + 1
+	if big.Jacobi(mPlusOne, group.p) == 1 {        // Check If (m + 1)^q = 1 mod p
+		M = mPlusOne // M = m + 1.
+	} else {
+		M = new(big.Int).Neg(mPlusOne) // M = -(m + 1)
+	}
+
+	c2 := new(big.Int).Mul(M, shared) // c2 = M * shared
+	return c2.Mod(c2, group.p)        // c2 = M * shared mod p
+}
+
+// UnmaskMessage computes M = c2 · shared^-1 mod p, and decodes it with the decoding method from the notes:
+// "If M ≤ q, then m = M − 1, otherwise m = −M − 1."
+func (group *ModPGroup) UnmaskMessage(c2 *big.Int, shared *big.Int) *big.Int {
+
+	M := new(big.Int).Mul(c2, group.Inverse(shared)) // M = c2 * shared^-1
+	M = M.Mod(M, group.p)                            // M = c2 * shared^-1 mod p
+
+	// Decode M
+	var m *big.Int
+	// Check if M ≤ q and set m accordingly
+	if M.Cmp(group.q) <= 0 {
+		m = new(big.Int).Sub(M, big.NewInt(1)) // m = M - 1. If M ≤ q
+	} else {
+		negatedM := new(big.Int).Neg(M)
+		m = new(big.Int).Sub(negatedM, big.NewInt(1)) // m = -M - 1
+	}
+
+	return m.Mod(m, group.p) // M = m mod p
+}
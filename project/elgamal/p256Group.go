@@ -0,0 +1,189 @@
+package elgamal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"filippo.io/nistec"
+)
+
+// Length in bytes of the compressed encoding of a point of P-256: a prefix byte 0x02 or 0x03 and the x-coordinate.
+const p256ElementLength = 33
+
+// Bit length of the messages of P256Group. The messages are masked with a key stream derived from the shared point,
+// so they are not limited by the size of the curve, and the limit matches a 2048-bit ModPGroup.
+const p256MessageBits = 2048
+
+// Order n of the base point of P-256 (FIPS 186-5, SP 800-186).
+var p256Order, _ = new(big.Int).SetString("ffffffff00000000ffffffffffffffffbce6faada7179e84f3b9cac2fc632551", 16)
+
+// P256Group is the group of points of the NIST P-256 curve, which has prime order and a 256-bit base field, so
+// exponentiations are much faster than in a 2048-bit ModPGroup at a similar security level. An element is the
+// integer value of the compressed encoding of a point (SEC 1), and the point at infinity is 0.
+// The arithmetic is the constant-time field arithmetic of filippo.io/nistec, the exported version of the
+// implementation behind crypto/ecdh, since crypto/ecdh has no point addition.
+// Messages cannot be embedded in points, so the ciphertexts are hashed ElGamal: c2 = m ⊕ H(pk^r).
+type P256Group struct{}
+
+// NewP256Group creates the P-256 group.
+func NewP256Group() *P256Group {
+	return &P256Group{}
+}
+
+func (group *P256Group) Name() string {
+	return "P-256"
+}
+
+func (group *P256Group) Order() *big.Int {
+	return new(big.Int).Set(p256Order)
+}
+
+// Generator returns the base point of the curve.
+func (group *P256Group) Generator() *big.Int {
+	return group.encode(nistec.NewP256Point().SetGenerator())
+}
+
+// Identity returns the point at infinity, 0.
+func (group *P256Group) Identity() *big.Int {
+	return new(big.Int)
+}
+
+// Validate returns nil, since the parameters of the curve are fixed by the standard and not received or loaded.
+func (group *P256Group) Validate() error {
+	return nil
+}
+
+// encode returns the element of a point, which is 0 for the point at infinity.
+func (group *P256Group) encode(point *nistec.P256Point) *big.Int {
+	return new(big.Int).SetBytes(point.BytesCompressed()) // The point at infinity is the single byte 0
+}
+
+// decode returns the point of an element, and false if it does not encode a point.
+func (group *P256Group) decode(element *big.Int) (*nistec.P256Point, bool) {
+	if element == nil || element.Sign() < 0 || element.BitLen() > 8*p256ElementLength {
+		return nil, false
+	}
+	if element.Sign() == 0 {
+		return nistec.NewP256Point(), true
+	}
+	point, err := nistec.NewP256Point().SetBytes(element.FillBytes(make([]byte, p256ElementLength)))
+	return point, err == nil
+}
+
+// scalar returns the exponent reduced mod n as the 32-byte big-endian scalar of nistec.
+func (group *P256Group) scalar(exponent *big.Int) []byte {
+	return new(big.Int).Mod(exponent, p256Order).FillBytes(make([]byte, 32))
+}
+
+// Exp returns the scalar multiplication [exponent]element, with the exponent reduced mod q. An invalid element is
+// treated as the point at infinity, so elements received from the other party must be checked with Contains, e.g.
+// with ElGamal.ValidateElement.
+func (group *P256Group) Exp(element *big.Int, exponent *big.Int) *big.Int {
+	point, ok := group.decode(element)
+	if !ok {
+		return new(big.Int)
+	}
+	result, err := nistec.NewP256Point().ScalarMult(point, group.scalar(exponent))
+	if err != nil {
+		panic(err) // The scalar always has 32 bytes
+	}
+	return group.encode(result)
+}
+
+// ExpBase returns the scalar multiplication [exponent]G of the base point, which uses precomputed tables.
+func (group *P256Group) ExpBase(exponent *big.Int) *big.Int {
+	result, err := nistec.NewP256Point().ScalarBaseMult(group.scalar(exponent))
+	if err != nil {
+		panic(err) // The scalar always has 32 bytes
+	}
+	return group.encode(result)
+}
+
+// Mul returns the point addition a + b.
+func (group *P256Group) Mul(a *big.Int, b *big.Int) *big.Int {
+	p1, ok1 := group.decode(a)
+	p2, ok2 := group.decode(b)
+	if !ok1 || !ok2 {
+		return new(big.Int)
+	}
+	return group.encode(nistec.NewP256Point().Add(p1, p2))
+}
+
+// Inverse returns the negation (x, -y) of a point.
+func (group *P256Group) Inverse(element *big.Int) *big.Int {
+	point, ok := group.decode(element)
+	if !ok {
+		return new(big.Int)
+	}
+	return group.encode(nistec.NewP256Point().Negate(point))
+}
+
+// Contains reports whether the value encodes a point of the curve, including the point at infinity 0.
+func (group *P256Group) Contains(element *big.Int) bool {
+	_, ok := group.decode(element)
+	return ok
+}
+
+// RandomElement returns a uniformly random point by sampling a random x-coordinate until it is the x-coordinate of
+// a point, and a random sign of y. The curve has prime order, so the point is uniform in the group like a real key
+// g^sk, and nobody knows its discrete logarithm.
+func (group *P256Group) RandomElement(random io.Reader) (*big.Int, error) {
+	buffer := make([]byte, 1+p256ElementLength)
+	for {
+		if _, err := io.ReadFull(random, buffer); err != nil {
+			return nil, fmt.Errorf("error reading randomness: %v", err)
+		}
+		encoding := buffer[1:]
+		encoding[0] = 2 | buffer[0]&1 // Compressed encoding with a random sign of y
+		// The encoding is rejected if x ≥ p or x^3 - 3x + b is not a square
+		if point, err := nistec.NewP256Point().SetBytes(encoding); err == nil {
+			return group.encode(point), nil
+		}
+	}
+}
+
+// MarshalElement returns the 33-byte compressed encoding of a point, or 33 zero bytes for the point at infinity.
+func (group *P256Group) MarshalElement(element *big.Int) []byte {
+	return element.FillBytes(make([]byte, p256ElementLength))
+}
+
+func (group *P256Group) UnmarshalElement(data []byte) (*big.Int, error) {
+	element := new(big.Int).SetBytes(data)
+	if len(data) != p256ElementLength || !group.Contains(element) {
+		return nil, errors.New("invalid point of P-256")
+	}
+	return element, nil
+}
+
+// MessageBits returns the maximum bit length of a message, which is 2048.
+func (group *P256Group) MessageBits() int {
+	return p256MessageBits
+}
+
+// keyStream derives p256MessageBits bits from the shared point with SHA-256 in counter mode.
+func (group *P256Group) keyStream(shared *big.Int) *big.Int {
+	point := group.MarshalElement(shared)
+	stream := make([]byte, 0, p256MessageBits/8)
+	for counter := uint32(0); len(stream) < p256MessageBits/8; counter++ {
+		hash := sha256.New()
+		hash.Write([]byte("P-256 ElGamal key stream"))
+		hash.Write(binary.BigEndian.AppendUint32(nil, counter))
+		hash.Write(point)
+		stream = hash.Sum(stream)
+	}
+	return new(big.Int).SetBytes(stream)
+}
+
+// MaskMessage returns c2 = m ⊕ H(shared), where H(shared) is a key stream of MessageBits bits.
+func (group *P256Group) MaskMessage(m *big.Int, shared *big.Int) *big.Int {
+	return new(big.Int).Xor(m, group.keyStream(shared))
+}
+
+// UnmaskMessage returns m = c2 ⊕ H(shared).
+func (group *P256Group) UnmaskMessage(c2 *big.Int, shared *big.Int) *big.Int {
+	return new(big.Int).Xor(c2, group.keyStream(shared))
+}
@@ -0,0 +1,141 @@
+package elgamal
+
+import (
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PEM block type of the parameters, the X9.42 Diffie-Hellman domain parameters also written by
+// "openssl genpkey -genparam -algorithm DHX", so the files can be inspected and created with OpenSSL.
+const parametersPEMType = "X9.42 DH PARAMETERS"
+
+// domainParameters is the ASN.1 structure of X9.42 domain parameters (RFC 3279, section 2.3.3).
+// The optional fields are parsed to accept files from OpenSSL, and not written.
+type domainParameters struct {
+	P                *big.Int
+	G                *big.Int
+	Q                *big.Int
+	J                *big.Int      `asn1:"optional"`
+	ValidationParams asn1.RawValue `asn1:"optional"`
+}
+
+// parametersJSON is the JSON encoding of the parameters, with p, q and g as hexadecimal strings.
+type parametersJSON struct {
+	P string `json:"p"`
+	Q string `json:"q"`
+	G string `json:"g"`
+}
+
+// newParsedModPGroup creates the group of parsed parameters, and names it if it is a standard group.
+func newParsedModPGroup(p *big.Int, q *big.Int, g *big.Int) (*ModPGroup, error) {
+	if p == nil || q == nil || g == nil || p.Sign() <= 0 || q.Sign() <= 0 || g.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: p, q and g must be positive", ErrInvalidGroup)
+	}
+	group := NewModPGroup(p, q, g)
+	for _, name := range StandardGroupNames() {
+		standard, _ := StandardGroup(name)
+		if standard.p.Cmp(p) == 0 && standard.q.Cmp(q) == 0 && standard.g.Cmp(g) == 0 {
+			group.name = name
+		}
+	}
+	return group, nil
+}
+
+// MarshalPEM returns the parameters as a PEM block of X9.42 domain parameters.
+func (group *ModPGroup) MarshalPEM() ([]byte, error) {
+	der, err := asn1.Marshal(domainParameters{P: group.p, G: group.g, Q: group.q})
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: parametersPEMType, Bytes: der}), nil
+}
+
+// ParseModPGroupPEM parses parameters written by MarshalPEM. The parameters are not validated, see Validate.
+// Returns an error wrapping ErrInvalidGroup if the data is not a PEM block of X9.42 domain parameters.
+func ParseModPGroupPEM(data []byte) (*ModPGroup, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != parametersPEMType {
+		return nil, fmt.Errorf("%w: no %s PEM block", ErrInvalidGroup, parametersPEMType)
+	}
+	var parameters domainParameters
+	rest, err := asn1.Unmarshal(block.Bytes, &parameters)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGroup, err)
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing data after the parameters", ErrInvalidGroup)
+	}
+	return newParsedModPGroup(parameters.P, parameters.Q, parameters.G)
+}
+
+// MarshalJSON returns the parameters as a JSON object with p, q and g as hexadecimal strings.
+func (group *ModPGroup) MarshalJSON() ([]byte, error) {
+	return json.Marshal(parametersJSON{P: group.p.Text(16), Q: group.q.Text(16), G: group.g.Text(16)})
+}
+
+// UnmarshalJSON parses parameters written by MarshalJSON. The parameters are not validated, see Validate.
+func (group *ModPGroup) UnmarshalJSON(data []byte) error {
+	var parameters parametersJSON
+	if err := json.Unmarshal(data, &parameters); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidGroup, err)
+	}
+	values := make([]*big.Int, 3)
+	for i, text := range []string{parameters.P, parameters.Q, parameters.G} {
+		value, ok := new(big.Int).SetString(text, 16)
+		if !ok {
+			return fmt.Errorf("%w: %q is not a hexadecimal number", ErrInvalidGroup, text)
+		}
+		values[i] = value
+	}
+	parsed, err := newParsedModPGroup(values[0], values[1], values[2])
+	if err != nil {
+		return err
+	}
+	*group = *parsed
+	return nil
+}
+
+// SaveModPGroup writes the parameters to a file, as JSON if the file name ends in ".json" and as PEM otherwise.
+func SaveModPGroup(path string, group *ModPGroup) error {
+	var data []byte
+	var err error
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		data, err = group.MarshalJSON()
+		data = append(data, '\n')
+	} else {
+		data, err = group.MarshalPEM()
+	}
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadModPGroup reads parameters written by SaveModPGroup, in either format, and validates them.
+// Returns an error wrapping ErrInvalidGroup if the file cannot be parsed or the parameters are invalid.
+func LoadModPGroup(path string) (*ModPGroup, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var group *ModPGroup
+	if block, _ := pem.Decode(data); block != nil {
+		group, err = ParseModPGroupPEM(data)
+	} else {
+		group = &ModPGroup{}
+		err = group.UnmarshalJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := group.Validate(); err != nil {
+		return nil, err
+	}
+	return group, nil
+}
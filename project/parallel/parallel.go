@@ -0,0 +1,61 @@
+// Package parallel splits loops over indexes across goroutines. It imports nothing from this module, so every
+// package, including elgamal, can use it.
+package parallel
+
+import (
+	"runtime"
+	"sync"
+)
+
+// DefaultWorkers returns the default number of goroutines used by the multithreaded methods, which is GOMAXPROCS.
+func DefaultWorkers() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// For splits the indexes [0, n) into at most workers contiguous ranges, and calls body on each range
+// in its own goroutine. It returns when all ranges are done. If workers ≤ 1, body is called once on the calling goroutine.
+func For(n int, workers int, body func(start int, end int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		body(0, n)
+		return
+	}
+
+	chunkSize := (n + workers - 1) / workers // Calculate chunk size for each goroutine
+
+	var wg sync.WaitGroup
+	for start := 0; start < n; start += chunkSize {
+		end := start + chunkSize
+		if end > n {
+			end = n
+		}
+		wg.Add(1)
+		go func(start int, end int) {
+			defer wg.Done()
+			body(start, end)
+		}(start, end)
+	}
+	wg.Wait() // Wait for all goroutines
+}
+
+// ForErr is For for a body that can fail. Every range is run to the end, and the error of the
+// first failing range (in index order) is returned.
+func ForErr(n int, workers int, body func(start int, end int) error) error {
+	var mutex sync.Mutex
+	firstStart := 0
+	var firstErr error
+
+	For(n, workers, func(start int, end int) {
+		if err := body(start, end); err != nil {
+			mutex.Lock()
+			if firstErr == nil || start < firstStart {
+				firstStart = start
+				firstErr = err
+			}
+			mutex.Unlock()
+		}
+	})
+	return firstErr
+}
@@ -2,15 +2,24 @@ package project
 
 import (
 	"bytes"
+	crand "crypto/rand"
+	"crypto/sha256"
 	OTBasic "cryptographic-computing/project/OTBasic"
 	OTExt "cryptographic-computing/project/OTExtension"
 	"cryptographic-computing/project/elgamal"
 	utils "cryptographic-computing/project/utils"
+	"errors"
+	"fmt"
+	"io"
 	"math"
 	"math/big"
 	"math/rand"
+	"os"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"testing"
+	"testing/iotest"
 )
 
 func TestOTBasicProtocol(t *testing.T) {
@@ -19,7 +28,9 @@ func TestOTBasicProtocol(t *testing.T) {
 
 	// create cryptoalgorithm, messages and selection bits for algorithms.
 	elGamal := elgamal.ElGamal{}
-	elGamal.Init()
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
 	selectionBits := utils.RandomSelectionBits(m)
 	var messages []*utils.MessagePair
 	for i := 0; i < m; i++ {
@@ -30,7 +41,10 @@ func TestOTBasicProtocol(t *testing.T) {
 		messages = append(messages, &msg)
 	}
 
-	plaintext := OTBasic.OTBasicProtocol(l, m, selectionBits, messages, elGamal)
+	plaintext, _, err := OTBasic.OTBasicProtocol(m, selectionBits, messages, elGamal)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
 
 	// Check if the plaintext is correct
 	for i := 0; i < m; i++ {
@@ -56,7 +70,9 @@ func TestOTExtensionProtocol(t *testing.T) {
 
 		// create cryptoalgorithm, messages and selection bits for algorithms.
 		elGamal := elgamal.ElGamal{}
-		elGamal.Init()
+		if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
 		selectionBits := utils.RandomSelectionBits(m)
 		var messages []*utils.MessagePair
 		for i := 0; i < m; i++ {
@@ -67,7 +83,10 @@ func TestOTExtensionProtocol(t *testing.T) {
 			messages = append(messages, &msg)
 		}
 
-		plaintext := OTExt.OTExtensionProtocol(k, l, m, selectionBits, messages, elGamal)
+		plaintext, _, err := OTExt.OTExtensionProtocol(k, m, selectionBits, messages, elGamal)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
 
 		// Check if the plaintext is correct
 		for i := 0; i < m; i++ {
@@ -85,6 +104,45 @@ func TestOTExtensionProtocol(t *testing.T) {
 
 }
 
+func TestOTExtensionProtocolVariableLengths(t *testing.T) {
+	k := 128
+	m := 256
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+	selectionBits := utils.RandomSelectionBits(m)
+
+	// Every pair has its own lengths, including empty messages and messages ending in zero bytes.
+	messages := make([]*utils.MessagePair, m)
+	for i := range messages {
+		messages[i] = &utils.MessagePair{
+			Message0: make([]byte, rand.Intn(40)),
+			Message1: make([]byte, rand.Intn(40)),
+		}
+		copy(messages[i].Message0, utils.RandomBits(8*len(messages[i].Message0)))
+		copy(messages[i].Message1, utils.RandomBits(8*len(messages[i].Message1)))
+	}
+	messages[0] = &utils.MessagePair{Message0: []byte{}, Message1: []byte{}}
+	messages[1] = &utils.MessagePair{Message0: []byte{1, 0, 0}, Message1: []byte{0x80}}
+
+	plaintext, _, err := OTExt.OTExtensionProtocol(k, m, selectionBits, messages, elGamal)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+
+	for i := 0; i < m; i++ {
+		expected := messages[i].Message0
+		if selectionBits[i] == 1 {
+			expected = messages[i].Message1
+		}
+		if !bytes.Equal(plaintext[i], expected) {
+			t.Errorf("Plaintext is not correct")
+		}
+	}
+}
+
 func TestOTExtensionProtocolTranspose(t *testing.T) {
 	k := 128
 	l := 1
@@ -94,7 +152,9 @@ func TestOTExtensionProtocolTranspose(t *testing.T) {
 
 		// create cryptoalgorithm, messages and selection bits for algorithms.
 		elGamal := elgamal.ElGamal{}
-		elGamal.Init()
+		if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
 		selectionBits := utils.RandomSelectionBits(m)
 		var messages []*utils.MessagePair
 		for i := 0; i < m; i++ {
@@ -105,7 +165,10 @@ func TestOTExtensionProtocolTranspose(t *testing.T) {
 			messages = append(messages, &msg)
 		}
 
-		plaintext := OTExt.OTExtensionProtocolTranspose(k, l, m, selectionBits, messages, elGamal)
+		plaintext, _, err := OTExt.OTExtensionProtocolTranspose(k, l, m, selectionBits, messages, elGamal)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
 
 		// Check if the plaintext is correct
 		for i := 0; i < m; i++ {
@@ -132,7 +195,9 @@ func TestOTExtensionProtocolEklundh(t *testing.T) {
 
 		// create cryptoalgorithm, messages and selection bits for algorithms.
 		elGamal := elgamal.ElGamal{}
-		elGamal.Init()
+		if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
 		selectionBits := utils.RandomSelectionBits(m)
 		var messages []*utils.MessagePair
 		for i := 0; i < m; i++ {
@@ -143,7 +208,10 @@ func TestOTExtensionProtocolEklundh(t *testing.T) {
 			messages = append(messages, &msg)
 		}
 
-		plaintext := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
+		plaintext, _, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
 
 		// Check if the plaintext is correct
 		for i := 0; i < m; i++ {
@@ -170,7 +238,9 @@ func TestOTExtensionProtocolEklundhMultithreaded(t *testing.T) {
 
 		// create cryptoalgorithm, messages and selection bits for algorithms.
 		elGamal := elgamal.ElGamal{}
-		elGamal.Init()
+		if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
 		selectionBits := utils.RandomSelectionBits(m)
 		var messages []*utils.MessagePair
 		for i := 0; i < m; i++ {
@@ -181,7 +251,10 @@ func TestOTExtensionProtocolEklundhMultithreaded(t *testing.T) {
 			messages = append(messages, &msg)
 		}
 
-		plaintext := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true)
+		plaintext, _, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
 
 		// Check if the plaintext is correct
 		for i := 0; i < m; i++ {
@@ -293,6 +366,34 @@ func TestEklundhTransposeNonSymmetricalMoreColsThanRows(t *testing.T) {
 	}
 }
 
+// TestEklundhTransposeArbitraryShapes compares EklundhTranspose with TransposeMatrix for random shapes,
+// including fewer columns than rows and dimensions that are not powers of 2.
+func TestEklundhTransposeArbitraryShapes(t *testing.T) {
+	shapes := [][2]int{{1, 1}, {1, 7}, {7, 1}, {3, 5}, {5, 3}, {127, 129}, {129, 127}, {128, 1000}, {1000, 128}, {192, 1536}, {1536, 192},
+		{129, 129}, {1000, 1000}, {1024, 1024}, {2000, 1999}}
+	random := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		shapes = append(shapes, [2]int{1 + random.Intn(300), 1 + random.Intn(300)})
+	}
+
+	for _, shape := range shapes {
+		rows, cols := shape[0], shape[1]
+		matrix := generateNonSymmetricMatrix(rows, cols)
+		original := utils.TransposeMatrix(utils.TransposeMatrix(matrix))
+		expected := utils.TransposeMatrix(matrix)
+
+		for _, multithreaded := range []bool{false, true} {
+			result := utils.EklundhTranspose(matrix, multithreaded)
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("EklundhTranspose failed for size %dx%d (multithreaded: %v)", rows, cols, multithreaded)
+			}
+		}
+		if !reflect.DeepEqual(matrix, original) {
+			t.Errorf("EklundhTranspose changed the input matrix of size %dx%d", rows, cols)
+		}
+	}
+}
+
 // generateNonSymmetricMatrix generates a non-symmetric matrix of size rows x cols.
 func generateNonSymmetricMatrix(rows, cols int) [][]byte {
 	matrix := make([][]byte, rows)
@@ -304,3 +405,2936 @@ func generateNonSymmetricMatrix(rows, cols int) [][]byte {
 	}
 	return matrix
 }
+
+// TestPseudoRandomGeneratorWords tests that the packed PseudoRandomGeneratorWords is consistent and
+// keeps the padding bits after bitLength set to 0.
+func TestPseudoRandomGeneratorWords(t *testing.T) {
+	seedValues := []int64{0, 1, 123, 4567, 89012, 345678}
+	outputLengths := []int{10, 50, 64, 100, 200, 500}
+
+	for _, seedVal := range seedValues {
+		for _, length := range outputLengths {
+
+			seed := big.NewInt(seedVal).FillBytes(make([]byte, 16))
+			output, err := utils.PseudoRandomGeneratorWords(seed, length)
+			if err != nil {
+				t.Errorf("Error returned for seed %d and length %d: %v", seedVal, length, err)
+			}
+			if len(output) != utils.WordsFor(length) {
+				t.Errorf("Output length is incorrect for seed %d and length %d. Expected %d words, got %d", seedVal, length, utils.WordsFor(length), len(output))
+			}
+			if length%64 != 0 && output[len(output)-1]>>(length%64) != 0 {
+				t.Errorf("Padding bits are not 0 for seed %d and length %d", seedVal, length)
+			}
+
+			output2, _ := utils.PseudoRandomGeneratorWords(seed, length)
+			if !reflect.DeepEqual(output, output2) {
+				t.Errorf("Inconsistent outputs for seed %d and length %d", seedVal, length)
+			}
+		}
+	}
+}
+
+// generateBitMatrix generates a random packed bit matrix of size rows x cols.
+func generateBitMatrix(rows, cols int) *utils.BitMatrix {
+	matrix := utils.NewBitMatrix(rows, cols)
+	for i := 0; i < rows; i++ {
+		for j := 0; j < cols; j++ {
+			matrix.SetBit(i, j, byte(rand.Intn(2)))
+		}
+	}
+	return matrix
+}
+
+// TestEklundhTransposeBitMatrix tests the word-level Eklundh transpose against the naive bit transpose.
+func TestEklundhTransposeBitMatrix(t *testing.T) {
+	shapes := [][2]int{{1, 1}, {64, 64}, {128, 128}, {128, 256}, {128, 1000}, {128, 4096}, {100, 37}, {3, 200}, {256, 65536}}
+
+	for _, shape := range shapes {
+		rows, cols := shape[0], shape[1]
+		matrix := generateBitMatrix(rows, cols)
+
+		expected := utils.TransposeBitMatrix(matrix)
+
+		for _, workers := range []int{1, 3, 8} {
+			result := utils.EklundhTransposeBitMatrix(matrix, workers)
+
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("EklundhTransposeBitMatrix failed for size %dx%d with %d workers", rows, cols, workers)
+			}
+			if result.Rows != cols || result.Cols != rows {
+				t.Errorf("EklundhTransposeBitMatrix returned size %dx%d for input size %dx%d", result.Rows, result.Cols, rows, cols)
+			}
+		}
+	}
+}
+
+func TestOTExtensionProtocolKOS(t *testing.T) {
+	k := 128
+	l := 8
+
+	// create cryptoalgorithm for algorithms.
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	for iters := 0; iters < 12; iters += 3 { // The KOS variant also works for m < k
+		m := int(math.Pow(2, float64(iters)))
+
+		selectionBits := utils.RandomSelectionBits(m)
+		var messages []*utils.MessagePair
+		for i := 0; i < m; i++ {
+			msg := utils.MessagePair{
+				Message0: utils.RandomBits(l),
+				Message1: utils.RandomBits(l),
+			}
+			messages = append(messages, &msg)
+		}
+
+		plaintext, _, err := OTExt.OTExtensionProtocolKOS(k, l, m, selectionBits, messages, elGamal, true)
+		if err != nil {
+			t.Fatalf("OTExtensionProtocolKOS failed for honest parties with m = 2^%d: %v", iters, err)
+		}
+
+		// Check if the plaintext is correct
+		for i := 0; i < m; i++ {
+			if selectionBits[i] == 0 {
+				if !bytes.Equal(plaintext[i], messages[i].Message0) {
+					t.Errorf("Plaintext is not correct")
+				}
+			} else {
+				if !bytes.Equal(plaintext[i], messages[i].Message1) {
+					t.Errorf("Plaintext is not correct")
+				}
+			}
+		}
+	}
+}
+
+// TestOTExtensionKOSDetectsInconsistentU runs the KOS protocol step by step with a malicious receiver,
+// who flips the selection bit of one OT in half of the rows of U to learn the corresponding bits of s.
+func TestOTExtensionKOSDetectsInconsistentU(t *testing.T) {
+	k := 128
+	l := 8
+	m := 1024
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("Honest step failed: %v", err)
+		}
+	}
+
+	receiver := OTExt.OTReceiver{}
+	sender := OTExt.OTSender{}
+	must(receiver.Init(selectionBits, k, l))
+	must(sender.Init(messages, k, l))
+	must(receiver.AddCheckOTs())
+	sender.AddCheckOTs()
+	must(sender.ChooseRandomS())
+	must(receiver.ChooseSeeds())
+	publicKeys, err := sender.Choose(&elGamal)
+	must(err)
+	must(receiver.ReceiveKeys(publicKeys, &elGamal))
+	seedCiphertexts, err := receiver.EncryptSeeds(&elGamal)
+	must(err)
+	must(sender.DecryptSeeds(seedCiphertexts, &elGamal))
+
+	U, err := receiver.GenerateMatrixTAndUEklundh(false)
+	must(err)
+	for i := 0; i < k/2; i++ {
+		U.SetBit(i, 7, U.Bit(i, 7)^1) // Inconsistent selection bit for OT number 7
+	}
+	must(sender.GenerateMatrixQEklundh(U, false))
+
+	commitment, err := sender.CommitChallengeSeed()
+	must(err)
+	seedReceiver, err := receiver.ChooseChallengeSeed(commitment)
+	must(err)
+	seedSender, err := sender.OpenChallengeSeed(seedReceiver)
+	must(err)
+	if err := receiver.ReceiveChallengeSeed(seedSender); err != nil {
+		t.Fatalf("Honest sender's challenge seed was rejected: %v", err)
+	}
+
+	check, err := receiver.MakeCorrelationCheck()
+	must(err)
+	err = sender.VerifyCorrelationCheck(check)
+	if err != OTExt.ErrCorrelationCheckFailed {
+		t.Errorf("Expected ErrCorrelationCheckFailed for an inconsistent U, got %v", err)
+	}
+}
+
+func TestOTExtensionProtocolCorrelated(t *testing.T) {
+	k := 128
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	for _, l := range []int{1, 8, 128} {
+		m := 1000
+		delta := utils.RandomBits(l)
+		selectionBits := utils.RandomSelectionBits(m)
+
+		pairs, plaintext, _, err := OTExt.OTExtensionProtocolCorrelated(k, l, m, selectionBits, delta, elGamal, false)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
+
+		for i := 0; i < m; i++ {
+			// Check the correlation x1_j = x0_j ⊕ Δ of the sender's pairs
+			for b := range delta {
+				if pairs[i].Message1[b] != pairs[i].Message0[b]^delta[b] {
+					t.Fatalf("Sender pair %d is not correlated by delta for l = %d", i, l)
+				}
+			}
+
+			// Check that the receiver got the message chosen by its selection bit
+			if selectionBits[i] == 0 {
+				if !bytes.Equal(plaintext[i], pairs[i].Message0) {
+					t.Errorf("Plaintext is not correct for selection bit 0 and l = %d", l)
+				}
+			} else {
+				if !bytes.Equal(plaintext[i], pairs[i].Message1) {
+					t.Errorf("Plaintext is not correct for selection bit 1 and l = %d", l)
+				}
+			}
+		}
+	}
+}
+
+func TestOTExtensionProtocolRandomAndDerandomize(t *testing.T) {
+	k := 128
+	l := 16
+	m := 1000
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	// Precompute random OTs before any inputs are known.
+	randomSender, randomReceiver, _, err := OTExt.OTExtensionProtocolRandom(k, l, m, elGamal, false)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+
+	// Check that the receiver got the random message chosen by its random choice bit
+	for i := 0; i < m; i++ {
+		if randomReceiver.ChoiceBits[i] == 0 {
+			if !bytes.Equal(randomReceiver.Messages[i], randomSender.Pairs[i].Message0) {
+				t.Errorf("Random message is not correct for choice bit 0")
+			}
+		} else {
+			if !bytes.Equal(randomReceiver.Messages[i], randomSender.Pairs[i].Message1) {
+				t.Errorf("Random message is not correct for choice bit 1")
+			}
+		}
+	}
+
+	// Derandomize the stored OTs with the real inputs.
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	plaintext, _, err := OTExt.OTDerandomizeProtocol(randomSender, randomReceiver, selectionBits, messages)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+
+	// Check if the plaintext is correct
+	for i := 0; i < m; i++ {
+		if selectionBits[i] == 0 {
+			if !bytes.Equal(plaintext[i], messages[i].Message0) {
+				t.Errorf("Plaintext is not correct")
+			}
+		} else {
+			if !bytes.Equal(plaintext[i], messages[i].Message1) {
+				t.Errorf("Plaintext is not correct")
+			}
+		}
+	}
+}
+
+func TestOTExtensionProtocolN(t *testing.T) {
+	l := 8
+	m := 300
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	for _, n := range []int{4, 8, 16, 256} {
+
+		// create message tuples and random choices in [0, N)
+		choices := make([]byte, m)
+		var tuples []*utils.MessageTuple
+		for i := 0; i < m; i++ {
+			choices[i] = byte(rand.Intn(n))
+			tuple := utils.MessageTuple{Messages: make([][]byte, n)}
+			for r := 0; r < n; r++ {
+				tuple.Messages[r] = utils.RandomBits(l)
+			}
+			tuples = append(tuples, &tuple)
+		}
+
+		plaintext, _, err := OTExt.OTExtensionProtocolN(n, l, m, choices, tuples, elGamal, false)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
+
+		// Check if the plaintext is correct
+		for i := 0; i < m; i++ {
+			if !bytes.Equal(plaintext[i], tuples[i].Messages[choices[i]]) {
+				t.Errorf("Plaintext is not correct for N = %d and choice %d", n, choices[i])
+			}
+		}
+	}
+}
+
+// TestPseudoRandomGeneratorWordsFrom tests that chunks generated from a counter offset line up with the full output.
+func TestPseudoRandomGeneratorWordsFrom(t *testing.T) {
+	seed := big.NewInt(4567).FillBytes(make([]byte, 16))
+	length := 10000
+	full, _ := utils.PseudoRandomGeneratorWords(seed, length)
+
+	for _, chunkSize := range []int{64, 128, 320, 1024} {
+		for start := 0; start < length; start += chunkSize {
+			count := chunkSize
+			if start+count > length {
+				count = length - start
+			}
+			chunk, err := utils.PseudoRandomGeneratorWordsFrom(seed, start, count)
+			if err != nil {
+				t.Fatalf("Error returned for offset %d: %v", start, err)
+			}
+
+			expected := make([]uint64, utils.WordsFor(count))
+			copy(expected, full[start/64:])
+			utils.ClearPadding(expected, count)
+			if !reflect.DeepEqual(chunk, expected) {
+				t.Errorf("Chunk at offset %d of size %d does not match the full output", start, count)
+			}
+		}
+	}
+
+	if _, err := utils.PseudoRandomGeneratorWordsFrom(seed, 10, 64); err == nil {
+		t.Errorf("Expected an error for an offset that is not a multiple of 64")
+	}
+}
+
+func TestOTExtensionProtocolStreaming(t *testing.T) {
+	k := 128
+	l := 8
+	m := 5000
+	chunkSize := 1024
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	received := 0
+	_, err := OTExt.OTExtensionProtocolStreaming(k, l, m, chunkSize,
+		func(start int, count int) []byte { return selectionBits[start : start+count] },
+		func(start int, count int) []*utils.MessagePair { return messages[start : start+count] },
+		func(start int, plaintext [][]byte) {
+			if len(plaintext) > chunkSize {
+				t.Errorf("Chunk at %d has %d results, more than the chunk size", start, len(plaintext))
+			}
+			// Check if the plaintext is correct
+			for i, p := range plaintext {
+				if selectionBits[start+i] == 0 {
+					if !bytes.Equal(p, messages[start+i].Message0) {
+						t.Errorf("Plaintext is not correct")
+					}
+				} else {
+					if !bytes.Equal(p, messages[start+i].Message1) {
+						t.Errorf("Plaintext is not correct")
+					}
+				}
+			}
+			received += len(plaintext)
+		},
+		elGamal, false)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+
+	if received != m {
+		t.Errorf("Received %d results, expected %d", received, m)
+	}
+}
+
+func TestOTExtensionSession(t *testing.T) {
+	k := 128
+	l := 8
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	// The base OTs are run once for all batches.
+	session, err := OTExt.NewOTExtensionSession(k, l, elGamal, false)
+	if err != nil {
+		t.Fatalf("NewOTExtensionSession failed: %v", err)
+	}
+
+	for batch, m := range []int{1, 100, 1000, 64} {
+		session.SetWorkers(1 + batch) // The results must not depend on the number of goroutines
+
+		selectionBits := utils.RandomSelectionBits(m)
+		var messages []*utils.MessagePair
+		for i := 0; i < m; i++ {
+			msg := utils.MessagePair{
+				Message0: utils.RandomBits(l),
+				Message1: utils.RandomBits(l),
+			}
+			messages = append(messages, &msg)
+		}
+
+		plaintext, err := session.Extend(m, selectionBits, messages)
+		if err != nil {
+			t.Fatalf("Extend failed: %v", err)
+		}
+
+		// Check if the plaintext is correct
+		for i := 0; i < m; i++ {
+			if selectionBits[i] == 0 {
+				if !bytes.Equal(plaintext[i], messages[i].Message0) {
+					t.Errorf("Plaintext is not correct in batch of size %d", m)
+				}
+			} else {
+				if !bytes.Equal(plaintext[i], messages[i].Message1) {
+					t.Errorf("Plaintext is not correct in batch of size %d", m)
+				}
+			}
+		}
+	}
+}
+
+// TestOTExtensionSessionFreshBatches tests that two successive batches of a session never reuse PRG output.
+// If a batch reused the PRG columns of the previous one, every row q_j of Q would be repeated (up to s), and the
+// random pads H(q_j), H(q_j ⊕ s) of the first batch would appear again in the second batch.
+func TestOTExtensionSessionFreshBatches(t *testing.T) {
+	k := 128
+	l := 128
+	m := 1000
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	session, err := OTExt.NewOTExtensionSession(k, l, elGamal, false)
+	if err != nil {
+		t.Fatalf("NewOTExtensionSession failed: %v", err)
+	}
+
+	offsetFirst := session.Offset()
+	firstSender, firstReceiver, err1 := session.ExtendRandom(m)
+	offsetSecond := session.Offset()
+	secondSender, secondReceiver, err2 := session.ExtendRandom(m)
+	if err1 != nil || err2 != nil {
+		t.Fatalf("ExtendRandom failed: %v %v", err1, err2)
+	}
+
+	if offsetSecond < offsetFirst+m {
+		t.Errorf("Second batch starts at PRG offset %d, inside the first batch [%d, %d)", offsetSecond, offsetFirst, offsetFirst+m)
+	}
+
+	firstPads := make(map[string]bool)
+	for _, pair := range firstSender.Pairs {
+		firstPads[string(pair.Message0)] = true
+		firstPads[string(pair.Message1)] = true
+	}
+	for i, pair := range secondSender.Pairs {
+		if firstPads[string(pair.Message0)] || firstPads[string(pair.Message1)] {
+			t.Fatalf("Random pad %d of the second batch was already used in the first batch", i)
+		}
+	}
+
+	// Both batches must still be correct random OTs
+	for _, batch := range []struct {
+		sender   *OTExt.RandomOTSender
+		receiver *OTExt.RandomOTReceiver
+	}{{firstSender, firstReceiver}, {secondSender, secondReceiver}} {
+		for i := 0; i < m; i++ {
+			expected := batch.sender.Pairs[i].Message0
+			if batch.receiver.ChoiceBits[i] == 1 {
+				expected = batch.sender.Pairs[i].Message1
+			}
+			if !bytes.Equal(batch.receiver.Messages[i], expected) {
+				t.Errorf("Random message is not correct")
+			}
+		}
+	}
+}
+
+// BenchmarkOTExtensionSessionWorkers benchmarks a batch of 2^16 OTs with an increasing number of worker goroutines.
+// The base OTs are run once in the session, so only the extension phase is measured.
+func BenchmarkOTExtensionSessionWorkers(b *testing.B) {
+	k := 128
+	l := 8
+	m := int(math.Pow(2, float64(16)))
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		b.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+	session, err := OTExt.NewOTExtensionSession(k, l, elGamal, true)
+	if err != nil {
+		b.Fatalf("NewOTExtensionSession failed: %v", err)
+	}
+
+	for workers := 1; workers <= 2*runtime.GOMAXPROCS(0); workers *= 2 {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			session.SetWorkers(workers)
+			for i := 0; i < b.N; i++ {
+				if _, err := session.Extend(m, selectionBits, messages); err != nil {
+					b.Fatalf("Extend failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestCRHash(t *testing.T) {
+	key := make([]byte, 16)
+	for i := range key {
+		key[i] = byte(i)
+	}
+	crHash, err := utils.NewCRHash(key)
+	if err != nil {
+		t.Fatalf("NewCRHash failed: %v", err)
+	}
+
+	if _, err := utils.NewCRHash(key[:8]); err == nil {
+		t.Errorf("NewCRHash accepted a key of 8 bytes")
+	}
+
+	for _, k := range []int{128, 192, 256} {
+		row := utils.RandomPackedBits(k)
+		for _, l := range []int{1, 8, 13, 128, 300} {
+			hash := crHash.Hash(42, row, l)
+
+			expectedLength := (l + 7) / 8
+			if len(hash) != expectedLength {
+				t.Errorf("Hash of length %d has %d bytes, expected %d", l, len(hash), expectedLength)
+			}
+			if l%8 != 0 && hash[len(hash)-1]>>(l%8) != 0 {
+				t.Errorf("Hash of length %d has bits set after the last bit", l)
+			}
+			if !bytes.Equal(hash, crHash.Hash(42, row, l)) {
+				t.Errorf("Hash is not deterministic for k = %d and l = %d", k, l)
+			}
+		}
+
+		// The same row at a different position must give a different pad.
+		if bytes.Equal(crHash.Hash(42, row, 128), crHash.Hash(43, row, 128)) {
+			t.Errorf("Hash does not depend on the tweak for k = %d", k)
+		}
+	}
+}
+
+func TestOTExtensionProtocolFixedKeyAES(t *testing.T) {
+	k := 128
+	l := 32
+	m := 1000
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	plaintext, _, err := OTExt.OTExtensionProtocolEklundhHash(k, l, m, selectionBits, messages, elGamal, true, OTExt.HashFixedKeyAES)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+
+	// Check if the plaintext is correct
+	for i := 0; i < m; i++ {
+		if selectionBits[i] == 0 {
+			if !bytes.Equal(plaintext[i], messages[i].Message0) {
+				t.Errorf("Plaintext is not correct")
+			}
+		} else {
+			if !bytes.Equal(plaintext[i], messages[i].Message1) {
+				t.Errorf("Plaintext is not correct")
+			}
+		}
+	}
+
+	// The tweak continues across the batches of a session.
+	session, err := OTExt.NewOTExtensionSession(k, l, elGamal, false)
+	if err != nil {
+		t.Fatalf("NewOTExtensionSession failed: %v", err)
+	}
+	if err := session.SetHashFunction(OTExt.HashFixedKeyAES); err != nil {
+		t.Fatalf("SetHashFunction failed: %v", err)
+	}
+	for _, m := range []int{10, 100} {
+		selectionBits := utils.RandomSelectionBits(m)
+		plaintext, err := session.Extend(m, selectionBits, messages[:m])
+		if err != nil {
+			t.Fatalf("Extend failed: %v", err)
+		}
+		for i := 0; i < m; i++ {
+			expected := messages[i].Message0
+			if selectionBits[i] == 1 {
+				expected = messages[i].Message1
+			}
+			if !bytes.Equal(plaintext[i], expected) {
+				t.Errorf("Plaintext is not correct in batch of size %d", m)
+			}
+		}
+	}
+}
+
+// TestSeedKeyDerivation tests that seeds keep their length through the base OTs, and that the KDF
+// gives AES keys matching the seed length for k = 128, 192 and 256.
+func TestSeedKeyDerivation(t *testing.T) {
+	for _, k := range []int{128, 192, 256} {
+		seedLength := utils.SeedLength(k)
+
+		// A seed with leading zero bytes must survive the conversion to a big int and back.
+		seed := make([]byte, seedLength)
+		seed[seedLength-1] = 42
+		restored, err := utils.SeedFromInt(utils.SeedToInt(seed), seedLength)
+		if err != nil || !bytes.Equal(seed, restored) {
+			t.Errorf("Seed is not restored for k = %d", k)
+		}
+		if _, err := utils.SeedFromInt(new(big.Int).Lsh(big.NewInt(1), uint(k)), seedLength); err == nil {
+			t.Errorf("Expected an error for a value of more than k = %d bits", k)
+		}
+
+		key, nonce := utils.DeriveAESKeyAndNonce(seed)
+		expectedKeyLength := 16
+		if k > 128 {
+			expectedKeyLength = 32
+		}
+		if len(key) != expectedKeyLength || len(nonce) != 8 {
+			t.Errorf("Derived key of %d bytes and nonce of %d bytes for k = %d", len(key), len(nonce), k)
+		}
+
+		// Seeds that only differ in the first byte must give different outputs.
+		otherSeed := append([]byte{}, seed...)
+		otherSeed[0] = 1
+		output, err := utils.PseudoRandomGeneratorWords(seed, 1000)
+		if err != nil {
+			t.Fatalf("Error returned for k = %d: %v", k, err)
+		}
+		otherOutput, _ := utils.PseudoRandomGeneratorWords(otherSeed, 1000)
+		if reflect.DeepEqual(output, otherOutput) {
+			t.Errorf("Different seeds give the same output for k = %d", k)
+		}
+	}
+
+	// Big int seeds of more than 128 bits are accepted by the PseudoRandomGenerator.
+	seed := new(big.Int).Lsh(big.NewInt(12345), 200)
+	if _, err := utils.PseudoRandomGenerator(seed, 100); err != nil {
+		t.Errorf("Error returned for a seed of more than 128 bits: %v", err)
+	}
+}
+
+// TestOTExtensionSecurityLevels runs the OTExtension and the KOS OTExtension with k = 128, 192 and 256.
+func TestOTExtensionSecurityLevels(t *testing.T) {
+	l := 16
+	m := 500
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	for _, k := range []int{128, 192, 256} {
+		selectionBits := utils.RandomSelectionBits(m)
+		var messages []*utils.MessagePair
+		for i := 0; i < m; i++ {
+			msg := utils.MessagePair{
+				Message0: utils.RandomBits(l),
+				Message1: utils.RandomBits(l),
+			}
+			messages = append(messages, &msg)
+		}
+
+		plaintext, _, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
+		plaintextKOS, _, err := OTExt.OTExtensionProtocolKOS(k, l, m, selectionBits, messages, elGamal, true)
+		if err != nil {
+			t.Fatalf("KOS OTExtension failed for k = %d: %v", k, err)
+		}
+
+		// Check if the plaintext is correct
+		for i := 0; i < m; i++ {
+			expected := messages[i].Message0
+			if selectionBits[i] == 1 {
+				expected = messages[i].Message1
+			}
+			if !bytes.Equal(plaintext[i], expected) || !bytes.Equal(plaintextKOS[i], expected) {
+				t.Errorf("Plaintext is not correct for k = %d", k)
+			}
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	k := 128
+	l := 8
+	m := 64
+
+	elGamal := elgamal.ElGamal{} // The inputs are validated before ElGamal is used, so it does not need to be initialized
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	invalidBits := append([]byte{}, selectionBits...)
+	invalidBits[5] = 2
+
+	shortMessages := append([]*utils.MessagePair{}, messages...)
+	shortMessages[3] = &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(2 * l)}
+
+	check := func(name string, err error, expected error) {
+		if !errors.Is(err, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, err)
+		}
+	}
+
+	missingMessages := append([]*utils.MessagePair{}, messages...)
+	missingMessages[3] = nil
+
+	_, _, err := OTExt.OTExtensionProtocol(k, m, invalidBits, messages, elGamal)
+	check("invalid choice bit", err, OTExt.ErrInvalidChoiceBit)
+	_, _, err = OTExt.OTExtensionProtocol(k, m+1, selectionBits, messages, elGamal)
+	check("mismatched m", err, OTExt.ErrDimensionMismatch)
+	_, _, err = OTExt.OTExtensionProtocol(100, m, selectionBits, messages, elGamal)
+	check("unsupported k", err, OTExt.ErrInvalidSecurityParameter)
+	_, _, err = OTExt.OTExtensionProtocol(k, m, selectionBits, missingMessages, elGamal)
+	check("missing message pair", err, OTExt.ErrInvalidMessageLength)
+	_, _, err = OTExt.OTExtensionProtocolTranspose(k, 0, m, selectionBits, messages, elGamal)
+	check("non-positive l", err, OTExt.ErrInvalidMessageLength)
+	_, _, err = OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, shortMessages, elGamal, false)
+	check("wrong message length", err, OTExt.ErrInvalidMessageLength)
+	_, _, err = OTExt.OTExtensionProtocolKOS(k, l, m, selectionBits[:m-1], messages, elGamal, false)
+	check("KOS mismatched selection bits", err, OTExt.ErrDimensionMismatch)
+	_, _, err = OTBasic.OTBasicProtocol(m, invalidBits, messages, elGamal)
+	check("OTBasic invalid choice bit", err, OTBasic.ErrInvalidChoiceBit)
+	_, err = OTExt.OTExtensionProtocolStreaming(k, l, m, 10, nil, nil, nil, elGamal, false)
+	check("streaming chunk size", err, OTExt.ErrInvalidParameter)
+	_, err = OTExt.NewOTExtensionSession(k, -1, elGamal, false)
+	check("session message length", err, OTExt.ErrInvalidMessageLength)
+
+	// A U matrix of the wrong size is rejected by the sender
+	sender := OTExt.OTSender{}
+	if err := sender.Init(messages, k, l); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	check("wrong size of U", sender.GenerateMatrixQEklundh(utils.NewBitMatrix(k, m-1), false), OTExt.ErrDimensionMismatch)
+}
+
+func TestWireFormatRoundTrip(t *testing.T) {
+	m := 100
+
+	var publicKeys []*utils.PublicKeyPair
+	var ciphertexts []*utils.CiphertextPair
+	var byteCiphertexts []*utils.ByteCiphertextPair
+	var hybridCiphertexts []*utils.HybridCiphertextPair
+	for j := 0; j < m; j++ {
+		publicKeys = append(publicKeys, &utils.PublicKeyPair{
+			MessageKey0: new(big.Int).SetBytes(utils.RandomBits(2048)),
+			MessageKey1: big.NewInt(int64(j)), // Includes 0, which is encoded as an empty byte string
+		})
+		ciphertexts = append(ciphertexts, &utils.CiphertextPair{
+			Ciphertext0: &elgamal.Ciphertext{C1: new(big.Int).SetBytes(utils.RandomBits(2048)), C2: big.NewInt(1)},
+			Ciphertext1: &elgamal.Ciphertext{C1: big.NewInt(0), C2: new(big.Int).SetBytes(utils.RandomBits(2048))},
+		})
+		byteCiphertexts = append(byteCiphertexts, &utils.ByteCiphertextPair{
+			Y0: utils.RandomBits(8 * (j + 1)),
+			Y1: []byte{},
+		})
+		hybridCiphertexts = append(hybridCiphertexts, &utils.HybridCiphertextPair{
+			Ciphertext0: &elgamal.HybridCiphertext{C1: new(big.Int).SetBytes(utils.RandomBits(2048)), Data: utils.RandomBits(8 * (j + 1))},
+			Ciphertext1: &elgamal.HybridCiphertext{C1: big.NewInt(int64(j)), Data: []byte{}},
+		})
+	}
+
+	data, err := utils.MarshalPublicKeyPairs(publicKeys)
+	if err != nil {
+		t.Fatalf("MarshalPublicKeyPairs failed: %v", err)
+	}
+	if len(data) != utils.PublicKeyPairsWireLength(publicKeys) {
+		t.Errorf("PublicKeyPairsWireLength is %d, expected %d", utils.PublicKeyPairsWireLength(publicKeys), len(data))
+	}
+	decodedKeys, err := utils.UnmarshalPublicKeyPairs(data)
+	if err != nil {
+		t.Fatalf("UnmarshalPublicKeyPairs failed: %v", err)
+	}
+	if !reflect.DeepEqual(decodedKeys, publicKeys) {
+		t.Errorf("Public key pairs changed in the round trip")
+	}
+
+	data, err = utils.MarshalCiphertextPairs(ciphertexts)
+	if err != nil {
+		t.Fatalf("MarshalCiphertextPairs failed: %v", err)
+	}
+	if len(data) != utils.CiphertextPairsWireLength(ciphertexts) {
+		t.Errorf("CiphertextPairsWireLength is %d, expected %d", utils.CiphertextPairsWireLength(ciphertexts), len(data))
+	}
+	decodedCiphertexts, err := utils.UnmarshalCiphertextPairs(data)
+	if err != nil {
+		t.Fatalf("UnmarshalCiphertextPairs failed: %v", err)
+	}
+	for j := range ciphertexts {
+		for _, pair := range [][2]*big.Int{
+			{decodedCiphertexts[j].Ciphertext0.C1, ciphertexts[j].Ciphertext0.C1},
+			{decodedCiphertexts[j].Ciphertext0.C2, ciphertexts[j].Ciphertext0.C2},
+			{decodedCiphertexts[j].Ciphertext1.C1, ciphertexts[j].Ciphertext1.C1},
+			{decodedCiphertexts[j].Ciphertext1.C2, ciphertexts[j].Ciphertext1.C2},
+		} {
+			if pair[0].Cmp(pair[1]) != 0 {
+				t.Fatalf("Ciphertext pair %d changed in the round trip", j)
+			}
+		}
+	}
+
+	data, err = utils.MarshalByteCiphertextPairs(byteCiphertexts)
+	if err != nil {
+		t.Fatalf("MarshalByteCiphertextPairs failed: %v", err)
+	}
+	if len(data) != utils.ByteCiphertextPairsWireLength(byteCiphertexts) {
+		t.Errorf("ByteCiphertextPairsWireLength is %d, expected %d", utils.ByteCiphertextPairsWireLength(byteCiphertexts), len(data))
+	}
+	decodedByteCiphertexts, err := utils.UnmarshalByteCiphertextPairs(data)
+	if err != nil {
+		t.Fatalf("UnmarshalByteCiphertextPairs failed: %v", err)
+	}
+	if !reflect.DeepEqual(decodedByteCiphertexts, byteCiphertexts) {
+		t.Errorf("Byte ciphertext pairs changed in the round trip")
+	}
+
+	data, err = utils.MarshalHybridCiphertextPairs(hybridCiphertexts)
+	if err != nil {
+		t.Fatalf("MarshalHybridCiphertextPairs failed: %v", err)
+	}
+	if len(data) != utils.HybridCiphertextPairsWireLength(hybridCiphertexts) {
+		t.Errorf("HybridCiphertextPairsWireLength is %d, expected %d", utils.HybridCiphertextPairsWireLength(hybridCiphertexts), len(data))
+	}
+	decodedHybridCiphertexts, err := utils.UnmarshalHybridCiphertextPairs(data)
+	if err != nil {
+		t.Fatalf("UnmarshalHybridCiphertextPairs failed: %v", err)
+	}
+	if !reflect.DeepEqual(decodedHybridCiphertexts, hybridCiphertexts) {
+		t.Errorf("Hybrid ciphertext pairs changed in the round trip")
+	}
+
+	share := utils.RandomBits(8 * utils.SessionIDLength)
+	data, err = utils.MarshalSessionShare(share)
+	if err != nil {
+		t.Fatalf("MarshalSessionShare failed: %v", err)
+	}
+	if len(data) != utils.SessionShareWireLength() {
+		t.Errorf("SessionShareWireLength is %d, expected %d", utils.SessionShareWireLength(), len(data))
+	}
+	decodedShare, err := utils.UnmarshalSessionShare(data)
+	if err != nil {
+		t.Fatalf("UnmarshalSessionShare failed: %v", err)
+	}
+	if !bytes.Equal(decodedShare, share) {
+		t.Errorf("Session share changed in the round trip")
+	}
+
+	// The messages of the KOS correlation check and of the correlated OTs
+	commitment := utils.RandomBits(256)
+	data, err = utils.MarshalChallengeCommitment(commitment)
+	if err != nil {
+		t.Fatalf("MarshalChallengeCommitment failed: %v", err)
+	}
+	if len(data) != utils.ChallengeCommitmentWireLength(commitment) {
+		t.Errorf("ChallengeCommitmentWireLength is %d, expected %d", utils.ChallengeCommitmentWireLength(commitment), len(data))
+	}
+	if decoded, err := utils.UnmarshalChallengeCommitment(data); err != nil || !bytes.Equal(decoded, commitment) {
+		t.Errorf("Challenge commitment changed in the round trip: %v", err)
+	}
+	seed := utils.RandomBits(256)
+	data, err = utils.MarshalChallengeSeed(seed)
+	if err != nil {
+		t.Fatalf("MarshalChallengeSeed failed: %v", err)
+	}
+	if len(data) != utils.ChallengeSeedWireLength(seed) {
+		t.Errorf("ChallengeSeedWireLength is %d, expected %d", utils.ChallengeSeedWireLength(seed), len(data))
+	}
+	if decoded, err := utils.UnmarshalChallengeSeed(data); err != nil || !bytes.Equal(decoded, seed) {
+		t.Errorf("Challenge seed changed in the round trip: %v", err)
+	}
+	check := &utils.CorrelationCheck{X: utils.RandomPackedBits(192), T: utils.RandomPackedBits(192)}
+	data, err = utils.MarshalCorrelationCheck(check)
+	if err != nil {
+		t.Fatalf("MarshalCorrelationCheck failed: %v", err)
+	}
+	if len(data) != utils.CorrelationCheckWireLength(check) {
+		t.Errorf("CorrelationCheckWireLength is %d, expected %d", utils.CorrelationCheckWireLength(check), len(data))
+	}
+	if decoded, err := utils.UnmarshalCorrelationCheck(data); err != nil || !reflect.DeepEqual(decoded, check) {
+		t.Errorf("Correlation check changed in the round trip: %v", err)
+	}
+	correlatedCiphertexts := [][]byte{utils.RandomBits(128), {}, utils.RandomBits(8)}
+	data, err = utils.MarshalCorrelatedCiphertexts(correlatedCiphertexts)
+	if err != nil {
+		t.Fatalf("MarshalCorrelatedCiphertexts failed: %v", err)
+	}
+	if len(data) != utils.CorrelatedCiphertextsWireLength(correlatedCiphertexts) {
+		t.Errorf("CorrelatedCiphertextsWireLength is %d, expected %d", utils.CorrelatedCiphertextsWireLength(correlatedCiphertexts), len(data))
+	}
+	if decoded, err := utils.UnmarshalCorrelatedCiphertexts(data); err != nil || !reflect.DeepEqual(decoded, correlatedCiphertexts) {
+		t.Errorf("Correlated ciphertexts changed in the round trip: %v", err)
+	}
+
+	for _, size := range [][2]int{{128, 1000}, {192, 64}, {1, 1}, {0, 0}} {
+		U := utils.NewBitMatrix(size[0], size[1])
+		for i := 0; i < U.Rows; i++ {
+			copy(U.Row(i), utils.RandomPackedBits(U.Cols))
+		}
+		data, err = utils.MarshalBitMatrix(U)
+		if err != nil {
+			t.Fatalf("MarshalBitMatrix failed: %v", err)
+		}
+		if len(data) != utils.BitMatrixWireLength(U) {
+			t.Errorf("BitMatrixWireLength is %d, expected %d", utils.BitMatrixWireLength(U), len(data))
+		}
+		decodedU, err := utils.UnmarshalBitMatrix(data)
+		if err != nil {
+			t.Fatalf("UnmarshalBitMatrix failed for %d × %d: %v", U.Rows, U.Cols, err)
+		}
+		if !reflect.DeepEqual(decodedU, U) {
+			t.Errorf("Bit matrix of %d × %d changed in the round trip", U.Rows, U.Cols)
+		}
+	}
+}
+
+func TestWireFormatMalformed(t *testing.T) {
+	byteCiphertexts := []*utils.ByteCiphertextPair{{Y0: []byte{1, 2, 3}, Y1: []byte{4, 5, 6}}}
+	valid, err := utils.MarshalByteCiphertextPairs(byteCiphertexts)
+	if err != nil {
+		t.Fatalf("MarshalByteCiphertextPairs failed: %v", err)
+	}
+
+	U := utils.NewBitMatrix(2, 70)
+	U.SetBit(1, 69, 1)
+	validU, err := utils.MarshalBitMatrix(U)
+	if err != nil {
+		t.Fatalf("MarshalBitMatrix failed: %v", err)
+	}
+
+	modified := func(data []byte, i int, value byte) []byte {
+		data = append([]byte{}, data...)
+		data[i] = value
+		return data
+	}
+
+	malformed := map[string][]byte{
+		"empty":                 {},
+		"header only":           valid[:2],
+		"unknown version":       modified(valid, 0, utils.WireVersion+1),
+		"wrong message type":    modified(valid, 1, utils.WirePublicKeyPairs),
+		"truncated":             valid[:len(valid)-1],
+		"trailing bytes":        append(append([]byte{}, valid...), 0),
+		"count too large":       modified(valid, 2, 0xff),
+		"field length too long": modified(valid, 6, 0xff),
+	}
+	for name, data := range malformed {
+		if _, err := utils.UnmarshalByteCiphertextPairs(data); !errors.Is(err, utils.ErrMalformedMessage) {
+			t.Errorf("%s: expected ErrMalformedMessage, got %v", name, err)
+		}
+	}
+
+	// The last byte of a row of 70 bits holds the bits 64 to 69 and 2 padding bits
+	malformedU := map[string][]byte{
+		"padding bit set": modified(validU, 2+8+8+7, 0x40),
+		"rows too large":  modified(validU, 2, 0x01),
+		"truncated":       validU[:len(validU)-8],
+		"wrong type":      modified(validU, 1, utils.WireByteCiphertextPairs),
+	}
+	for name, data := range malformedU {
+		if _, err := utils.UnmarshalBitMatrix(data); !errors.Is(err, utils.ErrMalformedMessage) {
+			t.Errorf("%s: expected ErrMalformedMessage, got %v", name, err)
+		}
+	}
+
+	validShare, err := utils.MarshalSessionShare(make([]byte, utils.SessionIDLength))
+	if err != nil {
+		t.Fatalf("MarshalSessionShare failed: %v", err)
+	}
+	malformedShare := map[string][]byte{
+		"too short":      validShare[:len(validShare)-1],
+		"wrong length":   modified(validShare[:len(validShare)-1], 5, utils.SessionIDLength-1),
+		"trailing bytes": append(append([]byte{}, validShare...), 0),
+		"wrong type":     modified(validShare, 1, utils.WireByteCiphertextPairs),
+	}
+	for name, data := range malformedShare {
+		if _, err := utils.UnmarshalSessionShare(data); !errors.Is(err, utils.ErrMalformedMessage) {
+			t.Errorf("%s: expected ErrMalformedMessage, got %v", name, err)
+		}
+	}
+	if _, err := utils.MarshalSessionShare(make([]byte, utils.SessionIDLength+1)); err == nil {
+		t.Errorf("Expected an error for a session share of the wrong length")
+	}
+
+	// Every integer has a single encoding: a leading zero byte is rejected
+	validKeys, err := utils.MarshalPublicKeyPairs([]*utils.PublicKeyPair{{MessageKey0: big.NewInt(1), MessageKey1: big.NewInt(2)}})
+	if err != nil {
+		t.Fatalf("MarshalPublicKeyPairs failed: %v", err)
+	}
+	leadingZero := append(append([]byte{}, validKeys[:6]...), 0, 0, 0, 2, 0, 1) // MessageKey0 = 1 as the bytes 0, 1
+	leadingZero = append(leadingZero, validKeys[11:]...)
+	if _, err := utils.UnmarshalPublicKeyPairs(leadingZero); !errors.Is(err, utils.ErrMalformedMessage) {
+		t.Errorf("Expected ErrMalformedMessage for an integer with a leading zero byte, got %v", err)
+	}
+
+	validCheck, err := utils.MarshalCorrelationCheck(&utils.CorrelationCheck{X: []uint64{1, 2}, T: []uint64{3, 4}})
+	if err != nil {
+		t.Fatalf("MarshalCorrelationCheck failed: %v", err)
+	}
+	validCorrelated, err := utils.MarshalCorrelatedCiphertexts([][]byte{{1, 2}, {3}})
+	if err != nil {
+		t.Fatalf("MarshalCorrelatedCiphertexts failed: %v", err)
+	}
+	if _, err := utils.UnmarshalCorrelationCheck(validCheck[:len(validCheck)-8]); !errors.Is(err, utils.ErrMalformedMessage) {
+		t.Errorf("Expected ErrMalformedMessage for a truncated correlation check, got %v", err)
+	}
+	if _, err := utils.UnmarshalCorrelationCheck(modified(validCheck, 5, 3)); !errors.Is(err, utils.ErrMalformedMessage) {
+		t.Errorf("Expected ErrMalformedMessage for a correlation check with too many words, got %v", err)
+	}
+	if _, err := utils.UnmarshalCorrelatedCiphertexts(validCorrelated[:len(validCorrelated)-1]); !errors.Is(err, utils.ErrMalformedMessage) {
+		t.Errorf("Expected ErrMalformedMessage for truncated correlated ciphertexts, got %v", err)
+	}
+	if _, err := utils.UnmarshalChallengeSeed(validCorrelated); !errors.Is(err, utils.ErrMalformedMessage) {
+		t.Errorf("Expected ErrMalformedMessage for a message of another type, got %v", err)
+	}
+	if _, err := utils.MarshalCorrelationCheck(&utils.CorrelationCheck{X: []uint64{1}}); err == nil {
+		t.Errorf("Expected an error for a correlation check with x and t of different lengths")
+	}
+
+	// The decoded keys are checked for group membership by the party that receives them
+	elGamal := elgamal.ElGamal{}
+	elGamal.SetGroup(elgamal.NewP256Group())
+	zeroKeys, err := utils.MarshalPublicKeyPairs([]*utils.PublicKeyPair{{MessageKey0: big.NewInt(0), MessageKey1: big.NewInt(0)}})
+	if err != nil {
+		t.Fatalf("MarshalPublicKeyPairs failed: %v", err)
+	}
+	decodedKeys, err := utils.UnmarshalPublicKeyPairs(zeroKeys)
+	if err != nil {
+		t.Fatalf("UnmarshalPublicKeyPairs failed: %v", err)
+	}
+	sender := OTBasic.OTSender{}
+	if err := sender.Init([]*utils.MessagePair{{Message0: []byte{1}, Message1: []byte{2}}}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := sender.ReceiveKeys(decodedKeys, &elGamal); !errors.Is(err, elgamal.ErrInvalidElement) {
+		t.Errorf("Expected ErrInvalidElement for decoded keys that are not group elements, got %v", err)
+	}
+
+	if _, err := utils.UnmarshalPublicKeyPairs(valid); !errors.Is(err, utils.ErrMalformedMessage) {
+		t.Errorf("Expected ErrMalformedMessage for a message of another type, got %v", err)
+	}
+	if _, err := utils.MarshalPublicKeyPairs([]*utils.PublicKeyPair{{MessageKey0: big.NewInt(-1), MessageKey1: big.NewInt(1)}}); err == nil {
+		t.Errorf("Expected an error for a negative public key")
+	}
+	if _, err := utils.MarshalCiphertextPairs([]*utils.CiphertextPair{{}}); err == nil {
+		t.Errorf("Expected an error for a missing ciphertext")
+	}
+}
+
+func TestOTExtensionProtocolOverWire(t *testing.T) {
+	k := 128
+	l := 8
+	m := 1000
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	// Every message between the parties is marshalled and unmarshalled, as if they ran in separate processes
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("Protocol step failed: %v", err)
+		}
+	}
+
+	receiver := OTExt.OTReceiver{}
+	sender := OTExt.OTSender{}
+	must(receiver.Init(selectionBits, k, l))
+	must(sender.Init(messages, k, l))
+	must(sender.ChooseRandomS())
+	must(receiver.ChooseSeeds())
+
+	publicKeys, err := sender.Choose(&elGamal)
+	must(err)
+	data, err := utils.MarshalPublicKeyPairs(publicKeys)
+	must(err)
+	publicKeys, err = utils.UnmarshalPublicKeyPairs(data)
+	must(err)
+	must(receiver.ReceiveKeys(publicKeys, &elGamal))
+
+	// The shares of the session ID are sent with the public keys and the seed ciphertexts
+	senderShare, err := sender.StartSession()
+	must(err)
+	data, err = utils.MarshalSessionShare(senderShare)
+	must(err)
+	senderShare, err = utils.UnmarshalSessionShare(data)
+	must(err)
+	receiverShare, err := receiver.JoinSession(senderShare)
+	must(err)
+	data, err = utils.MarshalSessionShare(receiverShare)
+	must(err)
+	receiverShare, err = utils.UnmarshalSessionShare(data)
+	must(err)
+
+	seedCiphertexts, err := receiver.EncryptSeeds(&elGamal)
+	must(err)
+	data, err = utils.MarshalCiphertextPairs(seedCiphertexts)
+	must(err)
+	seedCiphertexts, err = utils.UnmarshalCiphertextPairs(data)
+	must(err)
+	must(sender.CompleteSession(receiverShare))
+	must(sender.DecryptSeeds(seedCiphertexts, &elGamal))
+
+	U, err := receiver.GenerateMatrixTAndUEklundh(false)
+	must(err)
+	data, err = utils.MarshalBitMatrix(U)
+	must(err)
+	U, err = utils.UnmarshalBitMatrix(data)
+	must(err)
+	must(sender.GenerateMatrixQEklundh(U, false))
+
+	byteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	must(err)
+	data, err = utils.MarshalByteCiphertextPairs(byteCiphertexts)
+	must(err)
+	byteCiphertexts, err = utils.UnmarshalByteCiphertextPairs(data)
+	must(err)
+	plaintext, err := receiver.DecryptCiphertexts(byteCiphertexts)
+	must(err)
+
+	// Check if the plaintext is correct
+	for i := 0; i < m; i++ {
+		if selectionBits[i] == 0 {
+			if !bytes.Equal(plaintext[i], messages[i].Message0) {
+				t.Errorf("Plaintext is not correct")
+			}
+		} else {
+			if !bytes.Equal(plaintext[i], messages[i].Message1) {
+				t.Errorf("Plaintext is not correct")
+			}
+		}
+	}
+}
+
+func TestOTExtensionKOSOverWire(t *testing.T) {
+	k := 128
+	l := 8
+	m := 500
+
+	elGamal := elgamal.ElGamal{}
+	elGamal.SetGroup(elgamal.NewP256Group())
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+
+	// Every message of the KOS protocol is marshalled and unmarshalled, as if the parties ran in separate processes
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("Protocol step failed: %v", err)
+		}
+	}
+
+	receiver := OTExt.OTReceiver{}
+	sender := OTExt.OTSender{}
+	must(receiver.Init(selectionBits, k, l))
+	must(sender.Init(messages, k, l))
+	must(receiver.AddCheckOTs())
+	sender.AddCheckOTs()
+	must(sender.ChooseRandomS())
+	must(receiver.ChooseSeeds())
+
+	publicKeys, err := sender.Choose(&elGamal)
+	must(err)
+	senderShare, err := sender.StartSession()
+	must(err)
+	data, err := utils.MarshalPublicKeyPairs(publicKeys)
+	must(err)
+	publicKeys, err = utils.UnmarshalPublicKeyPairs(data)
+	must(err)
+	data, err = utils.MarshalSessionShare(senderShare)
+	must(err)
+	senderShare, err = utils.UnmarshalSessionShare(data)
+	must(err)
+	must(receiver.ReceiveKeys(publicKeys, &elGamal))
+	receiverShare, err := receiver.JoinSession(senderShare)
+	must(err)
+
+	seedCiphertexts, err := receiver.EncryptSeeds(&elGamal)
+	must(err)
+	data, err = utils.MarshalCiphertextPairs(seedCiphertexts)
+	must(err)
+	seedCiphertexts, err = utils.UnmarshalCiphertextPairs(data)
+	must(err)
+	data, err = utils.MarshalSessionShare(receiverShare)
+	must(err)
+	receiverShare, err = utils.UnmarshalSessionShare(data)
+	must(err)
+	must(sender.CompleteSession(receiverShare))
+	must(sender.DecryptSeeds(seedCiphertexts, &elGamal))
+
+	U, err := receiver.GenerateMatrixTAndUEklundh(false)
+	must(err)
+	data, err = utils.MarshalBitMatrix(U)
+	must(err)
+	U, err = utils.UnmarshalBitMatrix(data)
+	must(err)
+	must(sender.GenerateMatrixQEklundh(U, false))
+
+	commitment, err := sender.CommitChallengeSeed()
+	must(err)
+	data, err = utils.MarshalChallengeCommitment(commitment)
+	must(err)
+	commitment, err = utils.UnmarshalChallengeCommitment(data)
+	must(err)
+	seedReceiver, err := receiver.ChooseChallengeSeed(commitment)
+	must(err)
+	data, err = utils.MarshalChallengeSeed(seedReceiver)
+	must(err)
+	seedReceiver, err = utils.UnmarshalChallengeSeed(data)
+	must(err)
+	seedSender, err := sender.OpenChallengeSeed(seedReceiver)
+	must(err)
+	data, err = utils.MarshalChallengeSeed(seedSender)
+	must(err)
+	seedSender, err = utils.UnmarshalChallengeSeed(data)
+	must(err)
+	must(receiver.ReceiveChallengeSeed(seedSender))
+
+	check, err := receiver.MakeCorrelationCheck()
+	must(err)
+	data, err = utils.MarshalCorrelationCheck(check)
+	must(err)
+	check, err = utils.UnmarshalCorrelationCheck(data)
+	must(err)
+	must(sender.VerifyCorrelationCheck(check))
+
+	byteCiphertexts, err := sender.MakeAndSendCiphertexts()
+	must(err)
+	data, err = utils.MarshalByteCiphertextPairs(byteCiphertexts)
+	must(err)
+	byteCiphertexts, err = utils.UnmarshalByteCiphertextPairs(data)
+	must(err)
+	plaintext, err := receiver.DecryptCiphertexts(byteCiphertexts)
+	must(err)
+
+	for i := 0; i < m; i++ {
+		expected := messages[i].Message0
+		if selectionBits[i] == 1 {
+			expected = messages[i].Message1
+		}
+		if !bytes.Equal(plaintext[i], expected) {
+			t.Fatalf("Plaintext %d is not correct", i)
+		}
+	}
+}
+
+func TestSessionIDDerivation(t *testing.T) {
+	senderShare := bytes.Repeat([]byte{1}, utils.SessionIDLength)
+	receiverShare := bytes.Repeat([]byte{2}, utils.SessionIDLength)
+
+	sessionID, err := utils.NewSessionID(senderShare, receiverShare)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	again, _ := utils.NewSessionID(senderShare, receiverShare)
+	swapped, _ := utils.NewSessionID(receiverShare, senderShare)
+	if len(sessionID) != utils.SessionIDLength || !bytes.Equal(sessionID, again) || bytes.Equal(sessionID, swapped) {
+		t.Errorf("Session ID must be deterministic, %d bytes, and depend on the order of the shares", utils.SessionIDLength)
+	}
+	if _, err := utils.NewSessionID(senderShare[:8], receiverShare); !errors.Is(err, utils.ErrInvalidMessageLength) {
+		t.Errorf("expected ErrInvalidMessageLength for a short share, got %v", err)
+	}
+
+	// The mask is an involution, and the PRG seeds differ between sessions, base OTs and bits.
+	seed := bytes.Repeat([]byte{7}, utils.SeedLength(128))
+	masked := utils.SessionMaskSeed(sessionID, 3, 1, seed)
+	if bytes.Equal(masked, seed) || !bytes.Equal(utils.SessionMaskSeed(sessionID, 3, 1, masked), seed) {
+		t.Errorf("Masking the seed twice must restore it")
+	}
+	prgSeed := utils.SessionPRGSeed(sessionID, 3, 1, seed)
+	for _, other := range [][]byte{
+		utils.SessionPRGSeed(swapped, 3, 1, seed),
+		utils.SessionPRGSeed(sessionID, 4, 1, seed),
+		utils.SessionPRGSeed(sessionID, 3, 0, seed),
+	} {
+		if len(other) != len(seed) || bytes.Equal(other, prgSeed) {
+			t.Errorf("PRG seeds of different sessions, base OTs or bits must differ")
+		}
+	}
+}
+
+func TestOTExtensionSessionReplay(t *testing.T) {
+	k := 128
+	l := 64
+	m := 256
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+
+	must := func(err error) {
+		if err != nil {
+			t.Fatalf("Protocol step failed: %v", err)
+		}
+	}
+
+	// run runs the protocol, where the sender completes the session with the given receiver's share instead of
+	// the share of its receiver if it is not nil. It returns the number of correct plaintexts and the ciphertexts.
+	run := func(replayedShare []byte) (int, []*utils.ByteCiphertextPair) {
+		receiver := OTExt.OTReceiver{}
+		sender := OTExt.OTSender{}
+		must(receiver.Init(selectionBits, k, l))
+		must(sender.Init(messages, k, l))
+		must(sender.ChooseRandomS())
+		must(receiver.ChooseSeeds())
+		publicKeys, err := sender.Choose(&elGamal)
+		must(err)
+		must(receiver.ReceiveKeys(publicKeys, &elGamal))
+		senderShare, err := sender.StartSession()
+		must(err)
+		receiverShare, err := receiver.JoinSession(senderShare)
+		must(err)
+		seedCiphertexts, err := receiver.EncryptSeeds(&elGamal)
+		must(err)
+		if replayedShare != nil {
+			receiverShare = replayedShare
+		}
+		must(sender.CompleteSession(receiverShare))
+		if err := sender.DecryptSeeds(seedCiphertexts, &elGamal); err != nil {
+			return 0, nil // The replay was detected
+		}
+		if replayedShare == nil && !bytes.Equal(sender.SessionID(), receiver.SessionID()) {
+			t.Errorf("The parties must agree on the session ID")
+		}
+
+		U, err := receiver.GenerateMatrixTAndUEklundh(false)
+		must(err)
+		must(sender.GenerateMatrixQEklundh(U, false))
+		ciphertexts, err := sender.MakeAndSendCiphertexts()
+		must(err)
+		plaintext, err := receiver.DecryptCiphertexts(ciphertexts)
+		must(err)
+
+		correct := 0
+		for i := 0; i < m; i++ {
+			expected := messages[i].Message0
+			if selectionBits[i] == 1 {
+				expected = messages[i].Message1
+			}
+			if bytes.Equal(plaintext[i], expected) {
+				correct++
+			}
+		}
+		return correct, ciphertexts
+	}
+
+	correct, ciphertexts := run(nil)
+	if correct != m {
+		t.Fatalf("Plaintext is not correct")
+	}
+
+	// A receiver's share replayed from another session gives the sender other seeds, so every pad is garbage.
+	if correct, _ := run(bytes.Repeat([]byte{9}, utils.SessionIDLength)); correct != 0 {
+		t.Errorf("%d plaintexts were correct after replaying a session share", correct)
+	}
+
+	// Ciphertexts replayed into another session do not decrypt, and the padding of variable-length messages is
+	// rejected.
+	receiver := OTExt.OTReceiver{}
+	sender := OTExt.OTSender{}
+	must(receiver.InitVariableLengths(selectionBits, k))
+	must(sender.InitVariableLengths(messages, k))
+	must(sender.ChooseRandomS())
+	must(receiver.ChooseSeeds())
+	publicKeys, err := sender.Choose(&elGamal)
+	must(err)
+	must(receiver.ReceiveKeys(publicKeys, &elGamal))
+	senderShare, err := sender.StartSession()
+	must(err)
+	receiverShare, err := receiver.JoinSession(senderShare)
+	must(err)
+	seedCiphertexts, err := receiver.EncryptSeeds(&elGamal)
+	must(err)
+	must(sender.CompleteSession(receiverShare))
+	must(sender.DecryptSeeds(seedCiphertexts, &elGamal))
+	U, err := receiver.GenerateMatrixTAndUEklundh(false)
+	must(err)
+	must(sender.GenerateMatrixQEklundh(U, false))
+	if _, err := receiver.DecryptCiphertexts(ciphertexts); !errors.Is(err, OTExt.ErrInvalidMessageLength) {
+		t.Errorf("expected ErrInvalidMessageLength for replayed ciphertexts, got %v", err)
+	}
+}
+
+// idealBaseOT is a BaseOT that hands the chosen seeds to the receiver directly, standing in for any other base OT.
+type idealBaseOT struct {
+	transfers int
+}
+
+func (baseOT *idealBaseOT) Transfer(sessionID []byte, choiceBits []byte, seeds []*utils.Seed, communication *utils.Communication) ([][]byte, error) {
+	if len(choiceBits) != len(seeds) {
+		return nil, fmt.Errorf("%w: %d choice bits for %d seed pairs", utils.ErrDimensionMismatch, len(choiceBits), len(seeds))
+	}
+	received := make([][]byte, len(seeds))
+	for i, seed := range seeds {
+		received[i] = seed.Seed0
+		if choiceBits[i] == 1 {
+			received[i] = seed.Seed1
+		}
+	}
+	communication.Record(utils.PhaseSeedTransfer, utils.ReceiverToSender, 2*len(seeds)*len(seeds[0].Seed0))
+	baseOT.transfers++
+	return received, nil
+}
+
+func TestOTExtensionProtocolPluggableBaseOT(t *testing.T) {
+	k := 128
+	l := 8
+	m := 512
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+
+	baseOT := &idealBaseOT{}
+	plaintext, communication, err := OTExt.OTExtensionProtocolBaseOT(k, l, m, selectionBits, messages, baseOT, true)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+	if baseOT.transfers != 1 {
+		t.Errorf("expected one transfer of the base OTs, got %d", baseOT.transfers)
+	}
+	for i := 0; i < m; i++ {
+		expected := messages[i].Message0
+		if selectionBits[i] == 1 {
+			expected = messages[i].Message1
+		}
+		if !bytes.Equal(plaintext[i], expected) {
+			t.Errorf("Plaintext is not correct")
+		}
+	}
+
+	// The shares of the session ID are sent with the base OTs without adding rounds.
+	seedTransfer := communication.Phases[utils.PhaseSeedTransfer]
+	if seedTransfer.ReceiverToSender != 2*k*utils.SeedLength(k)+utils.SessionShareWireLength() || seedTransfer.Rounds != 1 {
+		t.Errorf("unexpected communication of the seed transfer: %+v", seedTransfer)
+	}
+
+	// The built-in base OTs transfer the chosen seeds with a session ID, and reject their inputs before using the group.
+	choiceBits := utils.RandomSelectionBits(k)
+	seeds := make([]*utils.Seed, k)
+	for i := range seeds {
+		seeds[i] = &utils.Seed{Seed0: utils.RandomBits(k), Seed1: utils.RandomBits(k)}
+	}
+	sessionID := bytes.Repeat([]byte{7}, utils.SessionIDLength)
+	elGamal := elgamal.ElGamal{}
+	elGamal.SetGroup(elgamal.NewP256Group())
+	for _, builtIn := range []OTExt.BaseOT{OTExt.NewElGamalBaseOT(elGamal), OTExt.NewOTBasicBaseOT(elGamal)} {
+		received, err := builtIn.Transfer(sessionID, choiceBits, seeds, &utils.Communication{})
+		if err != nil {
+			t.Fatalf("Transfer failed: %v", err)
+		}
+		for i, seed := range seeds {
+			expected := seed.Seed0
+			if choiceBits[i] == 1 {
+				expected = seed.Seed1
+			}
+			if !bytes.Equal(received[i], expected) {
+				t.Fatalf("Seed %d is not correct", i)
+			}
+		}
+	}
+	for _, builtIn := range []OTExt.BaseOT{OTExt.NewElGamalBaseOT(elgamal.ElGamal{}), OTExt.NewOTBasicBaseOT(elgamal.ElGamal{})} {
+		if _, err := builtIn.Transfer(nil, []byte{0, 1}, []*utils.Seed{{Seed0: []byte{1}, Seed1: []byte{2}}}, &utils.Communication{}); !errors.Is(err, utils.ErrDimensionMismatch) {
+			t.Errorf("expected ErrDimensionMismatch, got %v", err)
+		}
+		if _, err := builtIn.Transfer(nil, []byte{2}, []*utils.Seed{{Seed0: []byte{1}, Seed1: []byte{2}}}, &utils.Communication{}); !errors.Is(err, utils.ErrInvalidChoiceBit) {
+			t.Errorf("expected ErrInvalidChoiceBit, got %v", err)
+		}
+	}
+}
+
+func TestOTExtensionProtocolBaseOTs(t *testing.T) {
+	k := 128
+	l := 8
+	m := 512
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+
+	for name, baseOT := range map[string]OTExt.BaseOT{
+		"ElGamal": OTExt.NewElGamalBaseOT(elGamal),
+		"OTBasic": OTExt.NewOTBasicBaseOT(elGamal),
+	} {
+		plaintext, communication, err := OTExt.OTExtensionProtocolBaseOT(k, l, m, selectionBits, messages, baseOT, false)
+		if err != nil {
+			t.Fatalf("%s: protocol failed: %v", name, err)
+		}
+		for i := 0; i < m; i++ {
+			expected := messages[i].Message0
+			if selectionBits[i] == 1 {
+				expected = messages[i].Message1
+			}
+			if !bytes.Equal(plaintext[i], expected) {
+				t.Errorf("%s: Plaintext is not correct", name)
+			}
+		}
+
+		baseOTPhase := communication.Phases[utils.PhaseBaseOT]
+		seedTransfer := communication.Phases[utils.PhaseSeedTransfer]
+		if baseOTPhase.SenderToReceiver == 0 || baseOTPhase.ReceiverToSender != 0 || baseOTPhase.Rounds != 1 ||
+			seedTransfer.SenderToReceiver != 0 || seedTransfer.ReceiverToSender == 0 || seedTransfer.Rounds != 1 {
+			t.Errorf("%s: unexpected communication of the base OTs: %+v, %+v", name, baseOTPhase, seedTransfer)
+		}
+	}
+}
+
+// BenchmarkEklundhTransposeSquare transposes large square matrices, which are split into many bounded tiles.
+func BenchmarkEklundhTransposeSquare(b *testing.B) {
+	for _, size := range []int{1000, 4096} {
+		matrix := generateNonSymmetricMatrix(size, size)
+		for _, multithreaded := range []bool{false, true} {
+			b.Run(fmt.Sprintf("%dx%d/multithreaded=%v", size, size, multithreaded), func(b *testing.B) {
+				for i := 0; i < b.N; i++ {
+					utils.EklundhTranspose(matrix, multithreaded)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkBaseOT compares the base OTs of the extension on k = 128 seed pairs.
+func BenchmarkBaseOT(b *testing.B) {
+	k := 128
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		b.Fatalf("InitStandard failed: %v", err)
+	}
+	p256 := elgamal.ElGamal{}
+	p256.SetGroup(elgamal.NewP256Group())
+	sequential := func(elGamal elgamal.ElGamal) elgamal.ElGamal {
+		elGamal.SetWorkers(1)
+		return elGamal
+	}
+
+	choiceBits := utils.RandomSelectionBits(k)
+	seeds := make([]*utils.Seed, k)
+	for i := range seeds {
+		seeds[i] = &utils.Seed{Seed0: utils.RandomBits(k), Seed1: utils.RandomBits(k)}
+	}
+
+	for _, baseOT := range []struct {
+		name   string
+		baseOT OTExt.BaseOT
+	}{
+		{"ElGamal", OTExt.NewElGamalBaseOT(elGamal)},
+		{"ElGamal/1 worker", OTExt.NewElGamalBaseOT(sequential(elGamal))},
+		{"OTBasic", OTExt.NewOTBasicBaseOT(elGamal)},
+		{"ElGamal P-256", OTExt.NewElGamalBaseOT(p256)},
+		{"ElGamal P-256/1 worker", OTExt.NewElGamalBaseOT(sequential(p256))},
+	} {
+		b.Run(baseOT.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := baseOT.baseOT.Transfer(nil, choiceBits, seeds, &utils.Communication{}); err != nil {
+					b.Fatalf("Transfer failed: %v", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkFixedBaseExp compares g^e with and without the fixed-base table of g in a 2048-bit group.
+func BenchmarkFixedBaseExp(b *testing.B) {
+	group, err := elgamal.StandardGroup(elgamal.FFDHE2048)
+	if err != nil {
+		b.Fatalf("StandardGroup failed: %v", err)
+	}
+	exponent := new(big.Int).SetBytes(utils.RandomBits(2048))
+	group.ExpBase(exponent) // Build the table
+
+	b.Run("Exp", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			group.Exp(group.Generator(), exponent)
+		}
+	})
+	b.Run("ExpBase", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			group.ExpBase(exponent)
+		}
+	})
+}
+
+func TestGroups(t *testing.T) {
+	// A small safe-prime group, since the 2048-bit group of Init takes long to generate
+	var p, q *big.Int
+	for {
+		q = new(big.Int).SetBytes(utils.RandomBits(256))
+		q.SetBit(q, 255, 1)
+		if !q.ProbablyPrime(20) {
+			continue
+		}
+		p = new(big.Int).Add(new(big.Int).Lsh(q, 1), big.NewInt(1))
+		if p.ProbablyPrime(20) {
+			break
+		}
+	}
+	modP := elgamal.NewModPGroup(p, q, big.NewInt(4)) // 4 = 2^2 is a quadratic residue, so it generates the group of order q
+
+	for _, group := range []elgamal.Group{modP, elgamal.NewP256Group()} {
+		g := group.Generator()
+		a := new(big.Int).SetBytes(utils.RandomBits(256))
+		b := new(big.Int).SetBytes(utils.RandomBits(256))
+
+		// g^a · g^b = g^(a+b), g^a · g^-a = 1 and g^q = 1
+		identity := group.Exp(g, group.Order())
+		if group.Mul(group.Exp(g, a), group.Exp(g, b)).Cmp(group.Exp(g, new(big.Int).Add(a, b))) != 0 {
+			t.Errorf("%s: g^a · g^b is not g^(a+b)", group.Name())
+		}
+		if group.Mul(group.Exp(g, a), group.Inverse(group.Exp(g, a))).Cmp(identity) != 0 {
+			t.Errorf("%s: g^a · (g^a)^-1 is not the identity", group.Name())
+		}
+		if group.Exp(group.Exp(g, a), b).Cmp(group.Exp(group.Exp(g, b), a)) != 0 {
+			t.Errorf("%s: (g^a)^b is not (g^b)^a", group.Name())
+		}
+
+		for i := 0; i < 10; i++ {
+			element, err := group.RandomElement(crand.Reader)
+			if err != nil {
+				t.Fatalf("%s: RandomElement failed: %v", group.Name(), err)
+			}
+			if !group.Contains(element) {
+				t.Errorf("%s: random element is not in the group", group.Name())
+			}
+			decoded, err := group.UnmarshalElement(group.MarshalElement(element))
+			if err != nil || decoded.Cmp(element) != 0 {
+				t.Errorf("%s: element does not round trip: %v", group.Name(), err)
+			}
+		}
+
+		elGamal := elgamal.ElGamal{}
+		elGamal.SetGroup(group)
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", group.Name(), err)
+		}
+		otherSK, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", group.Name(), err)
+		}
+		pk := elGamal.Gen(sk)
+		for _, bits := range []int{1, 128, group.MessageBits()} {
+			m, err := crand.Int(crand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(bits)))
+			if err != nil {
+				t.Fatalf("error reading randomness: %v", err)
+			}
+			ciphertext, err := elGamal.Encrypt(m, pk)
+			if err != nil {
+				t.Fatalf("%s: Encrypt failed: %v", group.Name(), err)
+			}
+			if elGamal.Decrypt(ciphertext.C1, ciphertext.C2, sk).Cmp(m) != 0 {
+				t.Errorf("%s: Plaintext is not correct", group.Name())
+			}
+			if elGamal.Decrypt(ciphertext.C1, ciphertext.C2, otherSK).Cmp(m) == 0 {
+				t.Errorf("%s: decryption with another key recovers the plaintext", group.Name())
+			}
+		}
+	}
+
+	// Values outside the groups are rejected
+	for _, value := range []*big.Int{big.NewInt(0), p, new(big.Int).Sub(p, big.NewInt(1))} { // p - 1 = -1 has order 2
+		if _, err := modP.UnmarshalElement(modP.MarshalElement(value)); err == nil {
+			t.Errorf("%s: %v was accepted", modP.Name(), value)
+		}
+	}
+	p256 := elgamal.NewP256Group()
+	invalid := p256.MarshalElement(p256.Generator())
+	invalid[0] = 4
+	if _, err := p256.UnmarshalElement(invalid); err == nil {
+		t.Errorf("P-256: invalid point was accepted")
+	}
+}
+
+// TestOTProtocolsP256 runs OTBasic and the base phase of the extension unchanged in the P-256 group.
+func TestOTProtocolsP256(t *testing.T) {
+	k := 128
+	l := 8
+	m := 256
+
+	elGamal := elgamal.ElGamal{}
+	elGamal.SetGroup(elgamal.NewP256Group())
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+	check := func(name string, plaintext [][]byte, err error) {
+		if err != nil {
+			t.Fatalf("%s: protocol failed: %v", name, err)
+		}
+		for i := 0; i < m; i++ {
+			expected := messages[i].Message0
+			if selectionBits[i] == 1 {
+				expected = messages[i].Message1
+			}
+			if !bytes.Equal(plaintext[i], expected) {
+				t.Errorf("%s: Plaintext is not correct", name)
+			}
+		}
+	}
+
+	plaintext, _, err := OTBasic.OTBasicProtocol(m, selectionBits, messages, elGamal)
+	check("OTBasic", plaintext, err)
+	plaintext, _, err = OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, true)
+	check("Eklundh", plaintext, err)
+	plaintext, _, err = OTExt.OTExtensionProtocolBaseOT(k, l, m, selectionBits, messages, OTExt.NewOTBasicBaseOT(elGamal), false)
+	check("OTBasic base OT", plaintext, err)
+	plaintext, _, err = OTExt.OTExtensionProtocol(256, m, selectionBits, messages, elGamal)
+	check("k = 256", plaintext, err)
+}
+
+// TestInvalidElements checks that public keys and ciphertexts received from the other party are rejected if they are
+// not elements of the group or are the identity, which would make pk^r known to everyone and reveal the messages.
+func TestInvalidElements(t *testing.T) {
+	k := 128
+	l := 8
+	for _, name := range []string{elgamal.FFDHE2048, "P-256"} {
+		elGamal := elgamal.ElGamal{}
+		if name == "P-256" {
+			elGamal.SetGroup(elgamal.NewP256Group())
+		} else if err := elGamal.InitStandard(name); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
+		group := elGamal.Group()
+
+		invalid := map[string]*big.Int{
+			"zero":     big.NewInt(0),
+			"identity": group.Identity(),
+			"garbage":  new(big.Int).Lsh(big.NewInt(1), 3000),
+			"negative": big.NewInt(-4),
+		}
+		if modP, ok := group.(*elgamal.ModPGroup); ok {
+			p := modP.Modulus()
+			invalid["p - 1"] = new(big.Int).Sub(p, big.NewInt(1))       // -1 has order 2
+			invalid["non-residue"] = new(big.Int).Sub(p, big.NewInt(2)) // -2 is a non-residue, since p = 7 mod 8
+			invalid["p"] = new(big.Int).Set(p)
+		} else {
+			// A compressed encoding whose x-coordinate is not on the curve
+			for x := int64(1); ; x++ {
+				offCurve := new(big.Int).SetBytes(append([]byte{2}, big.NewInt(x).FillBytes(make([]byte, 32))...))
+				if !group.Contains(offCurve) {
+					invalid["off-curve"] = offCurve
+					break
+				}
+			}
+		}
+
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", name, err)
+		}
+		valid := elGamal.Gen(sk)
+		if err := elGamal.ValidateElement(valid); err != nil {
+			t.Errorf("%s: a public key was rejected: %v", name, err)
+		}
+
+		for description, element := range invalid {
+			if err := elGamal.ValidateElement(element); !errors.Is(err, elgamal.ErrInvalidElement) {
+				t.Errorf("%s: expected ErrInvalidElement for the %s element, got %v", name, description, err)
+			}
+
+			// OTBasic sender
+			sender := OTBasic.OTSender{}
+			if err := sender.Init([]*utils.MessagePair{{Message0: []byte{0}, Message1: []byte{1}}}); err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			keys := []*utils.PublicKeyPair{{MessageKey0: valid, MessageKey1: element}}
+			if err := sender.ReceiveKeys(keys, &elGamal); !errors.Is(err, OTBasic.ErrInvalidElement) {
+				t.Errorf("%s: expected ErrInvalidElement for the %s key in OTBasic, got %v", name, description, err)
+			}
+
+			// OTBasic receiver
+			receiver := OTBasic.OTReceiver{}
+			if err := receiver.Init([]byte{1}); err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			if _, err := receiver.Choose(1, &elGamal); err != nil {
+				t.Fatalf("Choose failed: %v", err)
+			}
+			ciphertext := &elgamal.HybridCiphertext{C1: element, Data: make([]byte, 17)}
+			_, err := receiver.DecryptMessage([]*utils.HybridCiphertextPair{{Ciphertext0: ciphertext, Ciphertext1: ciphertext}}, &elGamal)
+			if !errors.Is(err, OTBasic.ErrInvalidElement) || !errors.Is(err, OTBasic.ErrDecryptionFailed) {
+				t.Errorf("%s: expected ErrInvalidElement for the %s c1 in OTBasic, got %v", name, description, err)
+			}
+
+			// Receiver of the OT extension, which plays the sender of the base OTs
+			extensionReceiver := OTExt.OTReceiver{}
+			if err := extensionReceiver.Init(utils.RandomSelectionBits(k), k, l); err != nil {
+				t.Fatalf("Init failed: %v", err)
+			}
+			keys = make([]*utils.PublicKeyPair, k)
+			for i := range keys {
+				keys[i] = &utils.PublicKeyPair{MessageKey0: valid, MessageKey1: valid}
+			}
+			keys[k-1] = &utils.PublicKeyPair{MessageKey0: element, MessageKey1: valid}
+			if err := extensionReceiver.ReceiveKeys(keys, &elGamal); !errors.Is(err, OTExt.ErrInvalidElement) {
+				t.Errorf("%s: expected ErrInvalidElement for the %s key in the OT extension, got %v", name, description, err)
+			}
+		}
+	}
+}
+
+func TestStandardGroups(t *testing.T) {
+	for _, name := range elgamal.StandardGroupNames() {
+		group, err := elgamal.StandardGroup(name)
+		if err != nil {
+			t.Fatalf("StandardGroup(%s) failed: %v", name, err)
+		}
+		if group.Name() != name {
+			t.Errorf("expected the name %s, got %s", name, group.Name())
+		}
+		if err := group.Validate(); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := elgamal.StandardGroup("modp1024"); !errors.Is(err, elgamal.ErrInvalidGroup) {
+		t.Errorf("expected ErrInvalidGroup for an unknown group, got %v", err)
+	}
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.Validate(); !errors.Is(err, elgamal.ErrInvalidGroup) {
+		t.Errorf("expected ErrInvalidGroup without a group, got %v", err)
+	}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+	sk, err := elGamal.MakeSecretKey()
+	if err != nil {
+		t.Fatalf("MakeSecretKey failed: %v", err)
+	}
+	m, err := crand.Int(crand.Reader, new(big.Int).Lsh(big.NewInt(1), uint(elGamal.Group().MessageBits())))
+	if err != nil {
+		t.Fatalf("error reading randomness: %v", err)
+	}
+	ciphertext, err := elGamal.Encrypt(m, elGamal.Gen(sk))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if elGamal.Decrypt(ciphertext.C1, ciphertext.C2, sk).Cmp(m) != 0 {
+		t.Errorf("Plaintext is not correct")
+	}
+}
+
+func TestGroupParameterFiles(t *testing.T) {
+	standard, err := elgamal.StandardGroup(elgamal.FFDHE2048)
+	if err != nil {
+		t.Fatalf("StandardGroup failed: %v", err)
+	}
+
+	for _, file := range []string{"group.pem", "group.json"} {
+		path := filepath.Join(t.TempDir(), file)
+		if err := elgamal.SaveModPGroup(path, standard); err != nil {
+			t.Fatalf("SaveModPGroup failed: %v", err)
+		}
+		loaded, err := elgamal.LoadModPGroup(path)
+		if err != nil {
+			t.Fatalf("LoadModPGroup failed: %v", err)
+		}
+		if loaded.Modulus().Cmp(standard.Modulus()) != 0 || loaded.Order().Cmp(standard.Order()) != 0 ||
+			loaded.Generator().Cmp(standard.Generator()) != 0 {
+			t.Errorf("%s: parameters do not round trip", file)
+		}
+		if loaded.Name() != elgamal.FFDHE2048 {
+			t.Errorf("%s: the standard group is not recognized, got the name %s", file, loaded.Name())
+		}
+	}
+
+	// Invalid parameters are rejected when loaded
+	p := standard.Modulus()
+	q := standard.Order()
+	pMinusOne := new(big.Int).Sub(p, big.NewInt(1))
+	for name, group := range map[string]*elgamal.ModPGroup{
+		"composite p":  elgamal.NewModPGroup(new(big.Int).Add(p, big.NewInt(2)), q, big.NewInt(2)),
+		"composite q":  elgamal.NewModPGroup(p, pMinusOne, big.NewInt(2)),
+		"q ∤ p-1":      elgamal.NewModPGroup(p, big.NewInt(65537), big.NewInt(2)),
+		"p ≠ 2q + 1":   elgamal.NewModPGroup(big.NewInt(29), big.NewInt(7), big.NewInt(16)), // 16 has order 7 mod 29
+		"g = 1":        elgamal.NewModPGroup(p, q, big.NewInt(1)),
+		"g of order 2": elgamal.NewModPGroup(p, q, pMinusOne),
+		"g not in G":   elgamal.NewModPGroup(p, q, new(big.Int).Sub(p, big.NewInt(2))), // -2 is not a quadratic residue, since p = 7 mod 8
+	} {
+		path := filepath.Join(t.TempDir(), "group.pem")
+		if err := elgamal.SaveModPGroup(path, group); err != nil {
+			t.Fatalf("SaveModPGroup failed: %v", err)
+		}
+		if _, err := elgamal.LoadModPGroup(path); !errors.Is(err, elgamal.ErrInvalidGroup) {
+			t.Errorf("%s: expected ErrInvalidGroup, got %v", name, err)
+		}
+	}
+
+	// Malformed files are rejected
+	for name, data := range map[string]string{
+		"not JSON":       "p = 23",
+		"not a number":   `{"p": "xyz", "q": "b", "g": "4"}`,
+		"missing g":      `{"p": "17", "q": "b"}`,
+		"wrong PEM type": "-----BEGIN DH PARAMETERS-----\nMAYCAQUCAQI=\n-----END DH PARAMETERS-----\n",
+	} {
+		path := filepath.Join(t.TempDir(), "group")
+		if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if _, err := elgamal.LoadModPGroup(path); !errors.Is(err, elgamal.ErrInvalidGroup) {
+			t.Errorf("%s: expected ErrInvalidGroup, got %v", name, err)
+		}
+	}
+}
+
+func TestFixedBaseExp(t *testing.T) {
+	standard, err := elgamal.StandardGroup(elgamal.FFDHE2048)
+	if err != nil {
+		t.Fatalf("StandardGroup failed: %v", err)
+	}
+	for _, group := range []elgamal.Group{standard, elgamal.NewP256Group()} {
+		q := group.Order()
+		exponents := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(31), big.NewInt(32), new(big.Int).Sub(q, big.NewInt(1)), q, big.NewInt(-5)}
+		exponents = append(exponents, new(big.Int).SetBit(big.NewInt(1), 200, 1)) // Zero digits between two non-zero ones
+		for i := 0; i < 20; i++ {
+			exponents = append(exponents, new(big.Int).SetBytes(utils.RandomBits(q.BitLen())))
+		}
+		for _, exponent := range exponents {
+			if group.ExpBase(exponent).Cmp(group.Exp(group.Generator(), exponent)) != 0 {
+				t.Errorf("%s: ExpBase(%v) is not g^%v", group.Name(), exponent, exponent)
+			}
+		}
+	}
+}
+
+func TestBatchOperations(t *testing.T) {
+	n := 50
+	seeded := func(seed string) io.Reader {
+		random, err := utils.NewDeterministicReader([]byte(seed))
+		if err != nil {
+			t.Fatalf("NewDeterministicReader failed: %v", err)
+		}
+		return random
+	}
+	for _, name := range []string{elgamal.FFDHE2048, "P-256"} {
+		elGamal := elgamal.ElGamal{}
+		if name == "P-256" {
+			elGamal.SetGroup(elgamal.NewP256Group())
+		} else if err := elGamal.InitStandard(name); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
+
+		secretKeys := make([]*big.Int, n)
+		messages := make([]*big.Int, n)
+		for i := range secretKeys {
+			sk, err := elGamal.MakeSecretKey()
+			if err != nil {
+				t.Fatalf("%s: MakeSecretKey failed: %v", name, err)
+			}
+			secretKeys[i] = sk
+			messages[i] = new(big.Int).SetBytes(utils.RandomBits(128))
+		}
+
+		for _, workers := range []int{1, 4} {
+			elGamal.SetWorkers(workers)
+			publicKeys := elGamal.GenBatch(secretKeys)
+			for i := range publicKeys {
+				if publicKeys[i].Cmp(elGamal.Gen(secretKeys[i])) != 0 {
+					t.Errorf("%s, %d workers: public key %d is not correct", name, workers, i)
+				}
+			}
+			ciphertexts, err := elGamal.EncryptBatch(messages, publicKeys)
+			if err != nil {
+				t.Fatalf("%s: EncryptBatch failed: %v", name, err)
+			}
+			plaintexts := elGamal.DecryptBatch(ciphertexts, secretKeys)
+			for i := range plaintexts {
+				if plaintexts[i].Cmp(messages[i]) != 0 {
+					t.Errorf("%s, %d workers: Plaintext is not correct", name, workers)
+				}
+			}
+
+			// A batch draws the same randomness as the encryptions one by one
+			batch, err := elGamal.WithRandom(seeded("batch")).EncryptBatch(messages, publicKeys)
+			if err != nil {
+				t.Fatalf("%s: EncryptBatch failed: %v", name, err)
+			}
+			single := elGamal.WithRandom(seeded("batch"))
+			for i := range messages {
+				ciphertext, err := single.Encrypt(messages[i], publicKeys[i])
+				if err != nil {
+					t.Fatalf("%s: Encrypt failed: %v", name, err)
+				}
+				if ciphertext.C1.Cmp(batch[i].C1) != 0 || ciphertext.C2.Cmp(batch[i].C2) != 0 {
+					t.Errorf("%s, %d workers: ciphertext %d differs from Encrypt", name, workers, i)
+				}
+			}
+		}
+	}
+}
+
+func TestHybridEncryption(t *testing.T) {
+	for _, name := range []string{elgamal.FFDHE2048, "P-256"} {
+		elGamal := elgamal.ElGamal{}
+		if name == "P-256" {
+			elGamal.SetGroup(elgamal.NewP256Group())
+		} else if err := elGamal.InitStandard(name); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", name, err)
+		}
+		otherSK, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", name, err)
+		}
+		pk := elGamal.Gen(sk)
+
+		// Empty messages, leading and trailing zero bytes, and messages longer than q round trip exactly
+		messages := [][]byte{{}, {0}, {0, 0, 1}, {1, 0, 0}, utils.RandomBits(8 * 1000)}
+		for _, message := range messages {
+			ciphertext, err := elGamal.EncryptHybrid(message, pk, nil)
+			if err != nil {
+				t.Fatalf("%s: EncryptHybrid failed: %v", name, err)
+			}
+			plaintext, err := elGamal.DecryptHybrid(ciphertext, sk, nil)
+			if err != nil {
+				t.Fatalf("%s: DecryptHybrid failed: %v", name, err)
+			}
+			if !bytes.Equal(plaintext, message) || plaintext == nil {
+				t.Errorf("%s: Plaintext is not correct", name)
+			}
+
+			// Another key and modified ciphertexts are rejected
+			if _, err := elGamal.DecryptHybrid(ciphertext, otherSK, nil); !errors.Is(err, elgamal.ErrDecryptionFailed) {
+				t.Errorf("%s: expected ErrDecryptionFailed for another key, got %v", name, err)
+			}
+			modified := &elgamal.HybridCiphertext{C1: ciphertext.C1, Data: append([]byte{}, ciphertext.Data...)}
+			modified.Data[0] ^= 1
+			if _, err := elGamal.DecryptHybrid(modified, sk, nil); !errors.Is(err, elgamal.ErrDecryptionFailed) {
+				t.Errorf("%s: expected ErrDecryptionFailed for a modified ciphertext, got %v", name, err)
+			}
+		}
+
+		// A ciphertext only decrypts with the context it was encrypted with
+		ciphertext, err := elGamal.EncryptHybrid([]byte{1, 2, 3}, pk, []byte("session 1"))
+		if err != nil {
+			t.Fatalf("%s: EncryptHybrid failed: %v", name, err)
+		}
+		if plaintext, err := elGamal.DecryptHybrid(ciphertext, sk, []byte("session 1")); err != nil || !bytes.Equal(plaintext, []byte{1, 2, 3}) {
+			t.Errorf("%s: DecryptHybrid failed with the same context: %v", name, err)
+		}
+		for _, context := range [][]byte{nil, []byte("session 2")} {
+			if _, err := elGamal.DecryptHybrid(ciphertext, sk, context); !errors.Is(err, elgamal.ErrDecryptionFailed) {
+				t.Errorf("%s: expected ErrDecryptionFailed for another context, got %v", name, err)
+			}
+		}
+		if _, err := elGamal.DecryptHybrid(&elgamal.HybridCiphertext{Data: make([]byte, 16)}, sk, nil); !errors.Is(err, elgamal.ErrDecryptionFailed) {
+			t.Errorf("%s: expected ErrDecryptionFailed without C1, got %v", name, err)
+		}
+
+		publicKeys := []*big.Int{pk, pk, pk, pk, pk}
+		secretKeys := []*big.Int{sk, sk, sk, sk, sk}
+		ciphertexts, err := elGamal.EncryptHybridBatch(messages, publicKeys, nil)
+		if err != nil {
+			t.Fatalf("%s: EncryptHybridBatch failed: %v", name, err)
+		}
+		plaintexts, err := elGamal.DecryptHybridBatch(ciphertexts, secretKeys, nil)
+		if err != nil {
+			t.Fatalf("%s: DecryptHybridBatch failed: %v", name, err)
+		}
+		if !reflect.DeepEqual(plaintexts, messages) {
+			t.Errorf("%s: Plaintext is not correct", name)
+		}
+	}
+}
+
+func TestOTBasicProtocolMessages(t *testing.T) {
+	l := 8 * 300 // Longer than q in both groups
+	m := 16
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+	}
+	messages[0].Message0 = make([]byte, l/8) // All zero
+	messages[0].Message1 = make([]byte, l/8)
+	messages[1].Message0[0] = 0 // Leading zero byte
+	messages[1].Message1[0] = 0
+	messages[2].Message0 = []byte{} // Messages of different lengths within a pair
+	messages[2].Message1 = utils.RandomBits(8 * 5000)
+	messages[3].Message0 = utils.RandomBits(8 * 40)
+	messages[3].Message1 = []byte{7}
+
+	for _, name := range []string{elgamal.FFDHE2048, "P-256"} {
+		elGamal := elgamal.ElGamal{}
+		if name == "P-256" {
+			elGamal.SetGroup(elgamal.NewP256Group())
+		} else if err := elGamal.InitStandard(name); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
+		plaintext, _, err := OTBasic.OTBasicProtocol(m, selectionBits, messages, elGamal)
+		if err != nil {
+			t.Fatalf("%s: protocol failed: %v", name, err)
+		}
+		for i := 0; i < m; i++ {
+			expected := messages[i].Message0
+			if selectionBits[i] == 1 {
+				expected = messages[i].Message1
+			}
+			if !bytes.Equal(plaintext[i], expected) {
+				t.Errorf("%s: Plaintext is not correct", name)
+			}
+		}
+	}
+
+	// The receiver rejects a ciphertext of another receiver's key
+	elGamal := elgamal.ElGamal{}
+	elGamal.SetGroup(elgamal.NewP256Group())
+	receiver := OTBasic.OTReceiver{}
+	if err := receiver.Init([]byte{0}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if _, err := receiver.Choose(1, &elGamal); err != nil {
+		t.Fatalf("Choose failed: %v", err)
+	}
+	otherSK, err := elGamal.MakeSecretKey()
+	if err != nil {
+		t.Fatalf("MakeSecretKey failed: %v", err)
+	}
+	ciphertext, err := elGamal.EncryptHybrid([]byte{1}, elGamal.Gen(otherSK), nil)
+	if err != nil {
+		t.Fatalf("EncryptHybrid failed: %v", err)
+	}
+	_, err = receiver.DecryptMessage([]*utils.HybridCiphertextPair{{Ciphertext0: ciphertext, Ciphertext1: ciphertext}}, &elGamal)
+	if !errors.Is(err, OTBasic.ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed, got %v", err)
+	}
+
+	// The receiver rejects the ciphertexts of a sender with another context, e.g. of another session
+	publicKeys, err := receiver.Choose(1, &elGamal)
+	if err != nil {
+		t.Fatalf("Choose failed: %v", err)
+	}
+	sender := OTBasic.OTSender{}
+	sender.SetContext([]byte("session 1"))
+	receiver.SetContext([]byte("session 2"))
+	if err := sender.Init([]*utils.MessagePair{{Message0: []byte{1}, Message1: []byte{2}}}); err != nil {
+		t.Fatalf("Init failed: %v", err)
+	}
+	if err := sender.ReceiveKeys(publicKeys, &elGamal); err != nil {
+		t.Fatalf("ReceiveKeys failed: %v", err)
+	}
+	ciphertextPairs, err := sender.EncryptMessages(&elGamal)
+	if err != nil {
+		t.Fatalf("EncryptMessages failed: %v", err)
+	}
+	if _, err := receiver.DecryptMessage(ciphertextPairs, &elGamal); !errors.Is(err, OTBasic.ErrDecryptionFailed) {
+		t.Errorf("expected ErrDecryptionFailed for another context, got %v", err)
+	}
+	receiver.SetContext([]byte("session 1"))
+	if plaintexts, err := receiver.DecryptMessage(ciphertextPairs, &elGamal); err != nil || !bytes.Equal(plaintexts[0], []byte{1}) {
+		t.Errorf("DecryptMessage failed with the same context: %v", err)
+	}
+}
+
+func TestExponentialElGamal(t *testing.T) {
+	for _, name := range []string{elgamal.FFDHE2048, "P-256"} {
+		elGamal := elgamal.ElGamal{}
+		if name == "P-256" {
+			elGamal.SetGroup(elgamal.NewP256Group())
+		} else if err := elGamal.InitStandard(name); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", name, err)
+		}
+		pk := elGamal.Gen(sk)
+		encrypt := func(m int64) *elgamal.Ciphertext {
+			ciphertext, err := elGamal.EncryptExponential(big.NewInt(m), pk)
+			if err != nil {
+				t.Fatalf("%s: EncryptExponential failed: %v", name, err)
+			}
+			return ciphertext
+		}
+		decrypt := func(ciphertext *elgamal.Ciphertext, max int64) int64 {
+			m, err := elGamal.DecryptExponential(ciphertext, sk, max)
+			if err != nil {
+				t.Fatalf("%s: DecryptExponential failed: %v", name, err)
+			}
+			return m.Int64()
+		}
+
+		// Count the compatible donors among 30 under encryption
+		compatible := utils.RandomSelectionBits(30)
+		count := int64(0)
+		sum := encrypt(0)
+		for _, bit := range compatible {
+			sum = elGamal.Add(sum, encrypt(int64(bit)))
+			count += int64(bit)
+		}
+		if m := decrypt(sum, 30); m != count {
+			t.Errorf("%s: expected the count %d, got %d", name, count, m)
+		}
+
+		// Weighted sum of scores: 3·17 + 5·9 = 96
+		scores := elGamal.Add(elGamal.ScalarMul(encrypt(17), big.NewInt(3)), elGamal.ScalarMul(encrypt(9), big.NewInt(5)))
+		rerandomized, err := elGamal.Rerandomize(scores, pk)
+		if err != nil {
+			t.Fatalf("%s: Rerandomize failed: %v", name, err)
+		}
+		if rerandomized.C1.Cmp(scores.C1) == 0 || rerandomized.C2.Cmp(scores.C2) == 0 {
+			t.Errorf("%s: the rerandomized ciphertext is not fresh", name)
+		}
+		for _, ciphertext := range []*elgamal.Ciphertext{scores, rerandomized} {
+			if m := decrypt(ciphertext, 1000); m != 96 {
+				t.Errorf("%s: expected 96, got %d", name, m)
+			}
+		}
+
+		// The bounds of the search: 0, perfect squares and the maximum itself
+		for _, max := range []int64{0, 1, 15, 16, 99999} {
+			for _, m := range []int64{0, max / 2, max} {
+				if got := decrypt(encrypt(m), max); got != m {
+					t.Errorf("%s: expected %d for the maximum %d, got %d", name, m, max, got)
+				}
+			}
+			if _, err := elGamal.DecryptExponential(encrypt(max+1), sk, max); !errors.Is(err, elgamal.ErrPlaintextOutOfRange) {
+				t.Errorf("%s: expected ErrPlaintextOutOfRange for %d > %d, got %v", name, max+1, max, err)
+			}
+		}
+		// The maximum is bounded, so the table of baby steps cannot exhaust the memory
+		for _, max := range []int64{-1, elgamal.MaxExponentialPlaintext + 1, math.MaxInt64} {
+			if _, err := elGamal.DecryptExponential(encrypt(0), sk, max); !errors.Is(err, elgamal.ErrPlaintextOutOfRange) {
+				t.Errorf("%s: expected ErrPlaintextOutOfRange for the maximum %d, got %v", name, max, err)
+			}
+		}
+		if _, err := elGamal.DecryptExponential(encrypt(-1), sk, 1000); !errors.Is(err, elgamal.ErrPlaintextOutOfRange) {
+			t.Errorf("%s: expected ErrPlaintextOutOfRange for -1, got %v", name, err)
+		}
+		if _, err := elGamal.DecryptExponential(&elgamal.Ciphertext{}, sk, 1000); !errors.Is(err, elgamal.ErrDecryptionFailed) {
+			t.Errorf("%s: expected ErrDecryptionFailed for an empty ciphertext, got %v", name, err)
+		}
+	}
+}
+
+func TestCommunicationRecord(t *testing.T) {
+	communication := utils.Communication{}
+	communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, 10)
+	communication.Record(utils.PhaseBaseOT, utils.SenderToReceiver, 5) // Same flow as the previous message
+	communication.Record(utils.PhaseSeedTransfer, utils.ReceiverToSender, 20)
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, 30)
+	communication.Record(utils.PhaseUMatrix, utils.SenderToReceiver, 1)
+	communication.Record(utils.PhaseUMatrix, utils.ReceiverToSender, 2)
+
+	expected := [utils.NumPhases]utils.PhaseCommunication{
+		utils.PhaseBaseOT:       {SenderToReceiver: 15, ReceiverToSender: 0, Rounds: 1},
+		utils.PhaseSeedTransfer: {SenderToReceiver: 0, ReceiverToSender: 20, Rounds: 1},
+		utils.PhaseUMatrix:      {SenderToReceiver: 1, ReceiverToSender: 32, Rounds: 3},
+	}
+	if communication.Phases != expected {
+		t.Errorf("Expected %v, got %v", expected, communication.Phases)
+	}
+
+	total := communication.Total()
+	if total != (utils.PhaseCommunication{SenderToReceiver: 16, ReceiverToSender: 52, Rounds: 5}) {
+		t.Errorf("Total is not correct: %v", total)
+	}
+
+	communication.Add(&communication)
+	if communication.Total().Rounds != 10 || communication.Phases[utils.PhaseUMatrix].ReceiverToSender != 64 {
+		t.Errorf("Add is not correct: %v", communication.Phases)
+	}
+}
+
+func TestCommunicationAccounting(t *testing.T) {
+	k := 128
+	l := 8
+	m := 1000
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+
+	_, communication, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+
+	// Every phase is a single message in one direction
+	baseOT := communication.Phases[utils.PhaseBaseOT]
+	if baseOT.SenderToReceiver == 0 || baseOT.ReceiverToSender != 0 || baseOT.Rounds != 1 {
+		t.Errorf("Base OT phase is not correct: %v", baseOT)
+	}
+	seedTransfer := communication.Phases[utils.PhaseSeedTransfer]
+	if seedTransfer.SenderToReceiver != 0 || seedTransfer.ReceiverToSender == 0 || seedTransfer.Rounds != 1 {
+		t.Errorf("Seed transfer phase is not correct: %v", seedTransfer)
+	}
+	expectedU := utils.PhaseCommunication{ReceiverToSender: utils.BitMatrixWireLength(utils.NewBitMatrix(k, m)), Rounds: 1}
+	if communication.Phases[utils.PhaseUMatrix] != expectedU {
+		t.Errorf("Expected %v for the U matrix, got %v", expectedU, communication.Phases[utils.PhaseUMatrix])
+	}
+	expectedCiphertexts := utils.PhaseCommunication{SenderToReceiver: 2 + 4 + m*2*(4+utils.ByteLength(l)), Rounds: 1}
+	if communication.Phases[utils.PhaseCiphertexts] != expectedCiphertexts {
+		t.Errorf("Expected %v for the ciphertexts, got %v", expectedCiphertexts, communication.Phases[utils.PhaseCiphertexts])
+	}
+
+	// The KOS correlation check adds the coin tossing and the check to the U matrix phase
+	_, communicationKOS, err := OTExt.OTExtensionProtocolKOS(k, l, m, selectionBits, messages, elGamal, false)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+	uMatrixKOS := communicationKOS.Phases[utils.PhaseUMatrix]
+	if uMatrixKOS.Rounds != 5 || uMatrixKOS.ReceiverToSender <= expectedU.ReceiverToSender || uMatrixKOS.SenderToReceiver == 0 {
+		t.Errorf("U matrix phase of KOS is not correct: %v", uMatrixKOS)
+	}
+	if communicationKOS.Phases[utils.PhaseCiphertexts] != expectedCiphertexts {
+		t.Errorf("Expected %v for the ciphertexts of KOS, got %v", expectedCiphertexts, communicationKOS.Phases[utils.PhaseCiphertexts])
+	}
+
+	// OTBasic sends no seeds and no U matrix
+	_, communicationBasic, err := OTBasic.OTBasicProtocol(64, selectionBits[:64], messages[:64], elGamal)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+	if communicationBasic.Total().Rounds != 2 || communicationBasic.Phases[utils.PhaseUMatrix].Rounds != 0 {
+		t.Errorf("OTBasic communication is not correct: %v", communicationBasic.Phases)
+	}
+}
+
+func TestDeterministicRandomness(t *testing.T) {
+	readAll := func(seed string) []byte {
+		random, err := utils.NewDeterministicReader([]byte(seed))
+		if err != nil {
+			t.Fatalf("NewDeterministicReader failed: %v", err)
+		}
+		stream := make([]byte, 100)
+		if _, err := random.Read(stream[:30]); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if _, err := random.Read(stream[30:]); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		return stream
+	}
+	if !bytes.Equal(readAll("seed"), readAll("seed")) {
+		t.Errorf("Streams with the same seed are different")
+	}
+	if bytes.Equal(readAll("seed"), readAll("other seed")) {
+		t.Errorf("Streams with different seeds are equal")
+	}
+	if _, err := utils.NewDeterministicReader(nil); err == nil {
+		t.Errorf("Expected an error for an empty seed")
+	}
+}
+
+// TestRandomnessErrors checks that a failing source of randomness is returned as an error by ElGamal and the
+// protocols, instead of terminating the process.
+func TestRandomnessErrors(t *testing.T) {
+	k := 128
+	l := 8
+	m := 256
+
+	broken := elgamal.ElGamal{}
+	broken.SetRandom(iotest.ErrReader(errors.New("broken source")))
+	if err := broken.Init(); err == nil {
+		t.Errorf("Expected an error from Init")
+	}
+
+	for _, name := range []string{elgamal.FFDHE2048, "P-256"} {
+		elGamal := elgamal.ElGamal{}
+		if name == "P-256" {
+			elGamal.SetGroup(elgamal.NewP256Group())
+		} else if err := elGamal.InitStandard(name); err != nil {
+			t.Fatalf("InitStandard failed: %v", err)
+		}
+		sk, err := elGamal.MakeSecretKey()
+		if err != nil {
+			t.Fatalf("%s: MakeSecretKey failed: %v", name, err)
+		}
+		pk := elGamal.Gen(sk)
+		elGamal.SetRandom(iotest.ErrReader(errors.New("broken source")))
+
+		if _, err := elGamal.MakeSecretKey(); err == nil {
+			t.Errorf("%s: expected an error from MakeSecretKey", name)
+		}
+		if _, err := elGamal.OGen(); err == nil {
+			t.Errorf("%s: expected an error from OGen", name)
+		}
+		if _, err := elGamal.Encrypt(big.NewInt(1), pk); err == nil {
+			t.Errorf("%s: expected an error from Encrypt", name)
+		}
+		if _, err := elGamal.EncryptBatch([]*big.Int{big.NewInt(1)}, []*big.Int{pk}); err == nil {
+			t.Errorf("%s: expected an error from EncryptBatch", name)
+		}
+		if _, err := elGamal.EncryptHybrid([]byte{1}, pk, nil); err == nil {
+			t.Errorf("%s: expected an error from EncryptHybrid", name)
+		}
+		if _, err := elGamal.EncryptExponential(big.NewInt(1), pk); err == nil {
+			t.Errorf("%s: expected an error from EncryptExponential", name)
+		}
+
+		selectionBits := utils.RandomSelectionBits(m)
+		var messages []*utils.MessagePair
+		for i := 0; i < m; i++ {
+			messages = append(messages, &utils.MessagePair{Message0: utils.RandomBits(l), Message1: utils.RandomBits(l)})
+		}
+		if _, _, err := OTBasic.OTBasicProtocol(m, selectionBits, messages, elGamal); err == nil {
+			t.Errorf("%s: expected an error from OTBasicProtocol", name)
+		}
+		if _, _, err := OTExt.OTExtensionProtocolEklundh(k, l, m, selectionBits, messages, elGamal, false); err == nil {
+			t.Errorf("%s: expected an error from OTExtensionProtocolEklundh", name)
+		}
+	}
+}
+
+func TestOTExtensionReproducibleTranscript(t *testing.T) {
+	k := 128
+	l := 8
+	m := 500
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	// transcript runs the KOS protocol with all randomness drawn from a stream seeded with seed,
+	// and returns every message sent between the parties in the wire format.
+	transcript := func(seed string) [][]byte {
+		must := func(err error) {
+			if err != nil {
+				t.Fatalf("Protocol step failed: %v", err)
+			}
+		}
+		random, err := utils.NewDeterministicReader([]byte(seed))
+		must(err)
+		selectionBits, err := utils.RandomSelectionBitsFrom(random, m)
+		must(err)
+		var messages []*utils.MessagePair
+		for i := 0; i < m; i++ {
+			message0, err0 := utils.RandomBitsFrom(random, l)
+			message1, err1 := utils.RandomBitsFrom(random, l)
+			must(errors.Join(err0, err1))
+			messages = append(messages, &utils.MessagePair{Message0: message0, Message1: message1})
+		}
+
+		var sent [][]byte
+		send := func(data []byte, err error) {
+			must(err)
+			sent = append(sent, data)
+		}
+
+		receiver := OTExt.OTReceiver{}
+		sender := OTExt.OTSender{}
+		receiver.SetRandom(random)
+		sender.SetRandom(random)
+		must(receiver.Init(selectionBits, k, l))
+		must(sender.Init(messages, k, l))
+		must(receiver.AddCheckOTs())
+		sender.AddCheckOTs()
+		must(sender.ChooseRandomS())
+		must(receiver.ChooseSeeds())
+
+		publicKeys, err := sender.Choose(&elGamal)
+		must(err)
+		send(utils.MarshalPublicKeyPairs(publicKeys))
+		must(receiver.ReceiveKeys(publicKeys, &elGamal))
+		seedCiphertexts, err := receiver.EncryptSeeds(&elGamal)
+		must(err)
+		send(utils.MarshalCiphertextPairs(seedCiphertexts))
+		must(sender.DecryptSeeds(seedCiphertexts, &elGamal))
+
+		U, err := receiver.GenerateMatrixTAndUEklundh(false)
+		must(err)
+		send(utils.MarshalBitMatrix(U))
+		must(sender.GenerateMatrixQEklundh(U, false))
+
+		commitment, err := sender.CommitChallengeSeed()
+		send(commitment, err)
+		seedReceiver, err := receiver.ChooseChallengeSeed(commitment)
+		send(seedReceiver, err)
+		seedSender, err := sender.OpenChallengeSeed(seedReceiver)
+		send(seedSender, err)
+		must(receiver.ReceiveChallengeSeed(seedSender))
+		check, err := receiver.MakeCorrelationCheck()
+		must(err)
+		must(sender.VerifyCorrelationCheck(check))
+
+		byteCiphertexts, err := sender.MakeAndSendCiphertexts()
+		must(err)
+		send(utils.MarshalByteCiphertextPairs(byteCiphertexts))
+		plaintext, err := receiver.DecryptCiphertexts(byteCiphertexts)
+		must(err)
+
+		// Check if the plaintext is correct
+		for i := 0; i < m; i++ {
+			if selectionBits[i] == 0 {
+				if !bytes.Equal(plaintext[i], messages[i].Message0) {
+					t.Errorf("Plaintext is not correct")
+				}
+			} else {
+				if !bytes.Equal(plaintext[i], messages[i].Message1) {
+					t.Errorf("Plaintext is not correct")
+				}
+			}
+		}
+		return sent
+	}
+
+	first := transcript("transcript seed")
+	second := transcript("transcript seed")
+	other := transcript("another seed")
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("Runs with the same seed have different transcripts")
+	}
+	for i := range first {
+		if bytes.Equal(first[i], other[i]) {
+			t.Errorf("Message %d is the same for runs with different seeds", i)
+		}
+	}
+}
+
+func TestSilentOTParameters(t *testing.T) {
+	for _, params := range []OTExt.SilentOTParameters{OTExt.FerretParameters, OTExt.FerretSmallParameters} {
+		if err := params.Validate(); err != nil {
+			t.Errorf("Parameters %+v are invalid: %v", params, err)
+		}
+	}
+	if outputs := OTExt.FerretParameters.Outputs(); outputs != 10198341 {
+		t.Errorf("FerretParameters give %d COTs per iteration, expected 10198341", outputs)
+	}
+	if outputs := OTExt.FerretSmallParameters.Outputs(); outputs != 602114 {
+		t.Errorf("FerretSmallParameters give %d COTs per iteration, expected 602114", outputs)
+	}
+
+	invalid := []OTExt.SilentOTParameters{
+		{N: 4000, K: 256, T: 16},   // N/T is not a power of two
+		{N: 4096, K: 256, T: 3},    // N is not a multiple of T
+		{N: 4096, K: 5, T: 16},     // K is smaller than the weight of the code
+		{N: 512, K: 440, T: 16},    // N does not exceed the base COTs
+		{N: 4096, K: 256, T: 0},    // No noise
+		{N: 4096, K: 256, T: 4096}, // Blocks of one entry
+	}
+	for _, params := range invalid {
+		if err := params.Validate(); !errors.Is(err, OTExt.ErrInvalidParameter) {
+			t.Errorf("Expected ErrInvalidParameter for %+v, got %v", params, err)
+		}
+	}
+}
+
+func TestSilentOTParties(t *testing.T) {
+	params := OTExt.SilentOTParameters{N: 2048, K: 128, T: 16}
+	m := 2 * params.Outputs()
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
+	}
+
+	sender := OTExt.SilentOTSender{}
+	receiver := OTExt.SilentOTReceiver{}
+	must(sender.Init(params))
+	must(receiver.Init(params))
+
+	// Make the base COTs locally instead of with OTExtensionProtocolCorrelated.
+	delta, err := sender.ChooseDelta()
+	must(err)
+	choiceBits, err := receiver.ChooseBaseBits()
+	must(err)
+	var pairs []*utils.MessagePair
+	var messages [][]byte
+	for j := range choiceBits {
+		x0 := utils.RandomBits(128)
+		x1 := make([]byte, len(x0))
+		for b := range x1 {
+			x1[b] = x0[b] ^ delta[b]
+		}
+		pairs = append(pairs, &utils.MessagePair{Message0: x0, Message1: x1})
+		if choiceBits[j] == 0 {
+			messages = append(messages, x0)
+		} else {
+			messages = append(messages, x1)
+		}
+	}
+	must(sender.ReceiveBaseCOTs(pairs))
+	must(receiver.ReceiveBaseCOTs(choiceBits, messages))
+	setup, err := sender.ChooseSetup()
+	must(err)
+	must(receiver.ReceiveSetup(setup))
+
+	// Run two iterations, where the second one uses base COTs produced by the first one.
+	for iteration := 0; iteration < 2; iteration++ {
+		corrections, err := receiver.ChoosePoints()
+		must(err)
+		trees, err := sender.ExpandTrees(corrections)
+		must(err)
+		must(receiver.ReceiveTrees(trees))
+	}
+	if sender.Buffered() != m || receiver.Buffered() != m {
+		t.Fatalf("Expected %d buffered COTs, got %d and %d", m, sender.Buffered(), receiver.Buffered())
+	}
+
+	// Check the correlation z_j = y_j ⊕ x_j·Δ, and that the choice bits are not biased.
+	senderPairs, err := sender.MakeCorrelatedMessages(m)
+	must(err)
+	receiverBits, receiverMessages, err := receiver.MakeCorrelatedMessages(m)
+	must(err)
+	ones := 0
+	for j := 0; j < m; j++ {
+		expected := senderPairs[j].Message0
+		if receiverBits[j] == 1 {
+			expected = senderPairs[j].Message1
+			ones++
+		}
+		if !bytes.Equal(receiverMessages[j], expected) {
+			t.Fatalf("COT %d is not correct", j)
+		}
+		for b := range delta {
+			if senderPairs[j].Message0[b]^senderPairs[j].Message1[b] != delta[b] {
+				t.Fatalf("COT %d does not have the correlation Δ", j)
+			}
+		}
+	}
+	if ones < 2*m/5 || ones > 3*m/5 {
+		t.Errorf("Choice bits are biased: %d ones out of %d", ones, m)
+	}
+
+	// Invalid messages are rejected.
+	if _, err := sender.ExpandTrees(make([]byte, 3)); !errors.Is(err, OTExt.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for the corrections, got %v", err)
+	}
+	if _, err := receiver.ChoosePoints(); err != nil {
+		t.Fatalf("ChoosePoints failed: %v", err)
+	}
+	if err := receiver.ReceiveTrees(make([]*utils.GGMTreeMessage, params.T)); !errors.Is(err, OTExt.ErrDimensionMismatch) {
+		t.Errorf("Expected ErrDimensionMismatch for the trees, got %v", err)
+	}
+	if _, err := sender.MakeCorrelatedMessages(1); !errors.Is(err, OTExt.ErrInvalidParameter) {
+		t.Errorf("Expected ErrInvalidParameter when no COTs are buffered, got %v", err)
+	}
+}
+
+func TestSilentOTSession(t *testing.T) {
+	params := OTExt.SilentOTParameters{N: 4096, K: 256, T: 16}
+	l := 64
+	m := 5000
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	session, err := OTExt.NewSilentOTSession(params, l, elGamal, true)
+	if err != nil {
+		t.Fatalf("Session failed: %v", err)
+	}
+	baseCommunication := session.Communication()
+
+	// Random OTs from the silent iterations can be derandomized like the random OTs of OTExtension.
+	randomSender, randomReceiver, err := session.Extend(m)
+	if err != nil {
+		t.Fatalf("Extend failed: %v", err)
+	}
+	selectionBits := utils.RandomSelectionBits(m)
+	var messages []*utils.MessagePair
+	for i := 0; i < m; i++ {
+		msg := utils.MessagePair{
+			Message0: utils.RandomBits(l),
+			Message1: utils.RandomBits(l),
+		}
+		messages = append(messages, &msg)
+	}
+	plaintext, _, err := OTExt.OTDerandomizeProtocol(randomSender, randomReceiver, selectionBits, messages)
+	if err != nil {
+		t.Fatalf("Protocol failed: %v", err)
+	}
+	for i := 0; i < m; i++ {
+		if selectionBits[i] == 0 {
+			if !bytes.Equal(plaintext[i], messages[i].Message0) {
+				t.Errorf("Plaintext is not correct")
+			}
+		} else {
+			if !bytes.Equal(plaintext[i], messages[i].Message1) {
+				t.Errorf("Plaintext is not correct")
+			}
+		}
+	}
+
+	// Correlated OTs continue with the next COTs of the session.
+	pairs, correlatedReceiver, err := session.ExtendCorrelated(m)
+	if err != nil {
+		t.Fatalf("ExtendCorrelated failed: %v", err)
+	}
+	for i := 0; i < m; i++ {
+		expected := pairs[i].Message0
+		if correlatedReceiver.ChoiceBits[i] == 1 {
+			expected = pairs[i].Message1
+		}
+		if !bytes.Equal(correlatedReceiver.Messages[i], expected) {
+			t.Errorf("Correlated message is not correct")
+		}
+	}
+
+	// Only the GGM trees are sent after the base COTs: one correction bit per level, and 2h + 1 blocks per tree.
+	iterations := (2*m + params.Outputs() - 1) / params.Outputs()
+	if session.Iterations() != iterations {
+		t.Errorf("Expected %d iterations, got %d", iterations, session.Iterations())
+	}
+	communication := session.Communication()
+	if communication.Phases[utils.PhaseBaseOT] != baseCommunication.Phases[utils.PhaseBaseOT] ||
+		communication.Phases[utils.PhaseUMatrix] != baseCommunication.Phases[utils.PhaseUMatrix] {
+		t.Errorf("Iterations changed the communication of the base COTs")
+	}
+	expansion := communication.Phases[utils.PhaseExpansion]
+	treeBlocks := make([]int, params.T*(2*8+1))
+	for i := range treeBlocks {
+		treeBlocks[i] = 16
+	}
+	if expansion.SenderToReceiver != iterations*utils.ByteStringsWireLength(treeBlocks...) ||
+		expansion.ReceiverToSender != iterations*utils.ByteStringsWireLength(params.T) ||
+		expansion.Rounds != 2*iterations {
+		t.Errorf("Unexpected communication of the iterations: %+v", expansion)
+	}
+
+	if _, _, err := session.Extend(0); !errors.Is(err, OTExt.ErrInvalidParameter) {
+		t.Errorf("Expected ErrInvalidParameter for m = 0, got %v", err)
+	}
+}
+
+func TestMessagePadding(t *testing.T) {
+	pair := &utils.MessagePair{Message0: []byte{7, 0}, Message1: []byte{1, 2, 3, 0, 0}}
+	length := utils.PaddedLength(pair)
+	if length != 6 {
+		t.Fatalf("expected a padded length of 6, got %d", length)
+	}
+
+	for _, message := range [][]byte{{}, pair.Message0, pair.Message1, {0x80, 0}} {
+		padded := utils.PadMessage(message, length)
+		if len(padded) != length {
+			t.Errorf("expected %d padded bytes, got %d", length, len(padded))
+		}
+		unpadded, err := utils.UnpadMessage(padded)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !bytes.Equal(unpadded, message) {
+			t.Errorf("Plaintext is not correct")
+		}
+	}
+
+	for _, padded := range [][]byte{{}, {0, 0, 0}, {1, 2, 0}} {
+		if _, err := utils.UnpadMessage(padded); !errors.Is(err, utils.ErrInvalidMessageLength) {
+			t.Errorf("expected ErrInvalidMessageLength for %v, got %v", padded, err)
+		}
+	}
+}
+
+func TestStreamEncryption(t *testing.T) {
+	key := utils.RandomBits(128)
+	sizes := []int{0, 1, utils.StreamChunkSize - 1, utils.StreamChunkSize, utils.StreamChunkSize + 1, 3*utils.StreamChunkSize + 5}
+
+	for _, size := range sizes {
+		payload := make([]byte, size)
+		rand.Read(payload)
+
+		// Two streams back to back, as sent by the FileSender.
+		var channel bytes.Buffer
+		written, err := utils.EncryptStream(key, bytes.NewReader(payload), &channel)
+		if err != nil {
+			t.Fatalf("EncryptStream failed: %v", err)
+		}
+		if written != int64(channel.Len()) {
+			t.Errorf("EncryptStream returned %d bytes, but wrote %d", written, channel.Len())
+		}
+		if _, err := utils.EncryptStream(key, bytes.NewReader(payload), &channel); err != nil {
+			t.Fatalf("EncryptStream failed: %v", err)
+		}
+		encrypted := append([]byte(nil), channel.Bytes()...)
+
+		if err := utils.SkipStream(&channel); err != nil {
+			t.Fatalf("SkipStream failed for %d bytes: %v", size, err)
+		}
+		var output bytes.Buffer
+		if _, err := utils.DecryptStream(key, &channel, &output); err != nil {
+			t.Fatalf("DecryptStream failed for %d bytes: %v", size, err)
+		}
+		if !bytes.Equal(output.Bytes(), payload) || channel.Len() != 0 {
+			t.Errorf("Payload of %d bytes is not correct", size)
+		}
+
+		// A changed byte, a truncated stream and the wrong key are detected.
+		changed := append([]byte(nil), encrypted...)
+		changed[len(changed)/4] ^= 1
+		if _, err := utils.DecryptStream(key, bytes.NewReader(changed), io.Discard); err == nil {
+			t.Errorf("Changed stream of %d bytes was not detected", size)
+		}
+		first := int(written)
+		if _, err := utils.DecryptStream(key, bytes.NewReader(encrypted[:first-1]), io.Discard); !errors.Is(err, utils.ErrMalformedMessage) {
+			t.Errorf("Expected ErrMalformedMessage for a truncated stream of %d bytes, got %v", size, err)
+		}
+		if _, err := utils.DecryptStream(utils.RandomBits(128), bytes.NewReader(encrypted), io.Discard); !errors.Is(err, utils.ErrStreamAuthentication) {
+			t.Errorf("Expected ErrStreamAuthentication for the wrong key, got %v", err)
+		}
+	}
+}
+
+func TestOTFileTransferProtocol(t *testing.T) {
+	size := int64(5*utils.StreamChunkSize + 123)
+
+	elGamal := elgamal.ElGamal{}
+	if err := elGamal.InitStandard(elgamal.FFDHE2048); err != nil {
+		t.Fatalf("InitStandard failed: %v", err)
+	}
+
+	// Payloads are streamed from deterministic readers and hashed, so they are never held in memory.
+	payload := func(seed string) io.Reader {
+		random, err := utils.NewDeterministicReader([]byte(seed))
+		if err != nil {
+			t.Fatalf("NewDeterministicReader failed: %v", err)
+		}
+		return io.LimitReader(random, size)
+	}
+	digest := func(reader io.Reader) []byte {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, reader); err != nil {
+			t.Fatalf("Copy failed: %v", err)
+		}
+		return hash.Sum(nil)
+	}
+	expected := [][]byte{digest(payload("file 0")), digest(payload("file 1"))}
+
+	for _, selectionBit := range []byte{0, 1} {
+		hash := sha256.New()
+		communication, err := OTBasic.OTFileTransferProtocol(selectionBit, payload("file 0"), payload("file 1"), hash, elGamal)
+		if err != nil {
+			t.Fatalf("Protocol failed: %v", err)
+		}
+		if !bytes.Equal(hash.Sum(nil), expected[selectionBit]) {
+			t.Errorf("Payload %d is not correct", selectionBit)
+		}
+		if communication.Phases[utils.PhaseCiphertexts].SenderToReceiver < int(2*size) {
+			t.Errorf("Communication does not include both payloads: %+v", communication.Phases[utils.PhaseCiphertexts])
+		}
+	}
+
+	if _, err := OTBasic.OTFileTransferProtocol(2, payload("file 0"), payload("file 1"), io.Discard, elGamal); !errors.Is(err, OTBasic.ErrInvalidChoiceBit) {
+		t.Errorf("Expected ErrInvalidChoiceBit, got %v", err)
+	}
+}
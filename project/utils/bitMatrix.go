@@ -0,0 +1,201 @@
+package utils
+
+import (
+	"cryptographic-computing/project/parallel"
+	"encoding/binary"
+	"log"
+)
+
+// BitMatrix is a bit matrix of size Rows x Cols, where every row is packed into 64-bit words.
+// All rows are stored back to back in a single slice, so a matrix of m x k bits only costs m*k/8 bytes
+// and XORs of rows touch one word per 64 bits. Bit j of a row is stored in word j/64 at bit position j%64.
+// Bits beyond Cols in the last word of a row are always kept as 0.
+type BitMatrix struct {
+	Rows     int      // Number of rows in the matrix.
+	Cols     int      // Number of bits in each row.
+	RowWords int      // Number of 64-bit words used to store each row.
+	Words    []uint64 // Rows * RowWords words stored row after row.
+}
+
+// WordsFor returns the number of 64-bit words needed to store bitLength bits.
+func WordsFor(bitLength int) int {
+	return (bitLength + 63) / 64
+}
+
+// NewBitMatrix allocates a zero bit matrix of size rows x cols.
+func NewBitMatrix(rows int, cols int) *BitMatrix {
+	rowWords := WordsFor(cols)
+	return &BitMatrix{
+		Rows:     rows,
+		Cols:     cols,
+		RowWords: rowWords,
+		Words:    make([]uint64, rows*rowWords),
+	}
+}
+
+// Row returns the packed words of the i'th row. The returned slice aliases the matrix.
+func (matrix *BitMatrix) Row(i int) []uint64 {
+	start := i * matrix.RowWords
+	end := start + matrix.RowWords
+	return matrix.Words[start:end:end]
+}
+
+// Bit returns the bit at position (i, j) as 0 or 1.
+func (matrix *BitMatrix) Bit(i int, j int) byte {
+	return GetBit(matrix.Row(i), j)
+}
+
+// SetBit sets the bit at position (i, j) to bit (0 or 1).
+func (matrix *BitMatrix) SetBit(i int, j int, bit byte) {
+	SetBit(matrix.Row(i), j, bit)
+}
+
+// GetBit returns the j'th bit of a packed bit string as 0 or 1.
+func GetBit(words []uint64, j int) byte {
+	return byte(words[j/64]>>(j%64)) & 1
+}
+
+// SetBit sets the j'th bit of a packed bit string to bit (0 or 1).
+func SetBit(words []uint64, j int, bit byte) {
+	mask := uint64(1) << (j % 64)
+	if bit&1 == 1 {
+		words[j/64] |= mask
+	} else {
+		words[j/64] &^= mask
+	}
+}
+
+// PackBits packs a slice of 0/1 bytes into 64-bit words.
+func PackBits(bits []byte) []uint64 {
+	words := make([]uint64, WordsFor(len(bits)))
+	for j, bit := range bits {
+		words[j/64] |= uint64(bit&1) << (j % 64)
+	}
+	return words
+}
+
+// UnpackBits unpacks the first bitLength bits of a packed bit string into a slice of 0/1 bytes.
+func UnpackBits(words []uint64, bitLength int) []byte {
+	bits := make([]byte, bitLength)
+	for j := range bits {
+		bits[j] = GetBit(words, j)
+	}
+	return bits
+}
+
+// WordsToBytes converts packed words to bytes in little-endian order, e.g. for hashing a row of a bit matrix.
+func WordsToBytes(words []uint64) []byte {
+	bytes := make([]byte, 8*len(words))
+	for i, word := range words {
+		binary.LittleEndian.PutUint64(bytes[8*i:], word)
+	}
+	return bytes
+}
+
+// XORWords computes dst = a ⊕ b word by word. All slices must have the same length.
+func XORWords(dst []uint64, a []uint64, b []uint64) {
+	for i := range dst {
+		dst[i] = a[i] ^ b[i]
+	}
+}
+
+// ClearPadding sets the unused bits after bitLength in the last word of a packed bit string to 0.
+func ClearPadding(words []uint64, bitLength int) {
+	if remainingBits := bitLength % 64; remainingBits != 0 {
+		words[len(words)-1] &= (uint64(1) << remainingBits) - 1
+	}
+}
+
+// TransposeBitMatrix transposes a packed bit matrix using a naive algorithm of O(n^2) single bit accesses.
+// Used in the OTExtensionProtocolTranspose for transposing the matrices T and Q.
+func TransposeBitMatrix(matrix *BitMatrix) *BitMatrix {
+	transposed := NewBitMatrix(matrix.Cols, matrix.Rows)
+	for i := 0; i < matrix.Rows; i++ {
+		row := matrix.Row(i)
+		for j := 0; j < matrix.Cols; j++ {
+			if GetBit(row, j) == 1 {
+				transposed.SetBit(j, i, 1)
+			}
+		}
+	}
+	return transposed
+}
+
+// Masks used by eklundhTranspose64. Entry i selects the lower half of every group of 2*width bits,
+// where width = 32 >> i.
+var eklundhMasks = [6]uint64{
+	0x00000000FFFFFFFF,
+	0x0000FFFF0000FFFF,
+	0x00FF00FF00FF00FF,
+	0x0F0F0F0F0F0F0F0F,
+	0x3333333333333333,
+	0x5555555555555555,
+}
+
+// eklundhTranspose64 transposes a 64 x 64 bit block in place using Eklundh's algorithm on words.
+// Each step swaps the top right and bottom left sub-blocks of width 32, 16, ..., 1 for all rows at once,
+// using one shift, XOR and mask per pair of rows instead of swapping single bits.
+func eklundhTranspose64(block *[64]uint64) {
+	for step, width := 0, 32; width > 0; step, width = step+1, width/2 {
+		mask := eklundhMasks[step]
+		for i1 := 0; i1 < 64; i1 += 2 * width {
+			for i2 := i1; i2 < i1+width; i2++ {
+				swap := ((block[i2] >> width) ^ block[i2+width]) & mask
+				block[i2] ^= swap << width
+				block[i2+width] ^= swap
+			}
+		}
+	}
+}
+
+// EklundhTransposeBitMatrix transposes a packed bit matrix of any size using Eklundh's algorithm on 64-bit words.
+// The matrix is divided into 64 x 64 bit blocks (a 128 x 128 block for k = 128 is handled as four such blocks),
+// which are transposed with eklundhTranspose64 and written to their mirrored position in the result.
+// Blocks on the border are padded with zeros. The blocks are divided between the given number of worker goroutines.
+func EklundhTransposeBitMatrix(matrix *BitMatrix, workers int) *BitMatrix {
+	transposed := NewBitMatrix(matrix.Cols, matrix.Rows)
+
+	blockRows := WordsFor(matrix.Rows) // Number of 64-row blocks in the input (= words per row in the output)
+	blockCols := matrix.RowWords       // Number of 64-column blocks in the input
+
+	// transposeBlockCols transposes all blocks in the input block columns [start, end).
+	// Block columns map to distinct output rows, so they can be handled by separate goroutines.
+	transposeBlockCols := func(start int, end int) {
+		var block [64]uint64
+		for blockCol := start; blockCol < end; blockCol++ {
+			for blockRow := 0; blockRow < blockRows; blockRow++ {
+				for i := 0; i < 64; i++ {
+					row := blockRow*64 + i
+					if row < matrix.Rows {
+						block[i] = matrix.Words[row*matrix.RowWords+blockCol]
+					} else {
+						block[i] = 0 // Padding below the last row
+					}
+				}
+
+				eklundhTranspose64(&block)
+
+				for j := 0; j < 64; j++ {
+					col := blockCol*64 + j
+					if col >= matrix.Cols {
+						break // Padding after the last column
+					}
+					transposed.Words[col*transposed.RowWords+blockRow] = block[j]
+				}
+			}
+		}
+	}
+
+	parallel.For(blockCols, workers, transposeBlockCols)
+
+	return transposed
+}
+
+// RandomPackedBits generates bitLength random bits packed into 64-bit words using a secure random number generator.
+func RandomPackedBits(bitLength int) []uint64 {
+	words, err := RandomPackedBitsFrom(nil, bitLength)
+	if err != nil {
+		log.Fatal(err)
+	}
+	return words
+}
@@ -0,0 +1,104 @@
+package utils
+
+// Phase is a phase of an OT protocol run, used to account for the communication of the run.
+type Phase int
+
+const (
+	PhaseBaseOT       Phase = iota // Public keys of the base OTs (and the key of the hash function and the sender's session share, if any).
+	PhaseSeedTransfer              // Seeds encrypted under the base OT public keys, and the receiver's session share (OTExtension only).
+	PhaseUMatrix                   // The matrix U, and the correlation check of the KOS protocol.
+	PhaseCiphertexts               // The final ciphertexts, and the receiver's corrections when derandomizing.
+	PhaseExpansion                 // The GGM tree messages of the silent OT iterations (SilentOTSession only).
+	NumPhases                      // Number of phases.
+)
+
+// String returns the name of the phase, as used in the benchmark CSV headers.
+func (phase Phase) String() string {
+	switch phase {
+	case PhaseBaseOT:
+		return "base_ot"
+	case PhaseSeedTransfer:
+		return "seed_transfer"
+	case PhaseUMatrix:
+		return "u_matrix"
+	case PhaseCiphertexts:
+		return "ciphertexts"
+	case PhaseExpansion:
+		return "expansion"
+	}
+	return "unknown"
+}
+
+// Direction is the direction of a message between the OT sender and the OT receiver. The direction always refers
+// to the roles in the extended OTs, also in the base OT phase of OTExtension where the roles are reversed.
+type Direction int
+
+const (
+	SenderToReceiver Direction = iota
+	ReceiverToSender
+)
+
+// PhaseCommunication is the communication of one phase of a protocol run.
+type PhaseCommunication struct {
+	SenderToReceiver int // Bytes sent from the sender to the receiver.
+	ReceiverToSender int // Bytes sent from the receiver to the sender.
+	Rounds           int // Number of messages (flows) sent. Consecutive messages in the same direction count as one.
+}
+
+// Communication records the bytes sent in each direction and the number of rounds in each phase of a protocol run.
+// The size of a message is its length in the wire format (see wire.go), so the numbers match what two parties in
+// separate processes would send.
+type Communication struct {
+	Phases [NumPhases]PhaseCommunication
+
+	recorded      bool      // Whether any message has been recorded.
+	lastPhase     Phase     // Phase of the last recorded message.
+	lastDirection Direction // Direction of the last recorded message.
+}
+
+// Record adds a message of the given number of bytes sent in the given phase and direction.
+// A message in the same phase and direction as the previous one is sent in the same round.
+func (communication *Communication) Record(phase Phase, direction Direction, bytes int) {
+	phaseCommunication := &communication.Phases[phase]
+	if direction == SenderToReceiver {
+		phaseCommunication.SenderToReceiver += bytes
+	} else {
+		phaseCommunication.ReceiverToSender += bytes
+	}
+	if !communication.recorded || communication.lastPhase != phase || communication.lastDirection != direction {
+		phaseCommunication.Rounds++
+	}
+	communication.recorded = true
+	communication.lastPhase = phase
+	communication.lastDirection = direction
+}
+
+// Piggyback adds bytes sent along with a message that was already recorded in the given phase and direction, e.g. a
+// short value appended to it, without counting a round.
+func (communication *Communication) Piggyback(phase Phase, direction Direction, bytes int) {
+	if direction == SenderToReceiver {
+		communication.Phases[phase].SenderToReceiver += bytes
+	} else {
+		communication.Phases[phase].ReceiverToSender += bytes
+	}
+}
+
+// Add adds the communication of another protocol run, e.g. the next batch of an OTExtensionSession.
+func (communication *Communication) Add(other *Communication) {
+	for phase := range communication.Phases {
+		communication.Phases[phase].SenderToReceiver += other.Phases[phase].SenderToReceiver
+		communication.Phases[phase].ReceiverToSender += other.Phases[phase].ReceiverToSender
+		communication.Phases[phase].Rounds += other.Phases[phase].Rounds
+	}
+}
+
+// Total returns the communication summed over all phases.
+func (communication *Communication) Total() PhaseCommunication {
+	var total PhaseCommunication
+	for _, phaseCommunication := range communication.Phases {
+		total.SenderToReceiver += phaseCommunication.SenderToReceiver
+		total.ReceiverToSender += phaseCommunication.ReceiverToSender
+		total.Rounds += phaseCommunication.Rounds
+	}
+	return total
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+)
+
+// CRHash is a tweakable correlation-robust hash built from fixed-key AES in TMMO mode (Guo et al. 2020):
+// H(i, x) = π(π(x) ⊕ i) ⊕ π(x), where π is AES under a key that is fixed for a session, and the tweak i holds the OT index.
+// Unlike Hash, identical rows at different positions or in different sessions give different pads, and every
+// 128-bit block only costs two AES calls instead of a SHA-256 compression.
+type CRHash struct {
+	block cipher.Block
+}
+
+// NewCRHash creates the hash with a 16-byte session key for the fixed-key AES permutation π.
+func NewCRHash(key []byte) (*CRHash, error) {
+	if len(key) != aes.BlockSize {
+		return nil, fmt.Errorf("CRHash key must be %d bytes, got %d", aes.BlockSize, len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	return &CRHash{block: block}, nil
+}
+
+// Hash hashes a packed row (e.g. q_j or t_j) with the tweak j to a string of the given bit length.
+// Rows longer than 128 bits are split into 128-bit blocks x_t, and outputs longer than 128 bits into 128-bit blocks c,
+// where block c of the output is ⊕_t TMMO(tweak(j, c, t), x_t). The output has the same byte layout as Hash.
+func (crHash *CRHash) Hash(j uint64, row []uint64, length int) []byte {
+
+	var byteLength int
+	if length <= 8 {
+		byteLength = 1
+	} else {
+		byteLength = (length + 7) / 8 // Calculate the number of bytes needed to represent the given number of bits
+	}
+
+	// Compute π(x_t) once for every 128-bit block of the row.
+	rowBytes := WordsToBytes(row)
+	numInputBlocks := (len(rowBytes) + aes.BlockSize - 1) / aes.BlockSize
+	permuted := make([]byte, numInputBlocks*aes.BlockSize)
+	copy(permuted, rowBytes)
+	for t := 0; t < numInputBlocks; t++ {
+		crHash.block.Encrypt(permuted[t*aes.BlockSize:], permuted[t*aes.BlockSize:(t+1)*aes.BlockSize])
+	}
+
+	numOutputBlocks := (byteLength + aes.BlockSize - 1) / aes.BlockSize
+	output := make([]byte, numOutputBlocks*aes.BlockSize)
+	buffer := make([]byte, aes.BlockSize)
+
+	for c := 0; c < numOutputBlocks; c++ {
+		outputBlock := output[c*aes.BlockSize : (c+1)*aes.BlockSize]
+		for t := 0; t < numInputBlocks; t++ {
+			permutedBlock := permuted[t*aes.BlockSize : (t+1)*aes.BlockSize]
+
+			// buffer = π(x_t) ⊕ tweak, with the tweak (j, c, t) in the three parts of the block
+			copy(buffer, permutedBlock)
+			binary.LittleEndian.PutUint64(buffer[0:8], binary.LittleEndian.Uint64(buffer[0:8])^j)
+			binary.LittleEndian.PutUint32(buffer[8:12], binary.LittleEndian.Uint32(buffer[8:12])^uint32(c))
+			binary.LittleEndian.PutUint32(buffer[12:16], binary.LittleEndian.Uint32(buffer[12:16])^uint32(t))
+
+			// outputBlock ^= π(π(x_t) ⊕ tweak) ⊕ π(x_t)
+			crHash.block.Encrypt(buffer, buffer)
+			for b := range outputBlock {
+				outputBlock[b] ^= buffer[b] ^ permutedBlock[b]
+			}
+		}
+	}
+
+	// Truncate the hash to the exact byte length required
+	returnHash := output[:byteLength]
+
+	// Set remaining bits to 0 if length is not multiple of 8
+	remainingBits := length % 8
+	if remainingBits != 0 {
+		returnHash[byteLength-1] &= (1 << remainingBits) - 1
+	}
+	return returnHash
+}
@@ -0,0 +1,104 @@
+package utils
+
+import (
+	"cryptographic-computing/project/elgamal"
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the OTBasic and OTExtension protocols when their inputs are invalid. They are wrapped with
+// details about the offending input, so use errors.Is to check for them.
+var (
+	ErrInvalidChoiceBit         = errors.New("invalid choice bit")
+	ErrDimensionMismatch        = errors.New("dimension mismatch")
+	ErrInvalidSecurityParameter = errors.New("invalid security parameter")
+	ErrInvalidMessageLength     = errors.New("invalid message length")
+	ErrInvalidParameter         = errors.New("invalid parameter")
+)
+
+// ByteLength returns the number of bytes used to represent an l-bit message, as in RandomBits and Hash.
+func ByteLength(l int) int {
+	if l <= 8 {
+		return 1
+	}
+	return (l + 7) / 8
+}
+
+// ValidateSecurityParameter checks that the security parameter k is 128, 192 or 256.
+func ValidateSecurityParameter(k int) error {
+	if !IsSecurityParameterSupported(k) {
+		return fmt.Errorf("%w: k must be 128, 192 or 256, got %d", ErrInvalidSecurityParameter, k)
+	}
+	return nil
+}
+
+// ValidateMessageLength checks that the bit length l of the messages is positive.
+func ValidateMessageLength(l int) error {
+	if l <= 0 {
+		return fmt.Errorf("%w: l must be positive, got %d", ErrInvalidMessageLength, l)
+	}
+	return nil
+}
+
+// ValidateCount checks that a slice (named by name) has the expected number of entries.
+func ValidateCount(name string, got int, expected int) error {
+	if got != expected {
+		return fmt.Errorf("%w: %s has %d entries, expected %d", ErrDimensionMismatch, name, got, expected)
+	}
+	return nil
+}
+
+// ValidateSelectionBits checks that every selection bit is 0 or 1.
+func ValidateSelectionBits(selectionBits []byte) error {
+	for j, bit := range selectionBits {
+		if bit > 1 {
+			return fmt.Errorf("%w: selection bit %d is %d, expected 0 or 1", ErrInvalidChoiceBit, j, bit)
+		}
+	}
+	return nil
+}
+
+// ValidatePublicKeyPairs checks that there is one pair of public keys per OT, and that every key received from the
+// other party is an element of the group other than the identity (see elgamal.ElGamal.ValidateElement).
+func ValidatePublicKeyPairs(publicKeys []*PublicKeyPair, expected int, elGamal *elgamal.ElGamal) error {
+	if err := ValidateCount("PublicKeys", len(publicKeys), expected); err != nil {
+		return err
+	}
+	for i, keys := range publicKeys {
+		if keys == nil || keys.MessageKey0 == nil || keys.MessageKey1 == nil {
+			return fmt.Errorf("%w: public key pair %d is missing", ErrDimensionMismatch, i)
+		}
+		if err := elGamal.ValidateElement(keys.MessageKey0); err != nil {
+			return fmt.Errorf("public key 0 of pair %d: %w", i, err)
+		}
+		if err := elGamal.ValidateElement(keys.MessageKey1); err != nil {
+			return fmt.Errorf("public key 1 of pair %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// ValidateBytes checks that a byte string (named by name) has the byte length of an l-bit message.
+func ValidateBytes(name string, bytes []byte, l int) error {
+	if len(bytes) != ByteLength(l) {
+		return fmt.Errorf("%w: %s has %d bytes, expected %d for l = %d", ErrInvalidMessageLength, name, len(bytes), ByteLength(l), l)
+	}
+	return nil
+}
+
+// ValidateMessagePairs checks that every message pair is present and that both messages have the byte length of
+// an l-bit message.
+func ValidateMessagePairs(messages []*MessagePair, l int) error {
+	for j, pair := range messages {
+		if pair == nil {
+			return fmt.Errorf("%w: message pair %d is missing", ErrInvalidMessageLength, j)
+		}
+		if err := ValidateBytes(fmt.Sprintf("message 0 of pair %d", j), pair.Message0, l); err != nil {
+			return err
+		}
+		if err := ValidateBytes(fmt.Sprintf("message 1 of pair %d", j), pair.Message1, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package utils
+
+import "fmt"
+
+// Low-weight irreducible polynomials x^k + tail(x) defining GF(2^k) for the supported security parameters.
+// The entries list the exponents of tail(x). Used for the KOS correlation check in the OTExtension.
+var gfReductionPolynomials = map[int][]int{
+	128: {7, 2, 1, 0},  // x^128 + x^7 + x^2 + x + 1
+	192: {7, 2, 1, 0},  // x^192 + x^7 + x^2 + x + 1
+	256: {10, 5, 2, 0}, // x^256 + x^10 + x^5 + x^2 + 1
+}
+
+// carrylessMultiply64 multiplies two 64-bit polynomials over GF(2) and returns the 128-bit product as (hi, lo).
+func carrylessMultiply64(a uint64, b uint64) (uint64, uint64) {
+	var hi, lo uint64
+	for i := 0; i < 64; i++ {
+		if (b>>i)&1 == 1 {
+			lo ^= a << i
+			if i > 0 {
+				hi ^= a >> (64 - i)
+			}
+		}
+	}
+	return hi, lo
+}
+
+// CarrylessMultiplyAdd computes acc = acc ⊕ a·b, where a and b are packed polynomials over GF(2) and
+// the product is not reduced. acc must have room for len(a) + len(b) words.
+// Since reduction is linear, a sum of many products can be accumulated unreduced and reduced once with GFReduce.
+func CarrylessMultiplyAdd(acc []uint64, a []uint64, b []uint64) {
+	for i, aWord := range a {
+		if aWord == 0 {
+			continue
+		}
+		for j, bWord := range b {
+			hi, lo := carrylessMultiply64(aWord, bWord)
+			acc[i+j] ^= lo
+			acc[i+j+1] ^= hi
+		}
+	}
+}
+
+// GFReduce reduces a packed polynomial over GF(2) of degree < 2k modulo the irreducible polynomial of GF(2^k),
+// and returns the k-bit result. The input is not modified. Only k = 128, 192 and 256 are supported.
+func GFReduce(product []uint64, k int) ([]uint64, error) {
+	tail, ok := gfReductionPolynomials[k]
+	if !ok {
+		return nil, fmt.Errorf("GF(2^%d) is not supported, k must be 128, 192 or 256", k)
+	}
+
+	reduced := make([]uint64, len(product))
+	copy(reduced, product)
+
+	// Clear every bit of degree ≥ k from the top, using x^k = tail(x).
+	for degree := 64*len(reduced) - 1; degree >= k; degree-- {
+		if GetBit(reduced, degree) == 0 {
+			continue
+		}
+		SetBit(reduced, degree, 0)
+		for _, exponent := range tail {
+			position := degree - k + exponent
+			reduced[position/64] ^= uint64(1) << (position % 64)
+		}
+	}
+	return reduced[:WordsFor(k)], nil
+}
+
+// GFMultiply multiplies two elements of GF(2^k) packed into 64-bit words.
+func GFMultiply(a []uint64, b []uint64, k int) ([]uint64, error) {
+	product := make([]uint64, len(a)+len(b))
+	CarrylessMultiplyAdd(product, a, b)
+	return GFReduce(product, k)
+}
+
+// IsGFSupported reports whether GF(2^k) arithmetic is available for the security parameter k.
+func IsGFSupported(k int) bool {
+	_, ok := gfReductionPolynomials[k]
+	return ok
+}
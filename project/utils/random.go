@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Randomness returns random if it is not nil, and crypto/rand.Reader otherwise.
+// The parties and elgamal.ElGamal use it to default to a secure source when no source is injected.
+func Randomness(random io.Reader) io.Reader {
+	if random == nil {
+		return rand.Reader
+	}
+	return random
+}
+
+// NewDeterministicReader returns a reader of an endless pseudo-random stream determined by seed, using AES in
+// CTR mode with a key and nonce derived by DeriveAESKeyAndNonce. It is meant for tests, which can give it to the
+// parties and to elgamal.ElGamal to reproduce an exact protocol transcript. Never use it for real runs.
+func NewDeterministicReader(seed []byte) (io.Reader, error) {
+	if len(seed) == 0 {
+		return nil, errors.New("seed must not be empty")
+	}
+	key, nonce := DeriveAESKeyAndNonce(seed)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, nonce)
+	return &cipher.StreamReader{S: cipher.NewCTR(block, counter), R: zeroReader{}}, nil
+}
+
+// zeroReader is an endless stream of zero bytes, which the CTR mode of NewDeterministicReader turns into its key stream.
+type zeroReader struct{}
+
+func (zeroReader) Read(buffer []byte) (int, error) {
+	clear(buffer)
+	return len(buffer), nil
+}
+
+// RandomBitsFrom reads a slice of random bytes of a given bit length from random, like RandomBits.
+func RandomBitsFrom(random io.Reader, length int) ([]byte, error) {
+	bits := make([]byte, ByteLength(length))
+	if _, err := io.ReadFull(Randomness(random), bits); err != nil {
+		return nil, fmt.Errorf("error reading randomness: %v", err)
+	}
+
+	// Set remaining bits to 0 if length is not multiple of 8
+	if remainingBits := length % 8; remainingBits != 0 {
+		bits[len(bits)-1] &= (1 << remainingBits) - 1
+	}
+	return bits, nil
+}
+
+// RandomPackedBitsFrom reads bitLength random bits packed into 64-bit words from random, like RandomPackedBits.
+func RandomPackedBitsFrom(random io.Reader, bitLength int) ([]uint64, error) {
+	randomBytes := make([]byte, 8*WordsFor(bitLength))
+	if _, err := io.ReadFull(Randomness(random), randomBytes); err != nil {
+		return nil, fmt.Errorf("error reading randomness: %v", err)
+	}
+
+	words := make([]uint64, WordsFor(bitLength))
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint64(randomBytes[8*i:])
+	}
+	ClearPadding(words, bitLength)
+	return words, nil
+}
+
+// RandomSelectionBitsFrom reads m random selection bits (0 or 1) from random, like RandomSelectionBits.
+func RandomSelectionBitsFrom(random io.Reader, m int) ([]byte, error) {
+	words, err := RandomPackedBitsFrom(random, m)
+	if err != nil {
+		return nil, err
+	}
+	return UnpackBits(words, m), nil
+}
+
+// RandomSeedFrom reads a seed of the given number of bytes from random, like RandomSeed.
+func RandomSeedFrom(random io.Reader, length int) ([]byte, error) {
+	seed := make([]byte, length)
+	if _, err := io.ReadFull(Randomness(random), seed); err != nil {
+		return nil, fmt.Errorf("error generating random seed: %v", err)
+	}
+	return seed, nil
+}
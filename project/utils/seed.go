@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+// Labels for deriving the AES key and the CTR nonce of the pseudo-random generator from a seed.
+var (
+	prgKeyLabel   = []byte("cryptographic-computing PRG key")
+	prgNonceLabel = []byte("cryptographic-computing PRG nonce")
+)
+
+// Length in bytes of the nonce in the upper half of the AES-CTR counter block. The lower half holds the block counter.
+const prgNonceLength = 8
+
+// SeedLength returns the length in bytes of the seeds for security parameter k (128, 192 or 256).
+func SeedLength(k int) int {
+	return (k + 7) / 8
+}
+
+// IsSecurityParameterSupported reports whether seeds, the PRG and the KOS correlation check are available for k.
+func IsSecurityParameterSupported(k int) bool {
+	return k == 128 || k == 192 || k == 256
+}
+
+// RandomSeed generates a seed of the given number of bytes using a secure random number generator.
+func RandomSeed(length int) ([]byte, error) {
+	return RandomSeedFrom(nil, length)
+}
+
+// SeedToInt converts a seed to a big int, e.g. for encrypting it with ElGamal in the base OTs.
+func SeedToInt(seed []byte) *big.Int {
+	return new(big.Int).SetBytes(seed)
+}
+
+// SeedFromInt converts a big int back to a seed of the given number of bytes, restoring the leading zeros
+// that the big int representation removes.
+func SeedFromInt(value *big.Int, length int) ([]byte, error) {
+	if value.Sign() < 0 || value.BitLen() > 8*length {
+		return nil, fmt.Errorf("value does not fit in a seed of %d bytes", length)
+	}
+	return value.FillBytes(make([]byte, length)), nil
+}
+
+// DeriveAESKeyAndNonce derives the AES key and the CTR nonce of the pseudo-random generator from a seed,
+// using HMAC-SHA256 with the seed as key as a KDF. Seeds of up to 16 bytes give an AES-128 key, and longer
+// seeds (k = 192 and 256) an AES-256 key, so the PRG does not cut the security of the seed down to 128 bits.
+func DeriveAESKeyAndNonce(seed []byte) ([]byte, []byte) {
+	keyLength := 16
+	if len(seed) > 16 {
+		keyLength = 32
+	}
+
+	mac := hmac.New(sha256.New, seed)
+	mac.Write(prgKeyLabel)
+	key := mac.Sum(nil)[:keyLength]
+
+	mac.Reset()
+	mac.Write(prgNonceLabel)
+	nonce := mac.Sum(nil)[:prgNonceLength]
+
+	return key, nonce
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+)
+
+// Session identifiers bind the messages of an OTExtension run to that run. Both parties contribute a random share,
+// and the session ID is derived from the two shares, so neither party alone chooses it. The session ID is mixed into
+// the encryption of the base OT seeds together with the public keys of the base OTs, the seeds of the PRG and the
+// hashes of the rows, so a message replayed from another session decrypts to garbage or is rejected.
+
+// SessionIDLength is the length in bytes of a session ID and of each party's share of it.
+const SessionIDLength = 16
+
+// Labels for the values derived from a session ID.
+var (
+	sessionIDLabel      = []byte("cryptographic-computing session ID")
+	sessionPadLabel     = []byte("cryptographic-computing base OT pad")
+	sessionKeyPadLabel  = []byte("cryptographic-computing base OT key pad")
+	sessionSeedLabel    = []byte("cryptographic-computing session PRG seed")
+	sessionHashKeyLabel = []byte("cryptographic-computing session hash key")
+)
+
+// NewSessionID derives the session ID from the sender's and the receiver's shares of SessionIDLength bytes.
+// Returns an error wrapping ErrInvalidMessageLength if a share has another length.
+func NewSessionID(senderShare []byte, receiverShare []byte) ([]byte, error) {
+	if err := ValidateBytes("sender's session share", senderShare, 8*SessionIDLength); err != nil {
+		return nil, err
+	}
+	if err := ValidateBytes("receiver's session share", receiverShare, 8*SessionIDLength); err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	hash.Write(sessionIDLabel)
+	hash.Write(senderShare)
+	hash.Write(receiverShare)
+	return hash.Sum(nil)[:SessionIDLength], nil
+}
+
+// sessionMAC computes HMAC-SHA256 keyed by the session ID over the label, the base OT index i, the bit b and data,
+// truncated to length bytes (at most 32).
+func sessionMAC(sessionID []byte, label []byte, i int, b byte, data []byte, length int) []byte {
+	mac := hmac.New(sha256.New, sessionID)
+	mac.Write(label)
+	var index [9]byte
+	binary.BigEndian.PutUint64(index[:8], uint64(i))
+	index[8] = b
+	mac.Write(index[:])
+	mac.Write(data)
+	return mac.Sum(nil)[:length]
+}
+
+// SessionMaskSeed masks seed b of base OT i with a pad derived from the session ID, so the seed can only be
+// recovered from its encryption in the same session. Masking twice with the same arguments restores the seed.
+func SessionMaskSeed(sessionID []byte, i int, b byte, seed []byte) []byte {
+	return xorPad(seed, sessionMAC(sessionID, sessionPadLabel, i, b, nil, len(seed)))
+}
+
+// SessionBindSeed masks seed b of base OT i with a pad derived from the session ID and the encoded public keys of
+// the base OT, so the seed encrypted under these keys can only be recovered in the session with the same keys.
+// Masking twice with the same arguments restores the seed.
+func SessionBindSeed(sessionID []byte, i int, b byte, keys []byte, seed []byte) []byte {
+	return xorPad(seed, sessionMAC(sessionID, sessionKeyPadLabel, i, b, keys, len(seed)))
+}
+
+// xorPad returns seed ⊕ pad for a pad of the same length.
+func xorPad(seed []byte, pad []byte) []byte {
+	masked := make([]byte, len(seed))
+	for t := range seed {
+		masked[t] = seed[t] ^ pad[t]
+	}
+	return masked
+}
+
+// SessionPRGSeed derives the PRG seed of seed b of base OT i in the session, which has the same length as the seed.
+func SessionPRGSeed(sessionID []byte, i int, b byte, seed []byte) []byte {
+	return sessionMAC(sessionID, sessionSeedLabel, i, b, seed, len(seed))
+}
+
+// SessionHashKey derives the key of the row hash (e.g. of a CRHash) in the session from the key chosen by the
+// sender, which has the same length as the key.
+func SessionHashKey(sessionID []byte, key []byte) []byte {
+	return sessionMAC(sessionID, sessionHashKeyLabel, 0, 0, key, len(key))
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Streaming authenticated encryption of payloads of any size, e.g. files sent with the output of an OT as the key.
+// The payload is split into chunks of StreamChunkSize bytes, and each chunk is encrypted with AES-256-GCM under a
+// key derived from the OT output, with the chunk counter and a final flag in the nonce (the STREAM construction of
+// Hoang et al. 2015). This way only one chunk is held in memory, reordered or dropped chunks fail to decrypt, and a
+// stream truncated before its final chunk is detected. A stream is a sequence of frames:
+//
+//	flag (1 byte, 1 for the final chunk), length (uint32), ciphertext of the chunk with the GCM tag
+//
+// The final chunk may be empty, so an empty payload is a single frame with only the tag.
+
+// StreamChunkSize is the number of plaintext bytes in every chunk except the final one.
+const StreamChunkSize = 64 * 1024
+
+// ErrStreamAuthentication is returned when a chunk of a stream does not decrypt under the key, e.g. because the
+// stream was changed or the key is the wrong one of the two OT messages.
+var ErrStreamAuthentication = errors.New("stream authentication failed")
+
+const (
+	streamFrameHeaderLength = 5  // Final flag and length of a frame.
+	streamTagLength         = 16 // Length of the GCM tag of every chunk.
+)
+
+// newStreamCipher creates the AES-256-GCM cipher of a stream from the key, which may be any OT output.
+func newStreamCipher(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, errors.New("stream key must not be empty")
+	}
+	hash := sha256.New()
+	hash.Write([]byte("OT stream key"))
+	hash.Write(key)
+	block, err := aes.NewCipher(hash.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+	aead, err := cipher.NewGCMWithTagSize(block, streamTagLength)
+	if err != nil {
+		return nil, fmt.Errorf("error creating GCM: %v", err)
+	}
+	return aead, nil
+}
+
+// streamNonce returns the nonce of chunk number counter: the counter (uint64) followed by the final flag.
+func streamNonce(counter uint64, final bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[0:8], counter)
+	if final {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// EncryptStream reads the payload until EOF, and writes it encrypted under key as a stream of frames.
+// Returns the number of bytes written, which is the communication of the stream.
+func EncryptStream(key []byte, payload io.Reader, output io.Writer) (int64, error) {
+	aead, err := newStreamCipher(key)
+	if err != nil {
+		return 0, err
+	}
+
+	reader := bufio.NewReaderSize(payload, StreamChunkSize)
+	chunk := make([]byte, StreamChunkSize)
+	frame := make([]byte, streamFrameHeaderLength, streamFrameHeaderLength+StreamChunkSize+aead.Overhead())
+	var written int64
+
+	for counter := uint64(0); ; counter++ {
+		n, err := io.ReadFull(reader, chunk)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return written, fmt.Errorf("error reading payload: %v", err)
+		}
+
+		// The chunk is final if the payload ended in it or right after it.
+		final := err != nil
+		if !final {
+			if _, err := reader.Peek(1); err == io.EOF {
+				final = true
+			} else if err != nil {
+				return written, fmt.Errorf("error reading payload: %v", err)
+			}
+		}
+
+		frame = frame[:streamFrameHeaderLength]
+		if final {
+			frame[0] = 1
+		} else {
+			frame[0] = 0
+		}
+		frame = aead.Seal(frame, streamNonce(counter, final), chunk[:n], nil)
+		binary.BigEndian.PutUint32(frame[1:streamFrameHeaderLength], uint32(len(frame)-streamFrameHeaderLength))
+
+		count, err := output.Write(frame)
+		written += int64(count)
+		if err != nil {
+			return written, fmt.Errorf("error writing stream: %v", err)
+		}
+		if final {
+			return written, nil
+		}
+	}
+}
+
+// readStreamFrame reads the next frame of a stream into buffer, and returns its final flag and ciphertext.
+// Returns an error wrapping ErrMalformedMessage if the frame is invalid or the stream ends before its final frame.
+func readStreamFrame(input io.Reader, buffer []byte, overhead int) (bool, []byte, error) {
+	header := buffer[:streamFrameHeaderLength]
+	if _, err := io.ReadFull(input, header); err != nil {
+		return false, nil, fmt.Errorf("%w: stream ended before its final chunk: %v", ErrMalformedMessage, err)
+	}
+	if header[0] > 1 {
+		return false, nil, fmt.Errorf("%w: invalid final flag %d", ErrMalformedMessage, header[0])
+	}
+	length := int(binary.BigEndian.Uint32(header[1:]))
+	if length < overhead || length > StreamChunkSize+overhead {
+		return false, nil, fmt.Errorf("%w: chunk of %d bytes", ErrMalformedMessage, length)
+	}
+	ciphertext := buffer[streamFrameHeaderLength : streamFrameHeaderLength+length]
+	if _, err := io.ReadFull(input, ciphertext); err != nil {
+		return false, nil, fmt.Errorf("%w: stream ended in a chunk: %v", ErrMalformedMessage, err)
+	}
+	return header[0] == 1, ciphertext, nil
+}
+
+// DecryptStream reads one stream from input, and writes the decrypted payload to output chunk by chunk.
+// It stops after the final frame, so further streams can be read from the same input. Every chunk is authenticated
+// before it is written, but if an error is returned, the chunks written so far must be discarded.
+// Returns the number of payload bytes written, and an error wrapping ErrStreamAuthentication if a chunk does not
+// decrypt, or ErrMalformedMessage if the stream is malformed or truncated.
+func DecryptStream(key []byte, input io.Reader, output io.Writer) (int64, error) {
+	aead, err := newStreamCipher(key)
+	if err != nil {
+		return 0, err
+	}
+
+	buffer := make([]byte, streamFrameHeaderLength+StreamChunkSize+aead.Overhead())
+	chunk := make([]byte, 0, StreamChunkSize)
+	var written int64
+
+	for counter := uint64(0); ; counter++ {
+		final, ciphertext, err := readStreamFrame(input, buffer, aead.Overhead())
+		if err != nil {
+			return written, err
+		}
+		chunk, err = aead.Open(chunk[:0], streamNonce(counter, final), ciphertext, nil)
+		if err != nil {
+			return written, fmt.Errorf("%w: chunk %d", ErrStreamAuthentication, counter)
+		}
+
+		count, err := output.Write(chunk)
+		written += int64(count)
+		if err != nil {
+			return written, fmt.Errorf("error writing payload: %v", err)
+		}
+		if final {
+			return written, nil
+		}
+	}
+}
+
+// SkipStream reads one stream from input without decrypting it, e.g. the stream the OT receiver has no key for.
+// Returns an error wrapping ErrMalformedMessage if the stream is malformed or truncated.
+func SkipStream(input io.Reader) error {
+	buffer := make([]byte, streamFrameHeaderLength+StreamChunkSize+streamTagLength)
+	for {
+		final, _, err := readStreamFrame(input, buffer, streamTagLength)
+		if err != nil {
+			return err
+		}
+		if final {
+			return nil
+		}
+	}
+}
@@ -3,16 +3,14 @@ package utils
 // Import your ElGamal package
 import (
 	"crypto/aes"
-	"crypto/rand"
+	"crypto/cipher"
 	"crypto/sha256"
 	"cryptographic-computing/project/elgamal"
+	"cryptographic-computing/project/parallel"
 	"encoding/binary"
 	"fmt"
 	"log"
 	"math/big"
-	mathRand "math/rand"
-	"sync"
-	"time"
 )
 
 // Struct to store messages M0 and M1
@@ -34,10 +32,17 @@ type CiphertextPair struct {
 	Ciphertext1 *elgamal.Ciphertext
 }
 
+// Struct to store hybrid ciphertexts of two messages M0 and M1 of any length, as sent in OTBasic.
+type HybridCiphertextPair struct {
+	Ciphertext0 *elgamal.HybridCiphertext
+	Ciphertext1 *elgamal.HybridCiphertext
+}
+
 // Struct to store seeds for the OTExtension protocol in the initial phase.
+// Both seeds are byte strings of SeedLength(k) bytes.
 type Seed struct {
-	Seed0 *big.Int
-	Seed1 *big.Int
+	Seed0 []byte
+	Seed1 []byte
 }
 
 // Struct to store ciphertexts in the OTExtension protocol in the final phase.
@@ -46,6 +51,32 @@ type ByteCiphertextPair struct {
 	Y1 []byte
 }
 
+// Struct to store the N messages (x^0_j, ..., x^(N-1)_j) of a 1-out-of-N OT
+type MessageTuple struct {
+	Messages [][]byte
+}
+
+// Struct to store the N ciphertexts of a 1-out-of-N OT in the OTExtension protocol in the final phase.
+type ByteCiphertextTuple struct {
+	Y [][]byte
+}
+
+// Struct to store the correlation check sent from the receiver to the sender in the KOS OTExtension protocol.
+// X = ⊕_j χ_j·r_j and T = ⊕_j χ_j·t_j in GF(2^k), where χ_j are the coin-tossed challenges.
+type CorrelationCheck struct {
+	X []uint64
+	T []uint64
+}
+
+// Struct to store the message of the silent OT sender for one GGM tree with 2^h leaves.
+// Keys0[i] and Keys1[i] are the XORs of the left and right nodes on level i+1 of the tree, each masked with a hash of
+// one base COT string, and Sum is the XOR of all leaves and Δ. All entries are 128-bit blocks of two words.
+type GGMTreeMessage struct {
+	Keys0 [][2]uint64
+	Keys1 [][2]uint64
+	Sum   [2]uint64
+}
+
 // DEPRECATED TEST METHOD
 // PseudoRandomGenerator replacement that generates a bit string of a given bit length using sha256 and a seed.
 func PseudoRandomGeneratorTEST(seed *big.Int, bitLength int) ([]byte, error) {
@@ -75,19 +106,15 @@ func PseudoRandomGeneratorTEST(seed *big.Int, bitLength int) ([]byte, error) {
 }
 
 // PseudoRandomGenerator generates a pseudo-random bit string of a given bit length using AES in CTR mode.
-// The method uses a big int seed which is converted to a 16-byte slice for AES-128.
+// The AES key and nonce are derived from the bytes of the big int seed with DeriveAESKeyAndNonce, so seeds of any size can be used.
 func PseudoRandomGenerator(seed *big.Int, bitLength int) ([]byte, error) {
 	if bitLength <= 0 {
 		return nil, fmt.Errorf("bitLength must be positive")
 	}
 
-	// Convert seed to a 16-byte slice for AES-128 and Right-align seed bytes in the slice due to conversion from big int,
-	// where leading zeros are removed.
-	seedBytes := make([]byte, 16)
-	copy(seedBytes[16-len(seed.Bytes()):], seed.Bytes())
-
-	// Create the AES cipher object with the seed
-	block, err := aes.NewCipher(seedBytes)
+	// Create the AES cipher object with the key derived from the seed
+	key, nonce := DeriveAESKeyAndNonce(seed.Bytes())
+	block, err := aes.NewCipher(key)
 	if err != nil {
 		return nil, fmt.Errorf("error creating AES cipher: %v", err)
 	}
@@ -99,7 +126,8 @@ func PseudoRandomGenerator(seed *big.Int, bitLength int) ([]byte, error) {
 	for len(output) < bitLength {
 		// Increment the counter and encrypt it
 		counter++
-		binary.BigEndian.PutUint64(buffer[8:], counter) // Fill buffer with the counter value
+		copy(buffer, nonce)                             // Fill buffer with the nonce
+		binary.BigEndian.PutUint64(buffer[8:], counter) // and the counter value
 		block.Encrypt(buffer, buffer)                   // Encrypt buffer to produce a pseudo-random block of 16 bytes
 
 		// Extract each bit and store it in its own byte
@@ -114,6 +142,55 @@ func PseudoRandomGenerator(seed *big.Int, bitLength int) ([]byte, error) {
 	return output, nil
 }
 
+// PseudoRandomGeneratorWords generates a pseudo-random bit string of a given bit length using AES in CTR mode,
+// packed into 64-bit words (bit j is stored in word j/64 at position j%64). This uses 8 times less memory than
+// PseudoRandomGenerator, which stores one bit per byte. The AES key and nonce are derived from the seed with
+// DeriveAESKeyAndNonce.
+func PseudoRandomGeneratorWords(seed []byte, bitLength int) ([]uint64, error) {
+	return PseudoRandomGeneratorWordsFrom(seed, 0, bitLength)
+}
+
+// PseudoRandomGeneratorWordsFrom generates bitLength bits of the output of PseudoRandomGeneratorWords, starting at
+// bit bitOffset, by starting the AES counter at the matching block. This lets the OTExtension expand the columns
+// in chunks that line up with the full output. bitOffset must be a multiple of 64.
+func PseudoRandomGeneratorWordsFrom(seed []byte, bitOffset int, bitLength int) ([]uint64, error) {
+	if bitLength <= 0 {
+		return nil, fmt.Errorf("bitLength must be positive")
+	}
+	if bitOffset < 0 || bitOffset%64 != 0 {
+		return nil, fmt.Errorf("bitOffset must be a non-negative multiple of 64")
+	}
+	if len(seed) == 0 {
+		return nil, fmt.Errorf("seed must not be empty")
+	}
+
+	key, nonce := DeriveAESKeyAndNonce(seed)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("error creating AES cipher: %v", err)
+	}
+
+	// The counter block is the nonce followed by the block counter, which starts at the AES block containing
+	// bitOffset. If bitOffset is in the middle of a block, the first 8 bytes of the key stream are skipped.
+	counter := make([]byte, aes.BlockSize)
+	copy(counter, nonce)
+	binary.BigEndian.PutUint64(counter[8:], uint64(bitOffset/128))
+	skip := (bitOffset % 128) / 8
+
+	// Encrypt a zero buffer in CTR mode to get the key stream, and read it as little-endian words.
+	numWords := WordsFor(bitLength)
+	stream := make([]byte, skip+8*numWords)
+	cipher.NewCTR(block, counter).XORKeyStream(stream, stream)
+
+	output := make([]uint64, numWords)
+	for i := range output {
+		output[i] = binary.LittleEndian.Uint64(stream[skip+8*i:])
+	}
+	ClearPadding(output, bitLength)
+
+	return output, nil
+}
+
 // Hash creates a hash of the input data with a specified bit length using sha256.
 func Hash(originalData []byte, length int) []byte {
 
@@ -150,6 +227,34 @@ func Hash(originalData []byte, length int) []byte {
 	return returnHash
 }
 
+// PaddedLength returns the byte length both messages of a pair are padded to when the messages have different
+// lengths: the length of the longer message plus one byte for the padding marker.
+func PaddedLength(pair *MessagePair) int {
+	return max(len(pair.Message0), len(pair.Message1)) + 1
+}
+
+// PadMessage pads a message to length bytes by appending the marker 0x80 and zeros (ISO/IEC 7816-4 padding),
+// so messages of different lengths cannot be told apart after encryption. length must exceed len(message).
+func PadMessage(message []byte, length int) []byte {
+	padded := make([]byte, length)
+	copy(padded, message)
+	padded[len(message)] = 0x80
+	return padded
+}
+
+// UnpadMessage removes the padding of PadMessage. Returns an error wrapping ErrInvalidMessageLength if the
+// padding is invalid.
+func UnpadMessage(padded []byte) ([]byte, error) {
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != 0x80 {
+		return nil, fmt.Errorf("%w: invalid padding of a message of %d bytes", ErrInvalidMessageLength, len(padded))
+	}
+	return padded[:i], nil
+}
+
 // PrintBinaryString prints the binary representation of a []byte slice.
 func PrintBinaryString(bytes []byte) {
 	binaryString := ""
@@ -159,28 +264,12 @@ func PrintBinaryString(bytes []byte) {
 	fmt.Println("As binary string:", binaryString)
 }
 
-// RandomBits generates a slice of random bytes of a given bit length
+// RandomBits generates a slice of random bytes of a given bit length using a secure random number generator.
 func RandomBits(length int) []byte {
-
-	var numBytes int
-	if length <= 8 {
-		numBytes = 1
-	} else {
-		numBytes = (length + 7) / 8 // Calculate the number of bytes needed to represent the given number of bits
-	}
-
-	bits := make([]byte, numBytes)
-
-	_, err := rand.Read(bits)
+	bits, err := RandomBitsFrom(nil, length)
 	if err != nil {
 		log.Fatal(err)
 	}
-
-	// Set remaining bits to 0 if length is not multiple of 8
-	remainingBits := length % 8
-	if remainingBits != 0 {
-		bits[numBytes-1] &= (1 << remainingBits) - 1
-	}
 	return bits
 }
 
@@ -193,77 +282,13 @@ func AllOnesBytes(length int) []byte {
 	return b
 }
 
-// RandomSelectionBits generates a slice of m random selection bits (0 or 1)
+// RandomSelectionBits generates a slice of m random selection bits (0 or 1) using a secure random number generator.
 func RandomSelectionBits(m int) []byte {
-	// Create a new random source and random generator
-	src := mathRand.NewSource(time.Now().UnixNano())
-	rnd := mathRand.New(src)
-
-	SelectionBits := make([]byte, m)
-	for i := range SelectionBits {
-		SelectionBits[i] = byte(rnd.Intn(2)) // Generate a random byte 0 or 1
-	}
-	return SelectionBits
-}
-
-// divideMatrix divides a matrix of size rows x cols into smaller matrices of size rows x rows.
-// The last matrix is padded with zeros if necessary to create a square matrix of size rows x rows as the other matrices.
-func divideMatrix(matrix [][]byte, rows int, cols int) [][][]byte {
-
-	if rows > cols {
-		panic("divideMatrix: cols must be greater than or equal to rows")
-	}
-
-	// Calculate the number of rows x rows matrices
-	numBaseMatrices := cols / rows
-	numMatrices := numBaseMatrices
-
-	// Add one matrix if there is padding
-	remaining_cols := cols % rows
-	padding_cols := 0
-	if remaining_cols != 0 {
-		padding_cols = rows - remaining_cols
-	}
-	if padding_cols > 0 {
-		numMatrices += 1
-	}
-
-	// Initialize the result slice with size numMatrices
-	result := make([][][]byte, numMatrices)
-
-	for i := 0; i < numBaseMatrices; i++ {
-		// Initialize smallMatrix for each sub-matrix of size rows x rows
-		smallMatrix := make([][]byte, 0, len(matrix))
-
-		for _, row := range matrix {
-
-			start := i * rows
-			end := start + rows
-
-			// Append the row slice directly to the smallMatrix
-			smallMatrix = append(smallMatrix, row[start:end])
-
-		}
-		// Append the smallMatrix to the result
-		result = append(result, smallMatrix)
-	}
-
-	// If there is padding, add the last matrix
-	if padding_cols > 0 {
-		// Initialize smallMatrix for the last sub-matrix
-		smallMatrix := make([][]byte, len(matrix))
-		for _, row := range matrix {
-			start := numBaseMatrices * rows
-			end := start + (cols % rows) // Only take the non-padded elements
-			paddedRow := make([]byte, rows)
-			copy(paddedRow, row[start:end])
-			// Padding will automatically be zeros in the remaining positions
-			smallMatrix = append(smallMatrix, paddedRow)
-		}
-		result = append(result, smallMatrix)
-
+	selectionBits, err := RandomSelectionBitsFrom(nil, m)
+	if err != nil {
+		log.Fatal(err)
 	}
-	return result
+	return selectionBits
 }
 
 // EklundhTransposeInner transposes a matrix using Eklundh's algorithm.
@@ -300,70 +325,71 @@ func EklundhTransposeInner(matrix [][]byte) [][]byte {
 	return matrix
 }
 
-// Function is responsible for dividing the matrix of m x k into smaller matrices of k x k.
-// Each of the smaller matrices is then transposed using Eklundh's algorithm.
-// The method required that k is a power of 2 else it fails.
-// The method is multithreaded if multithreaded is set to true. This is done by using goroutines for each sub-matrix.
+// Largest dimension of the tiles of EklundhTranspose. Bounded tiles keep the scratch tile of each goroutine small,
+// split a square matrix into many tiles to share between the goroutines, and limit the padding at the edges.
+const eklundhMaxTileSize = 64
+
+// EklundhTranspose transposes a matrix of any size rows × cols using Eklundh's algorithm.
+// The matrix is divided into square tiles whose dimension is the smallest power of 2 ≥ min(rows, cols), capped at
+// eklundhMaxTileSize, and the tiles at the bottom and right edges are padded with zeros. Each tile is copied into a scratch tile, transposed
+// with EklundhTransposeInner and copied into the result, so the input matrix is not changed.
+// The result is a cols × rows matrix whose rows are slices of a single buffer.
+// The method is multithreaded if multithreaded is set to true. This is done by dividing the tiles
+// between parallel.DefaultWorkers goroutines.
 func EklundhTranspose(matrix [][]byte, multithreaded bool) [][]byte {
 	rows := len(matrix)
-	cols := len(matrix[0])
-
-	matrices := divideMatrix(matrix, rows, cols) // Divide the matrix into smaller matrices of size rows x rows (k x k)
-
-	if multithreaded {
-		const numThreads = 8
-		chunkSize := (len(matrices) + numThreads - 1) / numThreads // Calculate chunk size for each thread
-
-		var wg sync.WaitGroup
-		wg.Add(numThreads)
-
-		for i := 0; i < numThreads; i++ {
-			go func(i int) {
-				defer wg.Done()
-				start := i * chunkSize
-				end := start + chunkSize
-				if end > len(matrices) {
-					end = len(matrices)
-				}
-				for j := start; j < end; j++ {
-					matrices[j] = EklundhTransposeInner(matrices[j]) // Transpose each sub-matrix
-				}
-			}(i)
-		}
-		wg.Wait() // Wait for all goroutines
-
-	} else {
-		// Transpose each sub-matrix sequentially on the main thread if multithreaded is false
-		for i, mat := range matrices {
-			matrices[i] = EklundhTransposeInner(mat)
-		}
+	cols := 0
+	if rows > 0 {
+		cols = len(matrix[0])
 	}
 
-	// Initialize the final transposed matrix
+	// Initialize the final transposed matrix in a single buffer
+	buffer := make([]byte, cols*rows)
 	transposed := make([][]byte, cols) // cols rows
 	for i := range transposed {
-		transposed[i] = make([]byte, rows) // rows columns
+		transposed[i] = buffer[i*rows : (i+1)*rows : (i+1)*rows] // rows columns
+	}
+	if rows == 0 || cols == 0 {
+		return transposed
 	}
 
-	// Calculate padding
-	padding_rows := 0
-	if cols%rows != 0 {
-		padding_rows = rows - (cols % rows)
+	tileSize := 1
+	for tileSize < rows && tileSize < cols && tileSize < eklundhMaxTileSize {
+		tileSize *= 2
 	}
+	rowTiles := (rows + tileSize - 1) / tileSize
+	colTiles := (cols + tileSize - 1) / tileSize
+
+	workers := 1
+	if multithreaded {
+		workers = parallel.DefaultWorkers()
+	}
+	parallel.For(rowTiles*colTiles, workers, func(start int, end int) {
+		tile := make([][]byte, tileSize) // Scratch tile of this goroutine
+		tileBuffer := make([]byte, tileSize*tileSize)
+		for i := range tile {
+			tile[i] = tileBuffer[i*tileSize : (i+1)*tileSize]
+		}
 
-	// Stack the transposed matrices vertically
-	iterations := len(matrices)*len(matrices[0]) - padding_rows
-	currentRow := 0
-	for _, mat := range matrices {
-		for _, row := range mat {
-			transposed[currentRow] = row
-			currentRow++
+		for t := start; t < end; t++ {
+			rowStart := (t / colTiles) * tileSize
+			colStart := (t % colTiles) * tileSize
+			rowEnd := min(rowStart+tileSize, rows)
+			colEnd := min(colStart+tileSize, cols)
 
-			if currentRow == iterations {
-				break
+			// Copy the tile, padded with zeros, and transpose it
+			clear(tileBuffer)
+			for i := rowStart; i < rowEnd; i++ {
+				copy(tile[i-rowStart], matrix[i][colStart:colEnd])
+			}
+			EklundhTransposeInner(tile)
+
+			// Row j of the transposed tile holds column j of the tile
+			for j := colStart; j < colEnd; j++ {
+				copy(transposed[j][rowStart:rowEnd], tile[j-colStart])
 			}
 		}
-	}
+	})
 
 	return transposed
 }
@@ -487,31 +513,6 @@ func max(a, b int) int {
 	return b
 }
 
-func TestDivideMatrix() {
-	matrix := [][]byte{
-		{0, 1, 0, 1, 1, 0, 0, 1, 1},
-		{0, 1, 0, 0, 0, 0, 0, 1, 1},
-	}
-
-	rows := len(matrix)
-	cols := len(matrix[0])
-
-	for _, row := range matrix {
-		fmt.Println(row)
-	}
-	fmt.Println()
-
-	dividedMatrices := divideMatrix(matrix, rows, cols)
-
-	for _, mat := range dividedMatrices {
-		for _, row := range mat {
-			fmt.Println(row)
-		}
-		fmt.Println()
-	}
-
-}
-
 func TestEklundhTranspose() {
 	matrix := [][]byte{
 		{1, 0, 1, 0},
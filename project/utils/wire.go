@@ -0,0 +1,620 @@
+package utils
+
+import (
+	"cryptographic-computing/project/elgamal"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Binary wire format for the messages exchanged by the parties of OTBasicProtocol and of the OTExtension protocols
+// with the ElGamal base OTs, including the KOS correlation check and the correlated OTs, so the sender and the
+// receiver can run in separate processes. Only the message types listed below are covered. The other messages,
+// e.g. the hash key of OTExtension.OTSender.ChooseHashFunction, the corrections of OTDerandomizeProtocol, the
+// ciphertext tuples of the 1-out-of-N OTs and the messages of the silent OT, have no message type yet, and their
+// sizes are accounted with ByteStringsWireLength.
+//
+// Every message starts with a 2-byte header: the format version (WireVersion) and the message type.
+// The header is followed by the body of the message type:
+//
+//	WirePublicKeyPairs:        count (uint32), then count × (MessageKey0, MessageKey1) as integers
+//	WireCiphertextPairs:       count (uint32), then count × (C1, C2 of Ciphertext0, C1, C2 of Ciphertext1) as integers
+//	WireBitMatrix:             rows (uint32), cols (uint32), then rows × RowWords words (uint64)
+//	WireByteCiphertextPairs:   count (uint32), then count × (Y0, Y1) as byte strings
+//	WireHybridCiphertextPairs: count (uint32), then count × (C1, Data of Ciphertext0, C1, Data of Ciphertext1),
+//	                           with C1 as an integer and Data as a byte string
+//	WireSessionShare:          the share as a byte string of SessionIDLength bytes
+//	WireChallengeCommitment:   the commitment as a byte string
+//	WireChallengeSeed:         the seed as a byte string
+//	WireCorrelationCheck:      words (uint32), then words × x and words × t as words (uint64)
+//	WireCorrelatedCiphertexts: count (uint32), then count × y as byte strings
+//
+// Integers are non-negative big-endian byte strings without leading zero bytes, so 0 is the empty string and every
+// integer has a single encoding. Byte strings are prefixed by their length (uint32). All fixed-size numbers are
+// big-endian. Unmarshalling rejects unknown versions, the wrong message type, lengths and counts that exceed the
+// input or the limits below, integers with leading zero bytes, non-zero padding bits and trailing bytes.
+//
+// Unmarshalling only checks the encoding. The parties check the decoded values when they receive them: the public
+// keys and the c1 of the chosen ciphertexts must be group elements other than the identity (see
+// OTBasic.OTSender.ReceiveKeys, OTExtension.OTReceiver.ReceiveKeys, OTExtension.OTSender.DecryptSeeds and
+// OTBasic.OTReceiver.DecryptMessage, which return elgamal.ErrInvalidElement), and the lengths of the seeds,
+// commitments and ciphertexts must match the protocol parameters.
+
+// WireVersion is the version of the wire format written by the Marshal functions.
+const WireVersion byte = 1
+
+// Message types of the wire format.
+const (
+	WirePublicKeyPairs        byte = 1  // []*PublicKeyPair, the base OT public keys.
+	WireCiphertextPairs       byte = 2  // []*CiphertextPair, the ElGamal encrypted seeds of the base OTs.
+	WireBitMatrix             byte = 3  // *BitMatrix, the matrix U of the OTExtension protocols.
+	WireByteCiphertextPairs   byte = 4  // []*ByteCiphertextPair, the final ciphertexts of the OTExtension protocols.
+	WireHybridCiphertextPairs byte = 5  // []*HybridCiphertextPair, the hybrid ElGamal encrypted messages of OTBasic.
+	WireSessionShare          byte = 6  // []byte, a party's share of the session ID of OTExtension.
+	WireChallengeCommitment   byte = 7  // []byte, the sender's commitment to its challenge seed in the KOS protocol.
+	WireChallengeSeed         byte = 8  // []byte, a party's challenge seed in the KOS protocol.
+	WireCorrelationCheck      byte = 9  // *CorrelationCheck, the receiver's correlation check in the KOS protocol.
+	WireCorrelatedCiphertexts byte = 10 // [][]byte, the masked strings y_j of the correlated OTs.
+)
+
+// Limits enforced when unmarshalling, so a malformed or malicious message cannot force huge allocations.
+const (
+	MaxWireFieldLength = 1 << 24 // Maximum length in bytes of a single integer or byte string.
+	MaxWireCount       = 1 << 30 // Maximum number of pairs, or rows and columns of a matrix.
+)
+
+// ErrMalformedMessage is returned when a message in the wire format cannot be unmarshalled.
+var ErrMalformedMessage = errors.New("malformed message")
+
+const wireHeaderLength = 2
+
+// MarshalPublicKeyPairs encodes the public key pairs sent in the base OTs.
+func MarshalPublicKeyPairs(pairs []*PublicKeyPair) ([]byte, error) {
+	writer := newWireWriter(WirePublicKeyPairs, len(pairs))
+	for j, pair := range pairs {
+		if pair == nil {
+			return nil, fmt.Errorf("public key pair %d is missing", j)
+		}
+		if err := writer.writeInts(pair.MessageKey0, pair.MessageKey1); err != nil {
+			return nil, fmt.Errorf("public key pair %d: %w", j, err)
+		}
+	}
+	return writer.data, nil
+}
+
+// UnmarshalPublicKeyPairs decodes public key pairs encoded by MarshalPublicKeyPairs.
+func UnmarshalPublicKeyPairs(data []byte) ([]*PublicKeyPair, error) {
+	reader := newWireReader(data, WirePublicKeyPairs)
+	count := reader.readCount(2 * 4) // Every pair holds at least two length prefixes
+	pairs := make([]*PublicKeyPair, count)
+	for j := range pairs {
+		pairs[j] = &PublicKeyPair{
+			MessageKey0: reader.readInt(),
+			MessageKey1: reader.readInt(),
+		}
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// MarshalCiphertextPairs encodes ElGamal ciphertext pairs.
+func MarshalCiphertextPairs(pairs []*CiphertextPair) ([]byte, error) {
+	writer := newWireWriter(WireCiphertextPairs, len(pairs))
+	for j, pair := range pairs {
+		if pair == nil || pair.Ciphertext0 == nil || pair.Ciphertext1 == nil {
+			return nil, fmt.Errorf("ciphertext pair %d is missing", j)
+		}
+		err := writer.writeInts(pair.Ciphertext0.C1, pair.Ciphertext0.C2, pair.Ciphertext1.C1, pair.Ciphertext1.C2)
+		if err != nil {
+			return nil, fmt.Errorf("ciphertext pair %d: %w", j, err)
+		}
+	}
+	return writer.data, nil
+}
+
+// UnmarshalCiphertextPairs decodes ElGamal ciphertext pairs encoded by MarshalCiphertextPairs.
+func UnmarshalCiphertextPairs(data []byte) ([]*CiphertextPair, error) {
+	reader := newWireReader(data, WireCiphertextPairs)
+	count := reader.readCount(4 * 4) // Every pair holds at least four length prefixes
+	pairs := make([]*CiphertextPair, count)
+	for j := range pairs {
+		pairs[j] = &CiphertextPair{
+			Ciphertext0: &elgamal.Ciphertext{C1: reader.readInt(), C2: reader.readInt()},
+			Ciphertext1: &elgamal.Ciphertext{C1: reader.readInt(), C2: reader.readInt()},
+		}
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// MarshalHybridCiphertextPairs encodes hybrid ElGamal ciphertext pairs.
+func MarshalHybridCiphertextPairs(pairs []*HybridCiphertextPair) ([]byte, error) {
+	writer := newWireWriter(WireHybridCiphertextPairs, len(pairs))
+	for j, pair := range pairs {
+		if pair == nil || pair.Ciphertext0 == nil || pair.Ciphertext1 == nil {
+			return nil, fmt.Errorf("ciphertext pair %d is missing", j)
+		}
+		for _, ciphertext := range []*elgamal.HybridCiphertext{pair.Ciphertext0, pair.Ciphertext1} {
+			if err := writer.writeInts(ciphertext.C1); err != nil {
+				return nil, fmt.Errorf("ciphertext pair %d: %w", j, err)
+			}
+			if err := writer.writeBytes(ciphertext.Data); err != nil {
+				return nil, fmt.Errorf("ciphertext pair %d: %w", j, err)
+			}
+		}
+	}
+	return writer.data, nil
+}
+
+// UnmarshalHybridCiphertextPairs decodes hybrid ElGamal ciphertext pairs encoded by MarshalHybridCiphertextPairs.
+func UnmarshalHybridCiphertextPairs(data []byte) ([]*HybridCiphertextPair, error) {
+	reader := newWireReader(data, WireHybridCiphertextPairs)
+	count := reader.readCount(4 * 4) // Every pair holds at least four length prefixes
+	pairs := make([]*HybridCiphertextPair, count)
+	for j := range pairs {
+		pairs[j] = &HybridCiphertextPair{
+			Ciphertext0: &elgamal.HybridCiphertext{C1: reader.readInt(), Data: reader.readBytes()},
+			Ciphertext1: &elgamal.HybridCiphertext{C1: reader.readInt(), Data: reader.readBytes()},
+		}
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// MarshalSessionShare encodes a party's share of the session ID, which must have SessionIDLength bytes.
+func MarshalSessionShare(share []byte) ([]byte, error) {
+	if len(share) != SessionIDLength {
+		return nil, fmt.Errorf("session share of %d bytes, expected %d", len(share), SessionIDLength)
+	}
+	return marshalByteString(WireSessionShare, share)
+}
+
+// UnmarshalSessionShare decodes a share of the session ID encoded by MarshalSessionShare.
+func UnmarshalSessionShare(data []byte) ([]byte, error) {
+	share, err := unmarshalByteString(data, WireSessionShare)
+	if err != nil {
+		return nil, err
+	}
+	if len(share) != SessionIDLength {
+		return nil, fmt.Errorf("%w: session share of %d bytes, expected %d", ErrMalformedMessage, len(share), SessionIDLength)
+	}
+	return share, nil
+}
+
+// MarshalChallengeCommitment encodes the sender's commitment to its challenge seed in the KOS protocol.
+func MarshalChallengeCommitment(commitment []byte) ([]byte, error) {
+	return marshalByteString(WireChallengeCommitment, commitment)
+}
+
+// UnmarshalChallengeCommitment decodes a commitment encoded by MarshalChallengeCommitment. Its length is checked by
+// OTExtension.OTReceiver.ChooseChallengeSeed.
+func UnmarshalChallengeCommitment(data []byte) ([]byte, error) {
+	return unmarshalByteString(data, WireChallengeCommitment)
+}
+
+// MarshalChallengeSeed encodes a party's challenge seed in the KOS protocol.
+func MarshalChallengeSeed(seed []byte) ([]byte, error) {
+	return marshalByteString(WireChallengeSeed, seed)
+}
+
+// UnmarshalChallengeSeed decodes a challenge seed encoded by MarshalChallengeSeed. The receiver's seed is checked by
+// OTExtension.OTSender.OpenChallengeSeed, and the sender's seed against its commitment by
+// OTExtension.OTReceiver.ReceiveChallengeSeed.
+func UnmarshalChallengeSeed(data []byte) ([]byte, error) {
+	return unmarshalByteString(data, WireChallengeSeed)
+}
+
+// MarshalCorrelationCheck encodes the correlation check (x, t) of the KOS protocol, where x and t have the same
+// number of words.
+func MarshalCorrelationCheck(check *CorrelationCheck) ([]byte, error) {
+	if check == nil {
+		return nil, errors.New("correlation check is missing")
+	}
+	if len(check.X) != len(check.T) {
+		return nil, fmt.Errorf("correlation check has %d words of x and %d words of t", len(check.X), len(check.T))
+	}
+	writer := newWireWriter(WireCorrelationCheck, len(check.X))
+	for _, words := range [][]uint64{check.X, check.T} {
+		for _, word := range words {
+			writer.data = binary.BigEndian.AppendUint64(writer.data, word)
+		}
+	}
+	return writer.data, nil
+}
+
+// UnmarshalCorrelationCheck decodes a correlation check encoded by MarshalCorrelationCheck. The number of words is
+// checked by OTExtension.OTSender.VerifyCorrelationCheck.
+func UnmarshalCorrelationCheck(data []byte) (*CorrelationCheck, error) {
+	reader := newWireReader(data, WireCorrelationCheck)
+	words := reader.readCount(2 * 8) // Every word of x comes with a word of t
+	check := &CorrelationCheck{X: make([]uint64, words), T: make([]uint64, words)}
+	for _, words := range [][]uint64{check.X, check.T} {
+		for i := range words {
+			words[i] = reader.readUint64()
+		}
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return check, nil
+}
+
+// MarshalCorrelatedCiphertexts encodes the masked strings y_j sent by the sender of the correlated OTs.
+func MarshalCorrelatedCiphertexts(ciphertexts [][]byte) ([]byte, error) {
+	writer := newWireWriter(WireCorrelatedCiphertexts, len(ciphertexts))
+	for j, y_j := range ciphertexts {
+		if err := writer.writeBytes(y_j); err != nil {
+			return nil, fmt.Errorf("ciphertext %d: %w", j, err)
+		}
+	}
+	return writer.data, nil
+}
+
+// UnmarshalCorrelatedCiphertexts decodes the ciphertexts encoded by MarshalCorrelatedCiphertexts.
+func UnmarshalCorrelatedCiphertexts(data []byte) ([][]byte, error) {
+	reader := newWireReader(data, WireCorrelatedCiphertexts)
+	count := reader.readCount(4) // Every ciphertext holds at least a length prefix
+	ciphertexts := make([][]byte, count)
+	for j := range ciphertexts {
+		ciphertexts[j] = reader.readBytes()
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return ciphertexts, nil
+}
+
+// marshalByteString encodes a message holding a single byte string.
+func marshalByteString(messageType byte, value []byte) ([]byte, error) {
+	writer := &wireWriter{data: []byte{WireVersion, messageType}}
+	if err := writer.writeBytes(value); err != nil {
+		return nil, err
+	}
+	return writer.data, nil
+}
+
+// unmarshalByteString decodes a message holding a single byte string.
+func unmarshalByteString(data []byte, messageType byte) ([]byte, error) {
+	reader := newWireReader(data, messageType)
+	value := reader.readBytes()
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// MarshalBitMatrix encodes a packed bit matrix, such as U.
+func MarshalBitMatrix(matrix *BitMatrix) ([]byte, error) {
+	if matrix == nil {
+		return nil, errors.New("bit matrix is missing")
+	}
+	if matrix.Rows < 0 || matrix.Cols < 0 || matrix.RowWords != WordsFor(matrix.Cols) || len(matrix.Words) != matrix.Rows*matrix.RowWords {
+		return nil, fmt.Errorf("bit matrix of %d × %d bits has an inconsistent layout", matrix.Rows, matrix.Cols)
+	}
+	if matrix.Rows > MaxWireCount || matrix.Cols > MaxWireCount {
+		return nil, fmt.Errorf("bit matrix of %d × %d bits is too large", matrix.Rows, matrix.Cols)
+	}
+
+	data := make([]byte, wireHeaderLength, wireHeaderLength+8+8*len(matrix.Words))
+	data[0] = WireVersion
+	data[1] = WireBitMatrix
+	data = binary.BigEndian.AppendUint32(data, uint32(matrix.Rows))
+	data = binary.BigEndian.AppendUint32(data, uint32(matrix.Cols))
+	for _, word := range matrix.Words {
+		data = binary.BigEndian.AppendUint64(data, word)
+	}
+	return data, nil
+}
+
+// UnmarshalBitMatrix decodes a packed bit matrix encoded by MarshalBitMatrix.
+// The bits after Cols in the last word of every row must be 0.
+func UnmarshalBitMatrix(data []byte) (*BitMatrix, error) {
+	reader := newWireReader(data, WireBitMatrix)
+	rows := reader.readCount(0)
+	cols := reader.readCount(0)
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	rowWords := WordsFor(cols)
+	if uint64(rows)*uint64(rowWords)*8 != uint64(len(reader.data)) {
+		return nil, fmt.Errorf("%w: %d × %d bit matrix needs %d bytes, got %d", ErrMalformedMessage, rows, cols, uint64(rows)*uint64(rowWords)*8, len(reader.data))
+	}
+
+	matrix := NewBitMatrix(rows, cols)
+	for i := range matrix.Words {
+		matrix.Words[i] = reader.readUint64()
+	}
+	if remainingBits := cols % 64; remainingBits != 0 {
+		for i := 0; i < rows; i++ {
+			if matrix.Row(i)[rowWords-1]>>remainingBits != 0 {
+				return nil, fmt.Errorf("%w: padding bits of row %d are not 0", ErrMalformedMessage, i)
+			}
+		}
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return matrix, nil
+}
+
+// MarshalByteCiphertextPairs encodes the ciphertext pairs (y^0_j, y^1_j) sent by the OTExtension sender.
+func MarshalByteCiphertextPairs(pairs []*ByteCiphertextPair) ([]byte, error) {
+	writer := newWireWriter(WireByteCiphertextPairs, len(pairs))
+	for j, pair := range pairs {
+		if pair == nil {
+			return nil, fmt.Errorf("ciphertext pair %d is missing", j)
+		}
+		if err := writer.writeBytes(pair.Y0, pair.Y1); err != nil {
+			return nil, fmt.Errorf("ciphertext pair %d: %w", j, err)
+		}
+	}
+	return writer.data, nil
+}
+
+// UnmarshalByteCiphertextPairs decodes ciphertext pairs encoded by MarshalByteCiphertextPairs.
+func UnmarshalByteCiphertextPairs(data []byte) ([]*ByteCiphertextPair, error) {
+	reader := newWireReader(data, WireByteCiphertextPairs)
+	count := reader.readCount(2 * 4) // Every pair holds at least two length prefixes
+	pairs := make([]*ByteCiphertextPair, count)
+	for j := range pairs {
+		pairs[j] = &ByteCiphertextPair{
+			Y0: reader.readBytes(),
+			Y1: reader.readBytes(),
+		}
+	}
+	if err := reader.finish(); err != nil {
+		return nil, err
+	}
+	return pairs, nil
+}
+
+// PublicKeyPairsWireLength returns the length of the public key pairs encoded by MarshalPublicKeyPairs,
+// without encoding them.
+func PublicKeyPairsWireLength(pairs []*PublicKeyPair) int {
+	length := wireHeaderLength + 4
+	for _, pair := range pairs {
+		length += intWireLength(pair.MessageKey0) + intWireLength(pair.MessageKey1)
+	}
+	return length
+}
+
+// CiphertextPairsWireLength returns the length of the ciphertext pairs encoded by MarshalCiphertextPairs,
+// without encoding them.
+func CiphertextPairsWireLength(pairs []*CiphertextPair) int {
+	length := wireHeaderLength + 4
+	for _, pair := range pairs {
+		length += intWireLength(pair.Ciphertext0.C1) + intWireLength(pair.Ciphertext0.C2)
+		length += intWireLength(pair.Ciphertext1.C1) + intWireLength(pair.Ciphertext1.C2)
+	}
+	return length
+}
+
+// HybridCiphertextPairsWireLength returns the length of the ciphertext pairs encoded by
+// MarshalHybridCiphertextPairs, without encoding them.
+func HybridCiphertextPairsWireLength(pairs []*HybridCiphertextPair) int {
+	length := wireHeaderLength + 4
+	for _, pair := range pairs {
+		length += intWireLength(pair.Ciphertext0.C1) + 4 + len(pair.Ciphertext0.Data)
+		length += intWireLength(pair.Ciphertext1.C1) + 4 + len(pair.Ciphertext1.Data)
+	}
+	return length
+}
+
+// BitMatrixWireLength returns the length of the bit matrix encoded by MarshalBitMatrix, without encoding it.
+func BitMatrixWireLength(matrix *BitMatrix) int {
+	return wireHeaderLength + 8 + 8*len(matrix.Words)
+}
+
+// ByteCiphertextPairsWireLength returns the length of the ciphertext pairs encoded by MarshalByteCiphertextPairs,
+// without encoding them.
+func ByteCiphertextPairsWireLength(pairs []*ByteCiphertextPair) int {
+	length := wireHeaderLength + 4
+	for _, pair := range pairs {
+		length += 4 + len(pair.Y0) + 4 + len(pair.Y1)
+	}
+	return length
+}
+
+// SessionShareWireLength returns the length of a share of the session ID encoded by MarshalSessionShare.
+func SessionShareWireLength() int {
+	return wireHeaderLength + 4 + SessionIDLength
+}
+
+// ChallengeCommitmentWireLength returns the length of the commitment encoded by MarshalChallengeCommitment.
+func ChallengeCommitmentWireLength(commitment []byte) int {
+	return wireHeaderLength + 4 + len(commitment)
+}
+
+// ChallengeSeedWireLength returns the length of the challenge seed encoded by MarshalChallengeSeed.
+func ChallengeSeedWireLength(seed []byte) int {
+	return wireHeaderLength + 4 + len(seed)
+}
+
+// CorrelationCheckWireLength returns the length of the correlation check encoded by MarshalCorrelationCheck.
+func CorrelationCheckWireLength(check *CorrelationCheck) int {
+	return wireHeaderLength + 4 + 8*len(check.X) + 8*len(check.T)
+}
+
+// CorrelatedCiphertextsWireLength returns the length of the ciphertexts encoded by MarshalCorrelatedCiphertexts,
+// without encoding them.
+func CorrelatedCiphertextsWireLength(ciphertexts [][]byte) int {
+	length := wireHeaderLength + 4
+	for _, y_j := range ciphertexts {
+		length += 4 + len(y_j)
+	}
+	return length
+}
+
+// ByteStringsWireLength returns the length of a message holding byte strings of the given lengths, encoded like
+// the body of WireByteCiphertextPairs (a count followed by length-prefixed byte strings). It is used to account for
+// the messages without a message type of their own, such as the corrections of OTDerandomizeProtocol.
+func ByteStringsWireLength(lengths ...int) int {
+	length := wireHeaderLength + 4
+	for _, byteLength := range lengths {
+		length += 4 + byteLength
+	}
+	return length
+}
+
+// intWireLength returns the length of a length-prefixed non-negative integer.
+func intWireLength(value *big.Int) int {
+	return 4 + (value.BitLen()+7)/8
+}
+
+// wireWriter appends the fields of a message to its header.
+type wireWriter struct {
+	data []byte
+}
+
+// newWireWriter starts a message of the given type holding count entries.
+func newWireWriter(messageType byte, count int) *wireWriter {
+	data := []byte{WireVersion, messageType}
+	data = binary.BigEndian.AppendUint32(data, uint32(count))
+	return &wireWriter{data: data}
+}
+
+// writeInts appends non-negative integers as length-prefixed big-endian byte strings.
+func (writer *wireWriter) writeInts(values ...*big.Int) error {
+	for _, value := range values {
+		if value == nil {
+			return errors.New("integer is missing")
+		}
+		if value.Sign() < 0 {
+			return errors.New("integer is negative")
+		}
+		if err := writer.writeBytes(value.Bytes()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeBytes appends length-prefixed byte strings.
+func (writer *wireWriter) writeBytes(values ...[]byte) error {
+	for _, value := range values {
+		if len(value) > MaxWireFieldLength {
+			return fmt.Errorf("field of %d bytes exceeds the maximum of %d", len(value), MaxWireFieldLength)
+		}
+		writer.data = binary.BigEndian.AppendUint32(writer.data, uint32(len(value)))
+		writer.data = append(writer.data, value...)
+	}
+	return nil
+}
+
+// wireReader reads the fields of a message. After the first error every read returns a zero value, and the
+// error is reported by finish, so the Unmarshal functions only check for errors once.
+type wireReader struct {
+	data []byte
+	err  error
+}
+
+// newWireReader checks the header of a message of the expected type and returns a reader for its body.
+func newWireReader(data []byte, messageType byte) *wireReader {
+	reader := &wireReader{}
+	switch {
+	case len(data) < wireHeaderLength:
+		reader.err = fmt.Errorf("%w: message of %d bytes is too short", ErrMalformedMessage, len(data))
+	case data[0] != WireVersion:
+		reader.err = fmt.Errorf("%w: unsupported version %d", ErrMalformedMessage, data[0])
+	case data[1] != messageType:
+		reader.err = fmt.Errorf("%w: message type %d, expected %d", ErrMalformedMessage, data[1], messageType)
+	default:
+		reader.data = data[wireHeaderLength:]
+	}
+	return reader
+}
+
+// fail records the first error.
+func (reader *wireReader) fail(format string, args ...any) {
+	if reader.err == nil {
+		reader.err = fmt.Errorf("%w: "+format, append([]any{ErrMalformedMessage}, args...)...)
+	}
+	reader.data = nil
+}
+
+// take returns the next n bytes of the message.
+func (reader *wireReader) take(n int) []byte {
+	if reader.err != nil {
+		return nil
+	}
+	if n > len(reader.data) {
+		reader.fail("need %d more bytes, only %d left", n, len(reader.data))
+		return nil
+	}
+	bytes := reader.data[:n:n]
+	reader.data = reader.data[n:]
+	return bytes
+}
+
+func (reader *wireReader) readUint32() uint32 {
+	bytes := reader.take(4)
+	if bytes == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint32(bytes)
+}
+
+func (reader *wireReader) readUint64() uint64 {
+	bytes := reader.take(8)
+	if bytes == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(bytes)
+}
+
+// readCount reads a count of entries, each taking at least minEntryLength bytes of the remaining message.
+func (reader *wireReader) readCount(minEntryLength int) int {
+	count := reader.readUint32()
+	if count > MaxWireCount {
+		reader.fail("count %d exceeds the maximum of %d", count, MaxWireCount)
+		return 0
+	}
+	if uint64(count)*uint64(minEntryLength) > uint64(len(reader.data)) {
+		reader.fail("count %d does not fit in the remaining %d bytes", count, len(reader.data))
+		return 0
+	}
+	return int(count)
+}
+
+// readBytes reads a length-prefixed byte string. The result is a copy, so it does not alias the message.
+func (reader *wireReader) readBytes() []byte {
+	length := reader.readUint32()
+	if length > MaxWireFieldLength {
+		reader.fail("field of %d bytes exceeds the maximum of %d", length, MaxWireFieldLength)
+		return nil
+	}
+	bytes := reader.take(int(length))
+	if reader.err != nil {
+		return nil
+	}
+	return append([]byte{}, bytes...)
+}
+
+// readInt reads a non-negative integer stored as a length-prefixed big-endian byte string without leading zero bytes.
+func (reader *wireReader) readInt() *big.Int {
+	bytes := reader.readBytes()
+	if reader.err != nil {
+		return nil
+	}
+	if len(bytes) > 0 && bytes[0] == 0 {
+		reader.fail("integer of %d bytes has a leading zero byte", len(bytes))
+		return nil
+	}
+	return new(big.Int).SetBytes(bytes)
+}
+
+// finish returns the first error, or an error if there are bytes left after the message.
+func (reader *wireReader) finish() error {
+	if reader.err == nil && len(reader.data) != 0 {
+		reader.fail("%d trailing bytes", len(reader.data))
+	}
+	return reader.err
+}